@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/admin"
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// setupAdminCmdFlags sets up the flags of the admin API of the serve command.
+func setupAdminCmdFlags(command *cobra.Command) {
+	command.Flags().String("admin-listen", "", "Address to serve the admin API on, e.g. 127.0.0.1:9091 (disabled if empty)")
+	if err := viper.BindPFlag("admin-listen", command.Flags().Lookup("admin-listen")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().String("admin-token", "", "Bearer token of the admin API (visible in process listings, prefer --admin-token-file)")
+	if err := viper.BindPFlag("admin-token", command.Flags().Lookup("admin-token")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().String("admin-token-file", "", "File holding the bearer token of the admin API")
+	if err := viper.BindPFlag("admin-token-file", command.Flags().Lookup("admin-token-file")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
+
+// LoadAdminTokenFromCLI loads the bearer token of the admin API from the CLI flags and the configured secret sources.
+// A token is required to serve the admin API.
+func LoadAdminTokenFromCLI() (string, error) {
+	token, err := secrets.Resolve(secrets.Sources{
+		Name:   "admin-token",
+		Value:  viper.GetString("admin-token"),
+		File:   viper.GetString("admin-token-file"),
+		Helper: viper.GetString("credential-helper"),
+	})
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", errors.New("an admin token is required to serve the admin API")
+	}
+	secrets.Register(token)
+	return token, nil
+}
+
+// adminActions runs the actions of the admin API on the work items of the served neighborhoods, with the functions of
+// the reconcile step of the migration and of the verify command.
+type adminActions struct {
+	controller *admin.Controller
+	loops      map[uint64]*neighborhoodLoop
+}
+
+// reconciliation is the outcome of the reconciliation of a work item.
+type reconciliation struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Status    string    `json:"status"`    // Local status after the reconciliation
+	Completed bool      `json:"completed"` // True if the work item was completed, its local state is deleted
+}
+
+// Reconcile catches up with the remote work item, see reconcile. The work item must not be in flight.
+func (a adminActions) Reconcile(ctx context.Context, neighborhood uint64, id uuid.UUID) (any, error) {
+	ctx, r, err := a.client(ctx, neighborhood)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, ok := a.controller.Track(ctx, neighborhood, id)
+	if !ok {
+		return nil, admin.ErrConflict
+	}
+	defer done()
+	admin.SetStep(ctx, admin.StepReconcile)
+
+	item, err := store.LoadState(ctx, id.String())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(admin.ErrNotFound, "no local state")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	completed, err := reconcile(ctx, r, item)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Work item reconciled by an operator", "neighborhood", neighborhood, "uuid", id, "status", item.Status, "completed", completed)
+
+	return reconciliation{UUID: id, Status: item.Status.String(), Completed: completed}, nil
+}
+
+// Verify compares the local and remote states of the work item, see verifyWorkItem.
+func (a adminActions) Verify(ctx context.Context, neighborhood uint64, id uuid.UUID) (any, error) {
+	ctx, r, err := a.client(ctx, neighborhood)
+	if err != nil {
+		return nil, err
+	}
+	return verifyWorkItem(ctx, r, id)
+}
+
+// client returns the state context and an authenticated client of the neighborhood.
+func (a adminActions) client(ctx context.Context, neighborhood uint64) (context.Context, *resty.Client, error) {
+	loop, ok := a.loops[neighborhood]
+	if !ok {
+		return nil, nil, errors.WithMessagef(admin.ErrNotFound, "neighborhood %d not served", neighborhood)
+	}
+
+	ctx = store.WithStateDir(ctx, loop.stateDir)
+	r, err := loop.client(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, r, nil
+}
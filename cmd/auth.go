@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+const (
+	loginPath   = "/auth/login"
+	refreshPath = "/auth/refresh"
+
+	// tokenRefreshSkew is how long before its expiry a token is proactively refreshed
+	tokenRefreshSkew = 30 * time.Second
+)
+
+const (
+	// replayKey marks a request that is being replayed after re-authentication, so it is replayed at most once.
+	replayKey store.ContextKey = "authReplay"
+	// authRequestKey marks a login or refresh request, which must never trigger a re-authentication.
+	authRequestKey store.ContextKey = "authRequest"
+)
+
+// session keeps a resty client authenticated against the remote database.
+type session struct {
+	mu           sync.Mutex
+	client       *resty.Client
+	username     string
+	password     string
+	token        string
+	refreshToken string
+	expiresAt    time.Time // Zero if the remote database did not return an expiry
+}
+
+// AuthenticateRestClient logs in to the remote database.
+// The credentials are kept so the client can transparently log in again when the token expires or is rejected.
+func AuthenticateRestClient(ctx context.Context, r *resty.Client, username, password string) error {
+	slog.Info("Authenticating...")
+	s := &session{client: r, username: username, password: password}
+	if err := s.login(ctx); err != nil {
+		return err
+	}
+
+	r.OnBeforeRequest(s.refreshBeforeExpiry)
+	r.OnAfterResponse(s.replayOnUnauthorized)
+
+	return nil
+}
+
+// login logs in with the stored credentials and sets the resulting token on the client.
+func (s *session) login(ctx context.Context) error {
+	response, err := s.authRequest(ctx).
+		SetBody(map[string]interface{}{"username": s.username, "password": s.password}).
+		SetResult(&store.Token{}).
+		Post(loginPath)
+	if err != nil {
+		return errors.WithMessage(err, "could not login")
+	}
+
+	return s.setToken(response)
+}
+
+// refresh exchanges the refresh token for a new token, falling back to a full login.
+func (s *session) refresh(ctx context.Context) error {
+	if s.refreshToken != "" {
+		response, err := s.authRequest(ctx).
+			SetBody(map[string]interface{}{"refresh_token": s.refreshToken}).
+			SetResult(&store.Token{}).
+			Post(refreshPath)
+		if err == nil {
+			if err = s.setToken(response); err == nil {
+				return nil
+			}
+		}
+		slog.Warn("could not refresh token, logging in again", "error", err)
+	}
+
+	return s.login(ctx)
+}
+
+// setToken validates the token response and sets the token on the client.
+func (s *session) setToken(response *resty.Response) error {
+	if response == nil {
+		return fmt.Errorf("no response returned when logging in")
+	}
+
+	statusCode := response.StatusCode()
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("response status code: %d", statusCode)
+	}
+
+	token := response.Result().(*store.Token)
+	if token == nil {
+		return fmt.Errorf("no token returned")
+	}
+
+	if token.AccessToken == "" {
+		return fmt.Errorf("empty token returned")
+	}
+
+	secrets.Register(token.AccessToken)
+	secrets.Register(token.RefreshToken)
+
+	s.token = token.AccessToken
+	s.refreshToken = token.RefreshToken
+	s.expiresAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		s.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	slog.Debug("setting auth token", "expiresAt", s.expiresAt, "refreshable", s.refreshToken != "")
+	s.client.SetAuthToken(s.token)
+
+	return nil
+}
+
+// refreshBeforeExpiry is a resty request middleware refreshing the token shortly before it expires.
+func (s *session) refreshBeforeExpiry(_ *resty.Client, req *resty.Request) error {
+	if isAuthRequest(req) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expiresAt.IsZero() || time.Until(s.expiresAt) > tokenRefreshSkew {
+		return nil
+	}
+
+	slog.Info("Refreshing auth token...")
+	if err := s.refresh(req.Context()); err != nil {
+		// Let the request go through, it will be replayed if the token is rejected
+		slog.Warn("could not refresh auth token", "error", err)
+	}
+
+	return nil
+}
+
+// replayOnUnauthorized is a resty response middleware logging in again and replaying the request once
+// when the remote database rejects the token.
+func (s *session) replayOnUnauthorized(_ *resty.Client, resp *resty.Response) error {
+	req := resp.Request
+	if resp.StatusCode() != http.StatusUnauthorized || isAuthRequest(req) || req.Context().Value(replayKey) != nil {
+		return nil
+	}
+
+	if err := s.reauthenticate(req.Context(), req.RawRequest.Header.Get("Authorization")); err != nil {
+		return errors.WithMessage(err, "could not authenticate again")
+	}
+
+	slog.Info("Replaying request after authentication", "method", req.Method, "url", req.URL)
+	replayed, err := req.SetContext(context.WithValue(req.Context(), replayKey, true)).Execute(req.Method, req.URL)
+	if err != nil {
+		return errors.WithMessage(err, "could not replay request")
+	}
+	*resp = *replayed
+
+	return nil
+}
+
+// reauthenticate logs in again, unless the token was already renewed since the rejected request was sent.
+func (s *session) reauthenticate(ctx context.Context, sentAuthorization string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sentAuthorization != "" && sentAuthorization != "Bearer "+s.token {
+		return nil
+	}
+
+	slog.Info("Auth token rejected, authenticating again...")
+	return s.login(ctx)
+}
+
+// authRequest creates a login or refresh request, bound to the context of the caller.
+func (s *session) authRequest(ctx context.Context) *resty.Request {
+	return s.client.R().SetContext(context.WithValue(ctx, authRequestKey, true))
+}
+
+// isAuthRequest returns true if the request is a login or refresh request.
+// The request URL cannot be used, as resty rewrites it to an absolute URL once the request is sent.
+func isAuthRequest(req *resty.Request) bool {
+	return req.Context().Value(authRequestKey) != nil
+}
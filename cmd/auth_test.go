@@ -0,0 +1,154 @@
+package cmd_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+)
+
+const protectedUrl = testutils.RootUrl + "protected"
+
+// tokenSequenceResponder returns the given tokens, one per call, repeating the last one.
+func tokenSequenceResponder(tokens ...map[string]interface{}) httpmock.Responder {
+	calls := 0
+	return func(r *http.Request) (*http.Response, error) {
+		token := tokens[min(calls, len(tokens)-1)]
+		calls++
+		return httpmock.NewJsonResponse(http.StatusOK, token)
+	}
+}
+
+// bearerResponder returns 200 if the request carries the expected bearer token, 401 otherwise.
+func bearerResponder(token string) httpmock.Responder {
+	return func(r *http.Request) (*http.Response, error) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			return httpmock.NewStringResponse(http.StatusUnauthorized, ""), nil
+		}
+		return httpmock.NewStringResponse(http.StatusOK, "ok"), nil
+	}
+}
+
+func TestAuthenticateRestClient(t *testing.T) {
+	tt := []struct {
+		name       string
+		endpoints  []testutils.HttpResponder
+		statusCode int
+		calls      map[string]int
+	}{
+		{name: "replay after token rejection", endpoints: []testutils.HttpResponder{
+			{Method: "POST", Url: testutils.LoginUrl, Responder: tokenSequenceResponder(
+				map[string]interface{}{"access_token": "expired"},
+				map[string]interface{}{"access_token": "fresh"},
+			)},
+			{Method: "GET", Url: protectedUrl, Responder: bearerResponder("fresh")},
+		}, statusCode: http.StatusOK, calls: map[string]int{"POST " + testutils.LoginUrl: 2, "GET " + protectedUrl: 2}},
+		{name: "replay only once", endpoints: []testutils.HttpResponder{
+			{Method: "POST", Url: testutils.LoginUrl, Responder: tokenSequenceResponder(
+				map[string]interface{}{"access_token": "expired"},
+			)},
+			{Method: "GET", Url: protectedUrl, Responder: bearerResponder("fresh")},
+		}, statusCode: http.StatusUnauthorized, calls: map[string]int{"POST " + testutils.LoginUrl: 2, "GET " + protectedUrl: 2}},
+		{name: "refresh before expiry", endpoints: []testutils.HttpResponder{
+			{Method: "POST", Url: testutils.LoginUrl, Responder: tokenSequenceResponder(
+				map[string]interface{}{"access_token": "expiring", "refresh_token": "refresh", "expires_in": 1},
+			)},
+			{Method: "POST", Url: testutils.RefreshUrl, Responder: tokenSequenceResponder(
+				map[string]interface{}{"access_token": "fresh", "expires_in": 3600},
+			)},
+			{Method: "GET", Url: protectedUrl, Responder: bearerResponder("fresh")},
+		}, statusCode: http.StatusOK, calls: map[string]int{"POST " + testutils.LoginUrl: 1, "POST " + testutils.RefreshUrl: 1, "GET " + protectedUrl: 1}},
+		{name: "login when refresh fails", endpoints: []testutils.HttpResponder{
+			{Method: "POST", Url: testutils.LoginUrl, Responder: tokenSequenceResponder(
+				map[string]interface{}{"access_token": "expiring", "refresh_token": "refresh", "expires_in": 1},
+				map[string]interface{}{"access_token": "fresh", "expires_in": 3600},
+			)},
+			{Method: "POST", Url: testutils.RefreshUrl, Responder: testutils.NotFoundResponder},
+			{Method: "GET", Url: protectedUrl, Responder: bearerResponder("fresh")},
+		}, statusCode: http.StatusOK, calls: map[string]int{"POST " + testutils.LoginUrl: 2, "POST " + testutils.RefreshUrl: 1, "GET " + protectedUrl: 1}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := resty.New().SetBaseURL(testutils.RootUrl)
+			httpmock.ActivateNonDefault(client.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			for _, endpoint := range tc.endpoints {
+				httpmock.RegisterResponder(endpoint.Method, endpoint.Url, endpoint.Responder)
+			}
+
+			require.NoError(t, cmd.AuthenticateRestClient(context.Background(), client, "user", "pass"))
+
+			resp, err := client.R().Get("protected")
+			require.NoError(t, err)
+			require.Equal(t, tc.statusCode, resp.StatusCode())
+
+			info := httpmock.GetCallCountInfo()
+			for endpoint, calls := range tc.calls {
+				require.Equal(t, calls, info[endpoint], endpoint)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRestClient_LoginRejected(t *testing.T) {
+	client := resty.New().SetBaseURL(testutils.RootUrl)
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	logins := 0
+	httpmock.RegisterResponder("POST", testutils.LoginUrl, func(r *http.Request) (*http.Response, error) {
+		logins++
+		if logins > 1 {
+			return httpmock.NewStringResponse(http.StatusUnauthorized, ""), nil
+		}
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{"access_token": "expired"})
+	})
+	httpmock.RegisterResponder("GET", protectedUrl, bearerResponder("fresh"))
+
+	require.NoError(t, cmd.AuthenticateRestClient(context.Background(), client, "user", "pass"))
+
+	_, err := client.R().Get("protected")
+	require.ErrorContains(t, err, "could not authenticate again")
+	require.ErrorContains(t, err, "response status code: 401")
+	require.Equal(t, 2, logins)
+}
+
+type testContextKey string
+
+func TestAuthenticateRestClient_Context(t *testing.T) {
+	client := resty.New().SetBaseURL(testutils.RootUrl)
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", testutils.LoginUrl, tokenSequenceResponder(
+		map[string]interface{}{"access_token": "expired"},
+		map[string]interface{}{"access_token": "fresh"},
+	))
+	httpmock.RegisterResponder("GET", protectedUrl, bearerResponder("fresh"))
+
+	// Record the caller of every login request
+	var callers []any
+	client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		if req.Method == http.MethodPost {
+			callers = append(callers, req.Context().Value(testContextKey("caller")))
+		}
+		return nil
+	})
+
+	// The logins are bound to the context of the caller, including the login replaying a request
+	ctx := context.WithValue(context.Background(), testContextKey("caller"), "authenticate")
+	require.NoError(t, cmd.AuthenticateRestClient(ctx, client, "user", "pass"))
+	ctx = context.WithValue(context.Background(), testContextKey("caller"), "request")
+	resp, err := client.R().SetContext(ctx).Get("protected")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Equal(t, []any{"authenticate", "request"}, callers)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/go-resty/resty/v2"
@@ -10,6 +11,8 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 
 	"github.com/manifest-network/mfx-migrator/internal/store"
 )
@@ -29,7 +32,7 @@ Trying to claim a work item that is already failed should return an error, unles
 	RunE: ClaimCmdRunE,
 }
 
-func ClaimCmdRunE(cmd *cobra.Command, args []string) error {
+func ClaimCmdRunE(cmd *cobra.Command, args []string) (err error) {
 	c := LoadConfigFromCLI("claim-uuid")
 	slog.Debug("args", "c", c)
 	if err := c.Validate(); err != nil {
@@ -39,18 +42,38 @@ func ClaimCmdRunE(cmd *cobra.Command, args []string) error {
 	claimConfig := LoadClaimConfigFromCLI()
 	slog.Debug("args", "claim-c", claimConfig)
 
-	authConfig := LoadAuthConfigFromCLI()
+	authConfig, err := LoadAuthConfigFromCLI()
+	if err != nil {
+		return err
+	}
 	slog.Debug("args", "auth-c", authConfig)
 	if err := authConfig.Validate(); err != nil {
 		return err
 	}
 
-	r := CreateRestClient(cmd.Context(), c.Url, c.Neighborhood)
-	if err := AuthenticateRestClient(r, authConfig.Username, authConfig.Password); err != nil {
+	httpConfig := LoadHttpConfigFromCLI()
+	slog.Debug("args", "http-c", httpConfig)
+	if err := httpConfig.Validate(); err != nil {
+		return err
+	}
+
+	ctx, err := stateContext(cmd.Context(), c.Neighborhood)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := tracing.Start(ctx, "claim")
+	defer func() { tracing.End(span, err) }()
+
+	r, err := CreateRestClient(ctx, c.Url, c.Neighborhood, httpConfig)
+	if err != nil {
+		return err
+	}
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
 		return err
 	}
 
-	items, err := claimWorkItem(r, c.UUID, claimConfig)
+	items, err := claimWorkItem(ctx, r, c.UUID, claimConfig)
 	if err != nil {
 		return err
 	}
@@ -80,19 +103,19 @@ func SetupClaimCmdFlags(command *cobra.Command) {
 }
 
 // claimWorkItem claims a work item from the database
-func claimWorkItem(r *resty.Client, uuidStr string, config config.ClaimConfig) ([]*store.WorkItem, error) {
+func claimWorkItem(ctx context.Context, r *resty.Client, uuidStr string, config config.ClaimConfig) ([]*store.WorkItem, error) {
 	slog.Info("Claiming work item...")
 	var err error
 	var items []*store.WorkItem
 	if uuidStr != "" {
 		var item *store.WorkItem
-		item, err = store.ClaimWorkItemFromUUID(r, uuid.MustParse(uuidStr), config.Force)
+		item, err = store.ClaimWorkItemFromUUID(ctx, r, uuid.MustParse(uuidStr), config.Force)
 		if err != nil {
 			return nil, errors.WithMessage(err, "could not claim work item")
 		}
 		items = append(items, item)
 	} else {
-		items, err = store.ClaimWorkItemFromQueue(r)
+		items, err = store.ClaimWorkItemFromQueue(ctx, r)
 		if err != nil {
 			return nil, errors.WithMessage(err, "could not claim work item")
 		}
@@ -101,6 +124,7 @@ func claimWorkItem(r *resty.Client, uuidStr string, config config.ClaimConfig) (
 	for _, item := range items {
 		slog.Info("Work item claimed", "uuid", item.UUID)
 	}
+	metrics.Claims.Add(float64(len(items)))
 
 	return items, nil
 }
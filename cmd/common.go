@@ -2,16 +2,23 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 
 	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/httpclient"
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+	"github.com/manifest-network/mfx-migrator/internal/notify"
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 	"github.com/manifest-network/mfx-migrator/internal/utils"
 
 	"github.com/manifest-network/mfx-migrator/internal/store"
@@ -20,7 +27,7 @@ import (
 const RestyClientKey store.ContextKey = "restyClient"
 
 // CreateRestClient creates a new resty client with the parsed URL and the claim config
-func CreateRestClient(ctx context.Context, url string, neighborhood uint64) *resty.Client {
+func CreateRestClient(ctx context.Context, url string, neighborhood uint64, httpConfig config.HttpConfig) (*resty.Client, error) {
 	slog.Info("Creating REST client...")
 
 	// If a resty client is already in the context, use it. Otherwise, create a new one.
@@ -31,48 +38,15 @@ func CreateRestClient(ctx context.Context, url string, neighborhood uint64) *res
 	} else {
 		client = resty.New()
 	}
-	return client.
+	client.
 		SetBaseURL(url).
-		SetPathParam("neighborhood", strconv.FormatUint(neighborhood, 10)).
-		SetRetryCount(20).                     // Retry the request process 3 times. Retry uses an exponential backoff algorithm.
-		SetRetryWaitTime(10 * time.Second).    // With a 5 seconds wait time between retries
-		SetRetryMaxWaitTime(15 * time.Minute). // And a maximum wait time of 60 seconds for the whole process
-		SetTimeout(45 * time.Second)           // Set a timeout of 10 seconds for the request
-}
-
-// AuthenticateRestClient logs in to the remote database
-func AuthenticateRestClient(r *resty.Client, username, password string) error {
-	slog.Info("Authenticating...")
-	response, err := r.R().
-		SetBody(map[string]interface{}{"username": username, "password": password}).
-		SetResult(&store.Token{}).
-		Post("/auth/login")
-	if err != nil {
-		return errors.WithMessage(err, "could not login")
-	}
-
-	if response == nil {
-		return fmt.Errorf("no response returned when logging in")
-	}
-
-	statusCode := response.StatusCode()
-	if statusCode != 200 {
-		return fmt.Errorf("response status code: %d", statusCode)
-	}
-
-	token := response.Result().(*store.Token)
-	if token == nil {
-		return fmt.Errorf("no token returned")
-	}
-
-	if token.AccessToken == "" {
-		return fmt.Errorf("empty token returned")
+		SetPathParam("neighborhood", strconv.FormatUint(neighborhood, 10))
+	if err := httpclient.ApplyTLS(client, httpConfig); err != nil {
+		return nil, err
 	}
-
-	slog.Debug("setting auth token", "token", token.AccessToken)
-	r.SetAuthToken(token.AccessToken)
-
-	return nil
+	metrics.InstrumentClient(client)
+	tracing.InstrumentClient(client)
+	return httpclient.ApplyRetryPolicy(client, httpConfig), nil
 }
 
 // LoadConfigFromCLI loads the Config from the CLI flags
@@ -87,11 +61,94 @@ func LoadConfigFromCLI(uuidKey string) config.Config {
 	}
 }
 
-func LoadAuthConfigFromCLI() config.AuthConfig {
+// LoadHttpConfigFromCLI loads the HttpConfig from the CLI flags
+func LoadHttpConfigFromCLI() config.HttpConfig {
+	return config.HttpConfig{
+		RetryCount:       viper.GetInt("http-retry-count"),
+		RetryWaitTime:    viper.GetDuration("http-retry-wait"),
+		RetryMaxWaitTime: viper.GetDuration("http-retry-max-wait"),
+		RetryBudget:      viper.GetDuration("http-retry-budget"),
+		Timeout:          viper.GetDuration("http-timeout"),
+		CAFile:           viper.GetString("ca-file"),
+		ClientCertFile:   viper.GetString("client-cert"),
+		ClientKeyFile:    viper.GetString("client-key"),
+		TLSMinVersion:    viper.GetString("tls-min-version"),
+		TLSPins:          viper.GetStringSlice("tls-pin"),
+	}
+}
+
+// LoadAuthConfigFromCLI loads the AuthConfig from the CLI flags and the configured secret sources
+func LoadAuthConfigFromCLI() (config.AuthConfig, error) {
+	username, err := secrets.Resolve(secrets.Sources{
+		Name:   "talib-username",
+		Value:  credentialValue("username"),
+		File:   viper.GetString("username-file"),
+		Helper: viper.GetString("credential-helper"),
+	})
+	if err != nil {
+		return config.AuthConfig{}, err
+	}
+
+	password, err := secrets.Resolve(secrets.Sources{
+		Name:   "talib-password",
+		Value:  credentialValue("password"),
+		File:   viper.GetString("password-file"),
+		Helper: viper.GetString("credential-helper"),
+	})
+	if err != nil {
+		return config.AuthConfig{}, err
+	}
+	secrets.Register(password)
+
 	return config.AuthConfig{
-		Username: viper.GetString("username"),
-		Password: viper.GetString("password"),
+		Username: username,
+		Password: password,
+	}, nil
+}
+
+// credentialEnvs are the environment variables of the credential keys, by key.
+var credentialEnvs = map[string]struct{ name, deprecated string }{
+	"username": {name: "TALIB_USERNAME", deprecated: "USERNAME"},
+	"password": {name: "TALIB_PASSWORD", deprecated: "PASSWORD"},
+}
+
+// credentialValue returns the plaintext value of the credential key, from a flag, the environment or the config file.
+// The deprecated environment variable is still read, with a warning, unless the prefixed one is set.
+func credentialValue(key string) string {
+	value := viper.GetString(key)
+	env := credentialEnvs[key]
+	deprecated, ok := os.LookupEnv(env.deprecated)
+	if !ok || deprecated == "" || value != deprecated {
+		return value
+	}
+
+	// viper.AutomaticEnv reads the variable named after the key before the bound ones
+	if prefixed := os.Getenv(env.name); prefixed != "" {
+		return prefixed
+	}
+	slog.Warn("Deprecated environment variable, use the prefixed one instead", "env", env.deprecated, "replacement", env.name)
+	return value
+}
+
+// strict makes the decoding of a configuration key fail on unknown keys.
+func strict(c *mapstructure.DecoderConfig) {
+	c.ErrorUnused = true
+}
+
+// LoadNotifyConfigFromCLI loads the notification configuration from the configuration file
+func LoadNotifyConfigFromCLI() (notify.Config, error) {
+	notifyConfig := notify.Config{
+		RetryCount:       3,
+		RetryWaitTime:    time.Second,
+		RetryMaxWaitTime: 30 * time.Second,
+		Timeout:          10 * time.Second,
+		QueueSize:        100,
+		DrainTimeout:     30 * time.Second,
 	}
+	if err := viper.UnmarshalKey("notify", &notifyConfig, strict); err != nil {
+		return notify.Config{}, errors.WithMessage(err, "invalid notify configuration")
+	}
+	return notifyConfig, nil
 }
 
 func LoadClaimConfigFromCLI() config.ClaimConfig {
@@ -100,25 +157,60 @@ func LoadClaimConfigFromCLI() config.ClaimConfig {
 	}
 }
 
-func LoadMigrationConfigFromCLI() config.MigrateConfig {
+// LoadMigrationConfigFromCLI loads the MigrateConfig from the CLI flags and the configuration file, with the settings of
+// the neighborhood if it is one of the neighborhoods served by the migrator
+func LoadMigrationConfigFromCLI() (config.MigrateConfig, error) {
+	migrateConfig, err := loadBaseMigrationConfig()
+	if err != nil {
+		return config.MigrateConfig{}, err
+	}
+
+	neighborhoods, err := LoadNeighborhoodsFromCLI()
+	if err != nil {
+		return config.MigrateConfig{}, err
+	}
+	if n, ok := findNeighborhood(neighborhoods, viper.GetUint64("neighborhood")); ok {
+		migrateConfig = n.Apply(migrateConfig)
+	}
+	return migrateConfig, nil
+}
+
+// loadBaseMigrationConfig loads the MigrateConfig shared by the neighborhoods from the CLI flags and the configuration file
+func loadBaseMigrationConfig() (config.MigrateConfig, error) {
 	var tokenMap map[string]utils.TokenInfo
-	if err := viper.UnmarshalKey("token-map", &tokenMap); err != nil {
-		panic(err)
+	if err := viper.UnmarshalKey("token-map", &tokenMap, strict); err != nil {
+		return config.MigrateConfig{}, errors.WithMessage(err, "invalid token-map configuration")
 	}
-	return config.MigrateConfig{
-		ChainID:          viper.GetString("chain-id"),
-		AddressPrefix:    viper.GetString("address-prefix"),
-		NodeAddress:      viper.GetString("node-address"),
-		KeyringBackend:   viper.GetString("keyring-backend"),
-		BankAddress:      viper.GetString("bank-address"),
-		ChainHome:        viper.GetString("chain-home"),
-		TokenMap:         tokenMap,
-		WaitTxTimeout:    viper.GetUint("wait-for-tx-timeout"),
-		WaitBlockTimeout: viper.GetUint("wait-for-block-timeout"),
-		Binary:           viper.GetString("binary"),
-		GasAdjustment:    viper.GetFloat64("gas-adjustment"),
-		GasPrice:         viper.GetFloat64("gas-price"),
-		GasDenom:         viper.GetString("gas-denom"),
-		FeeGranter:       viper.GetString("fee-granter"),
+	var policyConfig policy.Config
+	if err := viper.UnmarshalKey("policy", &policyConfig, strict); err != nil {
+		return config.MigrateConfig{}, errors.WithMessage(err, "invalid policy configuration")
 	}
+	return config.MigrateConfig{
+		ChainID:           viper.GetString("chain-id"),
+		AddressPrefix:     viper.GetString("address-prefix"),
+		NodeAddress:       viper.GetString("node-address"),
+		KeyringBackend:    viper.GetString("keyring-backend"),
+		BankAddress:       viper.GetString("bank-address"),
+		ChainHome:         viper.GetString("chain-home"),
+		TokenMap:          tokenMap,
+		WaitTxTimeout:     viper.GetUint("wait-for-tx-timeout"),
+		WaitBlockTimeout:  viper.GetUint("wait-for-block-timeout"),
+		ConfirmationDepth: viper.GetUint("confirmation-depth"),
+		Binary:            viper.GetString("binary"),
+		GasAdjustment:     viper.GetFloat64("gas-adjustment"),
+		GasPrice:          viper.GetFloat64("gas-price"),
+		GasDenom:          viper.GetString("gas-denom"),
+		FeeGranter:        viper.GetString("fee-granter"),
+		GasPriceSource:    viper.GetString("gas-price-source"),
+		MaxFee:            viper.GetUint64("max-fee"),
+		Policy:            policyConfig,
+		MaxAttempts:       viper.GetUint("max-attempts"),
+		RetryBackoff:      viper.GetDuration("retry-backoff"),
+		RetryMaxBackoff:   viper.GetDuration("retry-max-backoff"),
+		KillSwitch: config.KillSwitchConfig{
+			File:  viper.GetString("kill-switch-file"),
+			Talib: viper.GetBool("kill-switch-talib"),
+			Chain: viper.GetBool("kill-switch-chain"),
+		},
+	}, nil
 }
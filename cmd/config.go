@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/configfile"
+	"github.com/manifest-network/mfx-migrator/internal/manifest"
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+)
+
+// Sources of a configuration value, by increasing precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// keyAliases are the flag and environment variable names of the configuration keys not named after the key.
+var keyAliases = map[string]struct{ flag, env string }{
+	"log-level": {flag: "logLevel", env: "LOGLEVEL"},
+}
+
+// secretKeys are the configuration keys holding secrets, at any depth of the configuration.
+var secretKeys = []string{"password", "secret", "admin-token"}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration of the migrator.",
+	Long: `The config commands show and validate the effective configuration, merged from the defaults,
+the migrator-config file in ./ or /config, the selected profile of the file, the environment and the flags.
+
+They accept the flags of the migrate command, but the UUID and the dry run.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and the source of each value, with the secrets masked.",
+	RunE:  ConfigShowCmdRunE,
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the configuration file to the latest version of its format.",
+	RunE:  ConfigMigrateCmdRunE,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration, then check the chain node, the bank key, the token denoms and the remote database login.",
+	RunE:  ConfigValidateCmdRunE,
+}
+
+func init() {
+	SetupConfigCmdFlags(configCmd, migrateCmd)
+	configCmd.AddCommand(configShowCmd, configValidateCmd, configMigrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// SetupConfigCmdFlags shares the flags of the migrate command with the config commands, but the UUID and the dry run.
+// The flags are bound to the same configuration keys, whichever command is run.
+func SetupConfigCmdFlags(command *cobra.Command, migrateCommand *cobra.Command) {
+	migrateCommand.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "uuid" && flag.Name != "dry-run" {
+			command.PersistentFlags().AddFlag(flag)
+		}
+	})
+}
+
+func ConfigShowCmdRunE(cmd *cobra.Command, args []string) error {
+	keys := viper.AllKeys()
+	slices.Sort(keys)
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, key := range keys {
+		value, err := formatValue(redact(key, viper.Get(key)))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, configSource(cmd.Flags(), key))
+	}
+	return w.Flush()
+}
+
+// configSource returns where the value of the key comes from, following the precedence of viper.
+func configSource(flags *pflag.FlagSet, key string) string {
+	// Environment variables are the upper case keys, see viper.AutomaticEnv
+	flagName, env := key, strings.ToUpper(key)
+	if alias, ok := keyAliases[key]; ok {
+		flagName, env = alias.flag, alias.env
+	}
+
+	if flag := flags.Lookup(flagName); flag != nil && flag.Changed {
+		return SourceFlag
+	}
+	if os.Getenv(env) != "" {
+		return SourceEnv
+	}
+	if profile := viper.GetString("profile"); profile != "" && viper.InConfig(profilesKey+"."+profile+"."+key) {
+		return SourceProfile
+	}
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// LoadConfigFile reads the configuration file in ./ or /config, if any. The file is decoded strictly and migrated to the
+// latest version of its format in memory, `config migrate` migrates the file itself.
+func LoadConfigFile() error {
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			slog.Info("No config file found")
+			return nil
+		}
+		return errors.WithMessage(err, "could not read config file")
+	}
+
+	path := viper.ConfigFileUsed()
+	slog.Info("Using config file", "file", path)
+	_, settings, version, err := configfile.Read(path)
+	if err != nil {
+		return err
+	}
+	if version < configfile.CurrentVersion {
+		slog.Warn("Outdated config file format, run `config migrate` to migrate the file", "file", path, "version", version, "latestVersion", configfile.CurrentVersion)
+	}
+
+	// Replace the settings of the file with the migrated settings
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	viper.SetConfigType("json")
+	err = viper.ReadConfig(bytes.NewReader(data))
+	// Read the file with its own format again next time
+	viper.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+	return err
+}
+
+func ConfigMigrateCmdRunE(cmd *cobra.Command, args []string) error {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return fmt.Errorf("no config file found")
+	}
+
+	_, settings, version, err := configfile.Read(path)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if version == configfile.CurrentVersion {
+		fmt.Fprintf(out, "%s is already at version %d\n", path, version)
+		return nil
+	}
+
+	backup, err := configfile.Write(path, settings)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Migrated %s from version %d to version %d, the previous file is saved as %s\n", path, version, configfile.CurrentVersion, backup)
+	return nil
+}
+
+// redact masks the value of a secret key, and the secrets nested in the value.
+func redact(key string, value any) any {
+	if slices.Contains(secretKeys, key[strings.LastIndex(key, ".")+1:]) {
+		if value == nil || value == "" {
+			return value
+		}
+		return secrets.Mask
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for k, nested := range v {
+			redacted[k] = redact(k, nested)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, nested := range v {
+			redacted[i] = redact("", nested)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// formatValue formats scalar values as is, and lists and maps as JSON.
+func formatValue(value any) (string, error) {
+	switch value.(type) {
+	case nil:
+		return "", nil
+	case map[string]any, []any, []string:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// checks runs the validation checks and reports their outcome.
+type checks struct {
+	w      io.Writer
+	failed int
+}
+
+// run runs the check and reports its outcome. The check is skipped if one of the checks it requires failed.
+func (c *checks) run(name string, requires []error, check func() (string, error)) error {
+	for _, err := range requires {
+		if err != nil {
+			fmt.Fprintf(c.w, "%s\tskipped\t\n", name)
+			return err
+		}
+	}
+
+	detail, err := check()
+	if err != nil {
+		c.failed++
+		fmt.Fprintf(c.w, "%s\tFAIL\t%s\n", name, err)
+		return err
+	}
+	fmt.Fprintf(c.w, "%s\tok\t%s\n", name, detail)
+	return nil
+}
+
+func ConfigValidateCmdRunE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	c := &checks{w: w}
+
+	// Static checks of every configuration
+	conf := LoadConfigFromCLI("")
+	confErr := c.run("url", nil, func() (string, error) {
+		return conf.Url, conf.Validate()
+	})
+
+	httpConfig := LoadHttpConfigFromCLI()
+	httpErr := c.run("http", nil, func() (string, error) {
+		return "", httpConfig.Validate()
+	})
+
+	authConfig, loadErr := LoadAuthConfigFromCLI()
+	authErr := c.run("credentials", nil, func() (string, error) {
+		if loadErr != nil {
+			return "", loadErr
+		}
+		return authConfig.Username, authConfig.Validate()
+	})
+
+	migrateConfig, loadErr := LoadMigrationConfigFromCLI()
+	migrateErr := c.run("migrate", nil, func() (string, error) {
+		if loadErr != nil {
+			return "", loadErr
+		}
+		return "", migrateConfig.Validate()
+	})
+
+	_ = c.run("notify", nil, func() (string, error) {
+		notifyConfig, err := LoadNotifyConfigFromCLI()
+		if err != nil {
+			return "", err
+		}
+		return "", notifyConfig.Validate()
+	})
+
+	_ = c.run("neighborhoods", nil, func() (string, error) {
+		neighborhoods, err := LoadNeighborhoodsFromCLI()
+		if err != nil {
+			return "", err
+		}
+		if err := config.ValidateNeighborhoods(neighborhoods); err != nil {
+			return "", err
+		}
+		baseConfig, err := loadBaseMigrationConfig()
+		if err != nil {
+			return "", err
+		}
+		for _, n := range neighborhoods {
+			if err := n.Apply(baseConfig).Validate(); err != nil {
+				return "", errors.WithMessagef(err, "neighborhood %d", n.ID)
+			}
+		}
+		return fmt.Sprintf("%d neighborhood(s)", len(neighborhoods)), nil
+	})
+
+	// Checks against the chain
+	_ = c.run("node-address", []error{migrateErr}, func() (string, error) {
+		height, err := manifest.LatestHeight(ctx, migrateConfig)
+		return fmt.Sprintf("%s at height %d", migrateConfig.NodeAddress, height), err
+	})
+
+	_ = c.run("bank-address", []error{migrateErr}, func() (string, error) {
+		return manifest.KeyAddress(ctx, migrateConfig)
+	})
+
+	symbols := utils.GetKeys(migrateConfig.TokenMap)
+	slices.Sort(symbols)
+	for _, symbol := range symbols {
+		denom := migrateConfig.TokenMap[symbol].Denom
+		_ = c.run("token-map."+symbol, []error{migrateErr}, func() (string, error) {
+			supply, err := manifest.GetDenomSupply(ctx, migrateConfig, denom)
+			if err != nil {
+				return "", err
+			}
+			if supply.Sign() == 0 {
+				return "", fmt.Errorf("denom %s not found on chain", denom)
+			}
+			return fmt.Sprintf("%s, supply %s", denom, supply), nil
+		})
+	}
+
+	// Check against the remote database
+	_ = c.run("login", []error{confErr, httpErr, authErr}, func() (string, error) {
+		r, err := CreateRestClient(ctx, conf.Url, conf.Neighborhood, httpConfig)
+		if err != nil {
+			return "", err
+		}
+		return authConfig.Username, AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password)
+	})
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if c.failed > 0 {
+		return fmt.Errorf("%d configuration check(s) failed", c.failed)
+	}
+	return nil
+}
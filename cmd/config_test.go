@@ -0,0 +1,232 @@
+package cmd_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils/fakechain"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+func configCommand(runE func(*cobra.Command, []string) error) *cobra.Command {
+	migrate := &cobra.Command{Use: "migrate"}
+	command := &cobra.Command{Use: "config", RunE: runE}
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupMigrateCmdFlags(migrate)
+	cmd.SetupConfigCmdFlags(command, migrate)
+	return command
+}
+
+// configLines returns the fields of the lines of the output, by first field.
+func configLines(out string) map[string][]string {
+	lines := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines[fields[0]] = fields[1:]
+		}
+	}
+	return lines
+}
+
+func TestConfigShowCmd(t *testing.T) {
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+chain-id: manifest-2
+notify:
+  webhooks:
+    - url: https://hooks.example.com/migrator
+      secret: webhook-secret
+`)))
+	t.Cleanup(func() { require.NoError(t, viper.ReadConfig(strings.NewReader(""))) })
+	t.Setenv("NEIGHBORHOOD", "7")
+
+	command := configCommand(cmd.ConfigShowCmdRunE)
+	var out bytes.Buffer
+	command.SetOut(&out)
+	command.SetArgs([]string{"--gas-denom", "uatom", "--password", "flag-password"})
+	require.NoError(t, command.Execute())
+	t.Log(out.String())
+
+	lines := configLines(out.String())
+	require.Equal(t, []string{"test", "default"}, lines["keyring-backend"])
+	require.Equal(t, []string{"manifest-2", "file"}, lines["chain-id"])
+	require.Equal(t, []string{"7", "env"}, lines["neighborhood"])
+	require.Equal(t, []string{"uatom", "flag"}, lines["gas-denom"])
+	require.Equal(t, []string{"***", "flag"}, lines["password"])
+	require.Equal(t, []string{`[{"secret":"***","url":"https://hooks.example.com/migrator"}]`, "file"}, lines["notify.webhooks"])
+	require.NotContains(t, out.String(), "flag-password")
+	require.NotContains(t, out.String(), "webhook-secret")
+}
+
+func TestConfigValidateCmd(t *testing.T) {
+	previous := viper.Get("token-map")
+	t.Cleanup(func() { viper.Set("token-map", previous) })
+
+	status := fakechain.Command{Pattern: "'status '*", Response: fakechain.Response{Stdout: `{"sync_info":{"latest_block_height":"1234"}}`}}
+	key := fakechain.Command{Pattern: "'keys show bank --address '*", Response: fakechain.Response{Stdout: "manifest1hj5fveer5cjtn4wd6wstzugjfdxzl0xp8ws9ct\n"}}
+	supply := fakechain.Command{Pattern: "'q bank total-supply-of umfx '*", Response: fakechain.Response{Stdout: `{"amount":{"denom":"umfx","amount":"1000000"}}`}}
+	noSupply := fakechain.Command{Pattern: "'q bank total-supply-of '*", Response: fakechain.Response{Stdout: `{"amount":{"denom":"ufoo","amount":"0"}}`}}
+	unreachable := fakechain.Command{Pattern: "'status '*", Response: fakechain.Response{Stderr: "connection refused", Exit: 1}}
+	noKey := fakechain.Command{Pattern: "'keys show '*", Response: fakechain.Response{Stderr: "bank is not a valid name or address", Exit: 1}}
+
+	tt := []struct {
+		name     string
+		commands []fakechain.Command
+		tokenMap map[string]utils.TokenInfo
+		args     []string
+		// Neighborhoods served by the migrator
+		neighborhoods []map[string]any
+		err           string
+		expected      map[string]string // Result by check
+	}{
+		{name: "valid", commands: []fakechain.Command{status, key, supply}, expected: map[string]string{
+			"url": "ok", "http": "ok", "credentials": "ok", "migrate": "ok", "notify": "ok", "neighborhoods": "ok",
+			"node-address": "ok", "bank-address": "ok", "token-map.dummy": "ok", "login": "ok",
+		}},
+		{name: "unknown denom", commands: []fakechain.Command{status, key, supply, noSupply},
+			tokenMap: map[string]utils.TokenInfo{"foo": {Denom: "ufoo"}}, err: "1 configuration check(s) failed",
+			expected: map[string]string{"token-map.dummy": "ok", "token-map.foo": "FAIL"}},
+		{name: "node unreachable", commands: []fakechain.Command{unreachable, key, supply},
+			err: "1 configuration check(s) failed", expected: map[string]string{"node-address": "FAIL", "bank-address": "ok"}},
+		{name: "missing key", commands: []fakechain.Command{status, noKey, supply},
+			err: "1 configuration check(s) failed", expected: map[string]string{"bank-address": "FAIL"}},
+		{name: "wrong password", commands: []fakechain.Command{status, key, supply}, args: []string{"--password", "wrong"},
+			err: "1 configuration check(s) failed", expected: map[string]string{"credentials": "ok", "login": "FAIL"}},
+		{name: "invalid migrate config", commands: []fakechain.Command{status, key, supply}, args: []string{"--fee-granter", ""},
+			err: "1 configuration check(s) failed", expected: map[string]string{
+				"migrate": "FAIL", "node-address": "skipped", "bank-address": "skipped", "token-map.dummy": "skipped", "login": "ok",
+			}},
+		{name: "invalid neighborhood", commands: []fakechain.Command{status, key, supply}, neighborhoods: []map[string]any{{"id": 1, "max-attempts": 0}},
+			err: "1 configuration check(s) failed", expected: map[string]string{"neighborhoods": "FAIL", "migrate": "ok"}},
+		{name: "invalid http config", commands: []fakechain.Command{status, key, supply}, args: []string{"--http-timeout", "0"},
+			err: "1 configuration check(s) failed", expected: map[string]string{"http": "FAIL", "node-address": "ok", "login": "skipped"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenMap := map[string]utils.TokenInfo{"dummy": {Denom: "umfx"}}
+			for symbol, info := range tc.tokenMap {
+				tokenMap[symbol] = info
+			}
+			viper.Set("token-map", tokenMap)
+			setNeighborhoods(t, tc.neighborhoods)
+
+			server := httptest.NewServer(faketalib.New("user", "pass"))
+			defer server.Close()
+
+			binary := fakechain.New(t, tc.commands...)
+			command := configCommand(cmd.ConfigValidateCmdRunE)
+			var out bytes.Buffer
+			command.SetOut(&out)
+			command.SetArgs(append([]string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-count", "0",
+				"--chain-home", "/tmp", "--fee-granter", "feegranter", "--binary", binary.Path}, tc.args...))
+			err := command.Execute()
+			t.Log(out.String())
+
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			lines := configLines(out.String())
+			for check, result := range tc.expected {
+				require.NotEmpty(t, lines[check], "check %s", check)
+				require.Equal(t, result, lines[check][0], "check %s", check)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Cleanup(func() {
+		viper.SetConfigType("yaml")
+		require.NoError(t, viper.ReadConfig(strings.NewReader("")))
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "migrator-config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("logLevel: warn\nchain-id: manifest-2\n"), 0o600))
+	viper.SetConfigFile(path)
+
+	// The file is migrated in memory
+	require.NoError(t, cmd.LoadConfigFile())
+	require.Equal(t, "warn", viper.GetString("log-level"))
+	require.Equal(t, "manifest-2", viper.GetString("chain-id"))
+
+	// The file itself is migrated on demand
+	command := configCommand(cmd.ConfigMigrateCmdRunE)
+	var out bytes.Buffer
+	command.SetOut(&out)
+	require.NoError(t, command.Execute())
+	require.Contains(t, out.String(), "from version 1 to version 2")
+	require.FileExists(t, path+".bak")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "log-level: warn")
+	require.Contains(t, string(data), "version: 2")
+
+	out.Reset()
+	require.NoError(t, command.Execute())
+	require.Contains(t, out.String(), "already at version 2")
+
+	// Unknown keys are rejected
+	require.NoError(t, os.WriteFile(path, []byte("version: 2\ngas-prices: 0.01\n"), 0o600))
+	require.ErrorContains(t, cmd.LoadConfigFile(), "invalid config file "+path+": unknown key(s): gas-prices")
+}
+
+func TestLoadMigrationConfigFromCLI_Strict(t *testing.T) {
+	previous := viper.Get("token-map")
+	t.Cleanup(func() { viper.Set("token-map", previous) })
+
+	viper.Set("token-map", map[string]any{"dummy": map[string]any{"demon": "umfx"}})
+	_, err := cmd.LoadMigrationConfigFromCLI()
+	require.ErrorContains(t, err, "invalid token-map configuration")
+	require.ErrorContains(t, err, "demon")
+}
+
+func TestLoadAuthConfigFromCLI_Environment(t *testing.T) {
+	dir := t.TempDir()
+	usernameFile, passwordFile := filepath.Join(dir, "username"), filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(usernameFile, []byte("file-user\n"), 0o600))
+	require.NoError(t, os.WriteFile(passwordFile, []byte("file-pass\n"), 0o600))
+
+	command := &cobra.Command{Use: "claim"}
+	cmd.SetupRootCmdFlags(command)
+	require.NoError(t, command.ParseFlags([]string{"--username-file", usernameFile, "--password-file", passwordFile}))
+
+	// Empty environment variables are ignored
+	for _, env := range []string{"USERNAME", "PASSWORD", "TALIB_USERNAME", "TALIB_PASSWORD"} {
+		t.Setenv(env, "")
+	}
+	auth, err := cmd.LoadAuthConfigFromCLI()
+	require.NoError(t, err)
+	require.Equal(t, config.AuthConfig{Username: "file-user", Password: "file-pass"}, auth)
+
+	// The deprecated environment variables are still read
+	t.Setenv("USERNAME", "env-user")
+	t.Setenv("PASSWORD", "env-pass")
+	auth, err = cmd.LoadAuthConfigFromCLI()
+	require.NoError(t, err)
+	require.Equal(t, config.AuthConfig{Username: "env-user", Password: "env-pass"}, auth)
+
+	// The prefixed environment variables take precedence
+	t.Setenv("TALIB_USERNAME", "talib-user")
+	t.Setenv("TALIB_PASSWORD", "talib-pass")
+	auth, err = cmd.LoadAuthConfigFromCLI()
+	require.NoError(t, err)
+	require.Equal(t, config.AuthConfig{Username: "talib-user", Password: "talib-pass"}, auth)
+}
@@ -0,0 +1,210 @@
+//go:build failpoint
+
+package cmd_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/failpoint"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+// TestMigrateCmd_Crash stops the migration at every failpoint, by crashing or failing, then runs the migration again.
+// The tokens must be sent exactly once, unless the work item failed before sending them, and the remote and local
+// states must agree.
+func TestMigrateCmd_Crash(t *testing.T) {
+	type outcome struct {
+		status       store.WorkItemStatus
+		payouts      int
+		intervention bool // The resumed migration requires an intervention
+	}
+
+	type testCase struct {
+		name     string
+		args     []string
+		setup    func(talib *faketalib.Server, binary string)
+		teardown func()
+		resume   func() // Run before resuming the migration, if any
+		expected outcome
+	}
+
+	var tt []testCase
+	for _, point := range failpoint.Points {
+		tt = append(tt, testCase{
+			name:     "crash " + point,
+			setup:    func(*faketalib.Server, string) { failpoint.Enable(point, failpoint.Crash) },
+			teardown: func() { failpoint.Disable(point) },
+			expected: outcome{status: store.COMPLETED, payouts: 1},
+		})
+
+		// Errors before sending the tokens or once the hash is recorded are retried, errors after the transaction is
+		// included require an intervention
+		expected := outcome{status: store.FAILED, payouts: 1}
+		if point == failpoint.AfterMigrating || point == failpoint.AfterBroadcast || point == failpoint.AfterCompleted {
+			expected = outcome{status: store.COMPLETED, payouts: 1}
+		}
+		tt = append(tt, testCase{
+			name:     "error " + point,
+			setup:    func(*faketalib.Server, string) { failpoint.Enable(point, failpoint.Error) },
+			teardown: func() { failpoint.Disable(point) },
+			expected: expected,
+		})
+	}
+
+	// The remote database processes the update, but the response is lost
+	// The updates are not retried, the first one sets the work item as MIGRATING, the second one as COMPLETED
+	for after, status := range []store.WorkItemStatus{store.MIGRATING, store.COMPLETED} {
+		tt = append(tt, testCase{
+			name: "lost " + status.String() + " update response",
+			args: []string{"--http-retry-count", "0"},
+			setup: func(talib *faketalib.Server, _ string) {
+				talib.Inject(faketalib.Fault{Route: faketalib.RouteUpdate, Status: http.StatusBadGateway, After: after, Times: 1, Processed: true})
+			},
+			teardown: func() {},
+			expected: outcome{status: store.COMPLETED, payouts: 1},
+		})
+	}
+
+	// The transaction was broadcast, but it can be neither found nor ruled out when the migration is resumed
+	tt = append(tt, testCase{
+		name: "crash " + failpoint.AfterBroadcast + " before indexing",
+		setup: func(_ *faketalib.Server, binary string) {
+			failpoint.Enable(failpoint.AfterBroadcast, failpoint.Crash)
+			require.NoError(t, os.WriteFile(binary+".unindexed", nil, 0o600))
+		},
+		teardown: func() { failpoint.Disable(failpoint.AfterBroadcast) },
+		expected: outcome{status: store.FAILED, payouts: 1, intervention: true},
+	})
+
+	// The confirmation depth is not reached in time: the work item is left MIGRATING and the next run completes it
+	var heightFile string
+	tt = append(tt, testCase{
+		name: "confirmation timeout",
+		args: []string{"--confirmation-depth", "1", "--wait-for-block-timeout", "1"},
+		setup: func(_ *faketalib.Server, binary string) {
+			heightFile = binary + ".height"
+			require.NoError(t, os.WriteFile(heightFile, []byte("42"), 0o600))
+		},
+		teardown: func() {},
+		resume:   func() { require.NoError(t, os.WriteFile(heightFile, []byte("43"), 0o600)) },
+		expected: outcome{status: store.COMPLETED, payouts: 1},
+	})
+
+	// The token is no longer mapped when the migration is resumed: the previous transfer completes the work item, and
+	// without transfer the work item requires an intervention rather than failing, as the tokens may have been sent
+	unmap := func() { viper.Set("token-map", map[string]utils.TokenInfo{}) }
+	for point, expected := range map[string]outcome{
+		failpoint.AfterMigrating: {status: store.FAILED, intervention: true},
+		failpoint.AfterBroadcast: {status: store.COMPLETED, payouts: 1},
+	} {
+		tt = append(tt, testCase{
+			name:     "crash " + point + " and unmap token",
+			setup:    func(*faketalib.Server, string) { failpoint.Enable(point, failpoint.Crash) },
+			teardown: func() { failpoint.Disable(point) },
+			resume:   unmap,
+			expected: expected,
+		})
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.Chdir(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			viper.Set("token-map", map[string]utils.TokenInfo{
+				testutils.ManySymbol: {Denom: "umfx"},
+			})
+
+			talib := faketalib.New("user", "pass")
+			server := httptest.NewServer(talib)
+			defer server.Close()
+
+			binary := filepath.Join(t.TempDir(), "manifestd")
+			require.NoError(t, os.WriteFile(binary, []byte(ledgerBinary), 0o755))
+
+			args := []string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-wait", "1ms"}
+			item := talib.Add(faketalib.Migration{
+				From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+			})
+
+			command := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupClaimCmdFlags(command)
+			_, err := testutils.Execute(t, command, args...)
+			require.NoError(t, err)
+
+			migrate := func(extra ...string) (crashed bool, err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						if _, ok := r.(failpoint.Crashed); !ok {
+							panic(r)
+						}
+						crashed = true
+					}
+				}()
+
+				command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+				cmd.SetupRootCmdFlags(command)
+				cmd.SetupMigrateCmdFlags(command)
+				command.SetArgs(append([]string{"--uuid", item.UUID.String(), "--chain-home", "/tmp", "--fee-granter", "feegranter",
+					"--binary", binary, "--retry-backoff", "1ns"}, append(args, extra...)...))
+				return false, command.Execute()
+			}
+
+			// Stop the migration, then run it again
+			tc.setup(talib, binary)
+			crashed, err := migrate(tc.args...)
+			tc.teardown()
+			require.True(t, crashed || err != nil, "the migration did not stop")
+
+			if local, lErr := store.LoadState(context.Background(), item.UUID.String()); lErr == nil && local.Status != store.FAILED {
+				if tc.resume != nil {
+					tc.resume()
+				}
+				crashed, err = migrate()
+				require.False(t, crashed)
+				if tc.expected.intervention {
+					require.Equal(t, errclass.Intervention, errclass.Of(err), "error: %v", err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+
+			ledger, err := os.ReadFile(binary + ".ledger")
+			require.NoError(t, err)
+			require.Len(t, strings.Fields(string(ledger)), 4*tc.expected.payouts, "ledger: %s", ledger)
+			if tc.expected.payouts > 0 {
+				require.Contains(t, string(ledger), item.UUID.String())
+			}
+
+			remote, ok := talib.Item(item.UUID)
+			require.True(t, ok)
+			require.Equal(t, tc.expected.status, remote.Status)
+
+			local, err := store.LoadState(context.Background(), item.UUID.String())
+			if remote.Status == store.COMPLETED {
+				require.Error(t, err, "the local state of a completed work item is deleted")
+				require.Equal(t, "TX0001", *remote.ManifestHash)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, remote.Equal(*local), "remote: %v, local: %v", remote, local)
+		})
+	}
+}
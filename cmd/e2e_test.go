@@ -0,0 +1,108 @@
+package cmd_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+// TestClaimAndMigrate claims and migrates work items from a fake talib, then checks their remote and local states.
+func TestClaimAndMigrate(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("token-map", map[string]utils.TokenInfo{
+		testutils.ManySymbol: {Denom: "umfx"},
+	})
+
+	talib := faketalib.New("user", "pass")
+	server := httptest.NewServer(talib)
+	defer server.Close()
+
+	binary := filepath.Join(t.TempDir(), "manifestd")
+	require.NoError(t, os.WriteFile(binary, []byte(fakeBinary), 0o755))
+
+	args := []string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-wait", "1ms"}
+	migrateArgs := append([]string{"--chain-home", "/tmp", "--fee-granter", "feegranter", "--binary", binary}, args...)
+
+	claim := func() error {
+		command := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupClaimCmdFlags(command)
+		_, err := testutils.Execute(t, command, args...)
+		return err
+	}
+	migrate := func(itemUUID uuid.UUID) error {
+		command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupMigrateCmdFlags(command)
+		_, err := testutils.Execute(t, command, append([]string{"--uuid", itemUUID.String()}, migrateArgs...)...)
+		return err
+	}
+
+	allowed := talib.Add(faketalib.Migration{
+		From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+	})
+	denied := talib.Add(faketalib.Migration{
+		From: "maeyvfsdssk3nuxi7cxbnilvdmjpcywxxqhbx5dnl2z5wvgpdu4", ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol,
+	})
+
+	// An unavailable remote database is retried by the HTTP client
+	talib.Inject(faketalib.Fault{Route: faketalib.RouteUpdate, Status: http.StatusServiceUnavailable, Times: 1})
+
+	require.NoError(t, claim())
+	require.NoError(t, migrate(allowed.UUID))
+	require.NoError(t, claim())
+	require.ErrorContains(t, migrate(denied.UUID), "not allowed to migrate")
+	require.NoError(t, claim())
+
+	// The state of the completed work item is only kept remotely
+	remote, ok := talib.Item(allowed.UUID)
+	require.True(t, ok)
+	require.Equal(t, store.COMPLETED, remote.Status)
+	require.Equal(t, "8F5E3B9A0C1D2E3F", *remote.ManifestHash)
+	require.Equal(t, "2024-06-01T12:00:00.123Z", remote.ManifestDatetime.Format("2006-01-02T15:04:05.000Z"))
+	require.NoFileExists(t, allowed.UUID.String()+".json")
+
+	// The policy decisions and the confirmation outlive the state file
+	entries, err := store.ReadAuditLog(context.Background())
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, store.PolicyEvaluated, entries[0].Event)
+	require.Equal(t, allowed.UUID, entries[0].UUID)
+	require.Equal(t, "allow", entries[0].Policy.Action)
+	require.Equal(t, store.TransferConfirmed, entries[1].Event)
+	require.Equal(t, allowed.UUID, entries[1].UUID)
+	require.Equal(t, "8F5E3B9A0C1D2E3F", entries[1].TxHash)
+	require.NotNil(t, entries[1].Confirmation)
+
+	// The whitelist rejection is recorded like a policy decision
+	require.Equal(t, store.PolicyEvaluated, entries[2].Event)
+	require.Equal(t, denied.UUID, entries[2].UUID)
+	require.Equal(t, policy.WhitelistRuleName, entries[2].Policy.Rule)
+	require.Equal(t, "reject", entries[2].Policy.Action)
+	require.Contains(t, entries[2].Policy.Reason, "not allowed to migrate")
+
+	remote, ok = talib.Item(denied.UUID)
+	require.True(t, ok)
+	require.Equal(t, store.FAILED, remote.Status)
+	local, err := store.LoadState(context.Background(), denied.UUID.String())
+	require.NoError(t, err)
+	require.True(t, remote.Equal(*local), "remote: %v, local: %v", remote, local)
+}
@@ -1,6 +1,36 @@
 package cmd
 
+import "github.com/pkg/errors"
+
 const (
 	ErrorBindingFlag         = "could not bind flags"
 	ErrorMarkingFlagRequired = "could not mark flag required"
 )
+
+// errPolicyHold is returned when the eligibility policy puts a migration on hold.
+var errPolicyHold = errors.New("migration on hold by policy")
+
+// errSendsPaused is returned when the sends of tokens are paused by an operator, see serve.
+var errSendsPaused = errors.New("sends paused by an operator")
+
+// errKillSwitch is returned when the kill switch is engaged, see checkKillSwitch.
+var errKillSwitch = errors.New("kill switch engaged")
+
+// ruleError is the rejection of a migration by a built-in eligibility rule, see policy.WhitelistRuleName.
+type ruleError struct {
+	rule string
+	err  error
+}
+
+// rejectedBy returns the error of the rejection of a migration by the built-in eligibility rule.
+func rejectedBy(rule string, err error) error {
+	return &ruleError{rule: rule, err: err}
+}
+
+func (e *ruleError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ruleError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,4 @@
+package cmd
+
+// RootCmd is the root command, with every subcommand set up.
+var RootCmd = rootCmd
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/manifest"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
+)
+
+// killSwitch is the kill switch flag of the remote database.
+type killSwitch struct {
+	Engaged bool   `json:"engaged"`
+	Reason  string `json:"reason"`
+}
+
+// checkKillSwitch checks every configured source of the kill switch, right before broadcasting a transfer of the denom.
+// It returns an error wrapping errKillSwitch if a source engages the kill switch, or cannot be checked: the kill switch
+// fails closed, nothing is sent unless every source is known to be released.
+func checkKillSwitch(ctx context.Context, r *resty.Client, config config.MigrateConfig, denom string) (err error) {
+	ctx, span := tracing.Start(ctx, "checkKillSwitch")
+	defer func() { tracing.End(span, err) }()
+
+	if file := config.KillSwitch.File; file != "" {
+		content, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			return engaged(withReason("sentinel file "+file+" exists", string(content)))
+		case !errors.Is(err, os.ErrNotExist):
+			return engaged(errors.WithMessagef(err, "could not check sentinel file %s", file).Error())
+		}
+	}
+
+	if config.KillSwitch.Talib {
+		flag, err := getKillSwitch(ctx, r)
+		if err != nil {
+			return engaged(errors.WithMessage(err, "could not check remote database flag").Error())
+		}
+		if flag.Engaged {
+			return engaged(withReason("remote database flag set", flag.Reason))
+		}
+	}
+
+	if config.KillSwitch.Chain {
+		enabled, err := manifest.IsSendEnabled(ctx, config, denom)
+		if err != nil {
+			return engaged(errors.WithMessagef(err, "could not check send enabled parameter of %s", denom).Error())
+		}
+		if !enabled {
+			return engaged("send disabled on chain for " + denom)
+		}
+	}
+
+	return nil
+}
+
+// engaged returns a transient error wrapping errKillSwitch, with the reason the kill switch is engaged.
+func engaged(reason string) error {
+	return errclass.Wrap(errclass.Transient, errors.WithMessage(errKillSwitch, reason))
+}
+
+// withReason appends the reason given by the operator engaging the kill switch to the message, if any.
+func withReason(message, reason string) string {
+	if reason = strings.TrimSpace(reason); reason != "" {
+		return message + " (" + reason + ")"
+	}
+	return message
+}
+
+// getKillSwitch gets the kill switch flag of the remote database, shared by every neighborhood.
+func getKillSwitch(ctx context.Context, r *resty.Client) (*killSwitch, error) {
+	resp, err := r.R().
+		SetContext(ctx).
+		Get("kill-switch")
+	if err != nil {
+		return nil, errors.WithMessage(errclass.Request(err), "error getting kill switch")
+	}
+
+	if resp == nil {
+		return nil, errclass.Transientf("no response returned when getting kill switch")
+	}
+
+	statusCode := resp.StatusCode()
+	if statusCode != 200 {
+		return nil, errclass.StatusCode(statusCode)
+	}
+
+	var flag killSwitch
+	if err := json.Unmarshal(resp.Body(), &flag); err != nil {
+		return nil, errors.WithMessage(err, "error unmarshalling kill switch")
+	}
+	return &flag, nil
+}
@@ -0,0 +1,216 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/notify"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+// ledgerBinary is a chain binary keeping the sent transactions in a ledger, one `<hash> <to> <amount> <memo>` line
+// per transaction, included at block 42 as soon as they are sent. The transactions cannot be looked up nor searched
+// while the `.unindexed` file next to the binary exists, as if they were not indexed yet. The bank send-enabled
+// parameter of every denom is read from the `.send-enabled` file next to the binary, true if there is none, and the
+// latest block height from the `.height` file, 42 if there is none.
+const ledgerBinary = `#!/bin/sh
+ledger="$0.ledger"
+touch "$ledger"
+for arg in "$@"; do
+  if [ "$arg" = "--dry-run" ]; then
+    echo "gas estimate: 81234" >&2
+    exit 0
+  fi
+done
+tx() {
+  printf '{"txhash":"%s","height":"42","code":0,"tx":{"body":{"memo":"%s"}},"events":[' "$1" "$4"
+  printf '{"type":"coin_received","attributes":[{"key":"receiver","value":"%s"},{"key":"amount","value":"%s"}]},' "$2" "$3"
+  printf '{"type":"transfer","attributes":[{"key":"recipient","value":"%s"},{"key":"amount","value":"%s"}]}]}' "$2" "$3"
+}
+case "$1 $2" in
+  "keys show") echo "manifest1hj5fveer5cjtn4wd6wstzugjfdxzl0xp8ws9ct"; exit 0 ;;
+  "tx bank")
+    memo=""; prev=""
+    for arg in "$@"; do
+      [ "$prev" = "--note" ] && memo="$arg"
+      prev="$arg"
+    done
+    hash=$(printf 'TX%04d' $(($(wc -l < "$ledger") + 1)))
+    echo "$hash $5 $6 $memo" >> "$ledger"
+    echo "{\"txhash\":\"$hash\",\"code\":0}"; exit 0 ;;
+  "q event-query-tx-for")
+    grep "^$3 " "$ledger" | { read -r hash to amount memo; tx "$hash" "$to" "$amount" "$memo"; }
+    exit 0 ;;
+  "q tx")
+    if [ ! -e "$0.unindexed" ] && grep -q "^$3 " "$ledger"; then
+      grep "^$3 " "$ledger" | { read -r hash to amount memo; tx "$hash" "$to" "$amount" "$memo"; }
+      exit 0
+    fi
+    echo "tx ($3) not found" >&2; exit 1 ;;
+  "q txs")
+    printf '{"txs":['
+    sep=""
+    [ -e "$0.unindexed" ] || while read -r hash to amount memo; do
+      printf '%s' "$sep"; tx "$hash" "$to" "$amount" "$memo"; sep=","
+    done < "$ledger"
+    printf ']}\n'; exit 0 ;;
+  "status "*)
+    printf '{"sync_info":{"latest_block_height":"%s"}}\n' "$(cat "$0.height" 2>/dev/null || echo 42)"; exit 0 ;;
+  "q block") echo '{"header":{"time":"2024-06-01T12:00:00.123Z"}}'; exit 0 ;;
+  "q bank")
+    enabled=$(cat "$0.send-enabled" 2>/dev/null || echo true)
+    printf '{"send_enabled":[{"denom":"%s","enabled":%s}]}\n' "$4" "$enabled"; exit 0 ;;
+esac
+echo "unexpected command: $*" >&2
+exit 1
+`
+
+// TestMigrateCmd_KillSwitch engages the kill switch from each of its sources before migrating a work item. The work item
+// must be left MIGRATING without sending the tokens nor recording an attempt, and an alert must fire. The migration
+// completes once the kill switch is released.
+func TestMigrateCmd_KillSwitch(t *testing.T) {
+	sentinel := func() string { return filepath.Join(t.TempDir(), "stop") }
+
+	tt := []struct {
+		name    string
+		args    func(binary, sentinel string) []string
+		engage  func(talib *faketalib.Server, binary, sentinel string)
+		release func(talib *faketalib.Server, binary, sentinel string)
+		err     string
+	}{
+		{
+			name: "sentinel file",
+			args: func(_, sentinel string) []string { return []string{"--kill-switch-file", sentinel} },
+			engage: func(_ *faketalib.Server, _, sentinel string) {
+				require.NoError(t, os.WriteFile(sentinel, []byte("incident 42\n"), 0o600))
+			},
+			release: func(_ *faketalib.Server, _, sentinel string) { require.NoError(t, os.Remove(sentinel)) },
+			err:     "exists (incident 42): kill switch engaged",
+		},
+		{
+			name:    "unreadable sentinel file",
+			args:    func(_, sentinel string) []string { return []string{"--kill-switch-file", sentinel} },
+			engage:  func(_ *faketalib.Server, _, sentinel string) { require.NoError(t, os.Mkdir(sentinel, 0o700)) },
+			release: func(_ *faketalib.Server, _, sentinel string) { require.NoError(t, os.Remove(sentinel)) },
+			err:     "could not check sentinel file",
+		},
+		{
+			name:    "remote database flag",
+			args:    func(_, _ string) []string { return []string{"--kill-switch-talib"} },
+			engage:  func(talib *faketalib.Server, _, _ string) { talib.SetKillSwitch(true, "incident 42") },
+			release: func(talib *faketalib.Server, _, _ string) { talib.SetKillSwitch(false, "") },
+			err:     "remote database flag set (incident 42): kill switch engaged",
+		},
+		{
+			name: "send disabled on chain",
+			args: func(_, _ string) []string { return []string{"--kill-switch-chain"} },
+			engage: func(_ *faketalib.Server, binary, _ string) {
+				require.NoError(t, os.WriteFile(binary+".send-enabled", []byte("false"), 0o600))
+			},
+			release: func(_ *faketalib.Server, binary, _ string) { require.NoError(t, os.Remove(binary+".send-enabled")) },
+			err:     "send disabled on chain for umfx: kill switch engaged",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.Chdir(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			viper.Set("token-map", map[string]utils.TokenInfo{
+				testutils.ManySymbol: {Denom: "umfx"},
+			})
+
+			var mu sync.Mutex
+			var alerts []notify.Payload
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload notify.Payload
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+				mu.Lock()
+				defer mu.Unlock()
+				alerts = append(alerts, payload)
+			}))
+			defer hook.Close()
+			viper.Set("notify", map[string]any{"webhooks": []map[string]any{{"url": hook.URL, "secret": "webhook-secret", "events": []string{string(notify.KillSwitchEngaged)}}}})
+			t.Cleanup(func() { viper.Set("notify", nil) })
+
+			talib := faketalib.New("user", "pass")
+			server := httptest.NewServer(talib)
+			defer server.Close()
+
+			binary := filepath.Join(t.TempDir(), "manifestd")
+			require.NoError(t, os.WriteFile(binary, []byte(ledgerBinary), 0o755))
+			path := sentinel()
+
+			args := []string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-wait", "1ms"}
+			item := talib.Add(faketalib.Migration{
+				From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+			})
+
+			claim := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+			cmd.SetupRootCmdFlags(claim)
+			cmd.SetupClaimCmdFlags(claim)
+			_, err := testutils.Execute(t, claim, args...)
+			require.NoError(t, err)
+
+			migrate := func() error {
+				command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+				cmd.SetupRootCmdFlags(command)
+				cmd.SetupMigrateCmdFlags(command)
+				command.SetArgs(append(append([]string{"--uuid", item.UUID.String(), "--chain-home", "/tmp", "--fee-granter", "feegranter",
+					"--binary", binary, "--retry-backoff", "1ns"}, args...), tc.args(binary, path)...))
+				return command.Execute()
+			}
+
+			// The kill switch stops the migration right before the broadcast, twice in a row
+			tc.engage(talib, binary, path)
+			for range 2 {
+				err = migrate()
+				require.ErrorContains(t, err, "kill switch engaged")
+				require.ErrorContains(t, err, tc.err)
+				require.Equal(t, errclass.Transient, errclass.Of(err))
+			}
+			ledger, err := os.ReadFile(binary + ".ledger")
+			require.NoError(t, err)
+			require.Empty(t, ledger, "no transaction is sent")
+
+			remote, ok := talib.Item(item.UUID)
+			require.True(t, ok)
+			require.Equal(t, store.MIGRATING, remote.Status)
+			local, err := store.LoadState(context.Background(), item.UUID.String())
+			require.NoError(t, err)
+			require.Equal(t, store.MIGRATING, local.Status)
+			require.Zero(t, local.Audit.Attempts)
+
+			mu.Lock()
+			require.Len(t, alerts, 2)
+			require.Equal(t, notify.KillSwitchEngaged, alerts[0].Event)
+			require.Equal(t, item.UUID, alerts[0].Item.UUID)
+			require.Contains(t, alerts[0].Error, tc.err)
+			mu.Unlock()
+
+			// The migration completes once the kill switch is released
+			tc.release(talib, binary, path)
+			require.NoError(t, migrate())
+			remote, ok = talib.Item(item.UUID)
+			require.True(t, ok)
+			require.Equal(t, store.COMPLETED, remote.Status)
+		})
+	}
+}
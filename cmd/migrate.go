@@ -1,21 +1,31 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log/slog"
 	"math/big"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/manifest-network/mfx-migrator/internal/admin"
 	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/failpoint"
 
 	"github.com/manifest-network/mfx-migrator/internal/many"
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+	"github.com/manifest-network/mfx-migrator/internal/notify"
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 	"github.com/manifest-network/mfx-migrator/internal/utils"
 
 	"github.com/manifest-network/mfx-migrator/internal/manifest"
@@ -29,65 +39,195 @@ var migrateCmd = &cobra.Command{
 	RunE:  MigrateCmdRunE,
 }
 
-func MigrateCmdRunE(cmd *cobra.Command, args []string) error {
+func MigrateCmdRunE(cmd *cobra.Command, args []string) (err error) {
 	c := LoadConfigFromCLI("migrate-uuid")
 	slog.Debug("args", "c", c)
 	if err := c.Validate(); err != nil {
 		return err
 	}
 
-	migrateConfig := LoadMigrationConfigFromCLI()
+	migrateConfig, err := LoadMigrationConfigFromCLI()
+	if err != nil {
+		return err
+	}
 	slog.Debug("args", "migrate-c", migrateConfig)
 	if err := migrateConfig.Validate(); err != nil {
 		return err
 	}
 
-	authConfig := LoadAuthConfigFromCLI()
+	notifyConfig, err := LoadNotifyConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	notifier, err := notify.New(notifyConfig)
+	if err != nil {
+		return errors.WithMessage(err, "invalid notification configuration")
+	}
+	defer notifier.Close()
+
+	authConfig, err := LoadAuthConfigFromCLI()
+	if err != nil {
+		return err
+	}
 	slog.Debug("args", "auth-c", authConfig)
 	if err := authConfig.Validate(); err != nil {
 		return err
 	}
 
+	httpConfig := LoadHttpConfigFromCLI()
+	slog.Debug("args", "http-c", httpConfig)
+	if err := httpConfig.Validate(); err != nil {
+		return err
+	}
+
+	ctx, err := stateContext(cmd.Context(), c.Neighborhood)
+	if err != nil {
+		return err
+	}
+
 	slog.Info("Loading state...", "uuid", c.UUID)
-	item, err := store.LoadState(c.UUID)
+	item, err := store.LoadState(ctx, c.UUID)
 	if err != nil {
 		return errors.WithMessage(err, "unable to load state")
 	}
 
-	if err := verifyItemStatus(item); err != nil {
+	// Continue the trace of the work item started when it was claimed
+	if item.Audit != nil {
+		ctx = tracing.Extract(ctx, item.Audit.TraceContext)
+	}
+	ctx, span := tracing.Start(ctx, "migrate", trace.WithAttributes(attribute.String("uuid", c.UUID)))
+	defer func() { tracing.End(span, err) }()
+
+	dryRunOnly := viper.GetBool("dry-run")
+	if done, err := checkWorkItem(ctx, item, migrateConfig, dryRunOnly); done {
+		return err
+	}
+
+	r, err := CreateRestClient(ctx, c.Url, c.Neighborhood, httpConfig)
+	if err != nil {
 		return err
 	}
-	r := CreateRestClient(cmd.Context(), c.Url, c.Neighborhood)
-	if err := AuthenticateRestClient(r, authConfig.Username, authConfig.Password); err != nil {
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
 		return err
 	}
 
-	if err := verifyManyAddressIsAllowed(item, r); err != nil {
-		// An unauthorized address scheduled a migration
-		// Mark the migration as failed
-		slog.Error("Migration failed", "error", err)
-		errStr := err.Error()
-		sErr := setAsFailed(r, *item, &errStr)
-		if sErr != nil {
+	// Show what would be sent, without changing anything
+	if dryRunOnly {
+		p, err := dryRun(ctx, r, item, migrateConfig)
+		if err != nil {
+			return err
+		}
+		printPlan(cmd.OutOrStdout(), item, p)
+		return nil
+	}
+
+	return migrateWorkItem(ctx, r, notifier, migrateConfig, item)
+}
+
+// checkWorkItem checks whether the migration of the work item can be attempted now. It returns true if it cannot, with
+// the reason as an error, or without error if a previous run completed the work item.
+func checkWorkItem(ctx context.Context, item *store.WorkItem, config config.MigrateConfig, dryRun bool) (bool, error) {
+	// A previous run completed the work item but stopped before deleting its state
+	if item.Status == store.COMPLETED && !dryRun {
+		slog.Info("Work item already completed", "uuid", item.UUID)
+		return true, deleteState(ctx, item)
+	}
+
+	if err := verifyItemStatus(item); err != nil {
+		return true, err
+	}
+
+	// The previous attempt failed temporarily, back off before trying again
+	if next := store.NextAttempt(item, config.RetryBackoff, config.RetryMaxBackoff); !dryRun && time.Now().Before(next) {
+		return true, errclass.Transientf("work item %s failed %d time(s), next attempt not before %s", item.UUID, item.Audit.Attempts, next.Format(time.RFC3339))
+	}
+
+	return false, nil
+}
+
+// migrateWorkItem migrates the claimed work item and records the outcome of the migration.
+func migrateWorkItem(ctx context.Context, r *resty.Client, notifier *notify.Notifier, migrateConfig config.MigrateConfig, item *store.WorkItem) error {
+	err := migrate(ctx, r, item, migrateConfig, notifier)
+
+	// The migration is on hold, leave the work item untouched but keep track of the policy decision
+	if errors.Is(err, errPolicyHold) {
+		slog.Warn("Migration on hold", "uuid", item.UUID, "error", err)
+		if sErr := store.SaveState(ctx, item); sErr != nil {
 			return errors.WithMessage(err, sErr.Error())
 		}
+		notifier.Notify(notify.Payload{Event: notify.PolicyHold, Item: item, Error: err.Error()})
+		return err
+	}
 
+	// The sends are paused, leave the work item untouched without recording an attempt
+	if errors.Is(err, errSendsPaused) {
+		slog.Info("Migration paused", "uuid", item.UUID)
 		return err
 	}
 
-	err = migrate(r, item, migrateConfig)
+	// The kill switch is engaged, leave the work item pending without recording an attempt and raise the alert
+	if errors.Is(err, errKillSwitch) {
+		slog.Error("Migration stopped by the kill switch", "uuid", item.UUID, "status", item.Status, "error", err)
+		notifier.Notify(notify.Payload{Event: notify.KillSwitchEngaged, Item: item, Error: err.Error()})
+		return err
+	}
+
+	// The work item was completed but its state could not be deleted, the next run deletes it
+	if err != nil && item.Status == store.COMPLETED {
+		return errclass.Wrap(errclass.Transient, err)
+	}
+
+	// The migration was interrupted, e.g. on shutdown, leave the work item as is so the next run resumes it
+	if err != nil && ctx.Err() != nil {
+		slog.Warn("Migration interrupted", "uuid", item.UUID, "status", item.Status, "error", err)
+		return errclass.Wrap(errclass.Transient, err)
+	}
 
 	// The migration failed for some reason, update the work item status and save the state
 	if err != nil {
-		slog.Error("Migration failed", "error", err)
-		errStr := err.Error()
-		sErr := setAsFailed(r, *item, &errStr)
-		if sErr != nil {
-			return errors.WithMessage(err, sErr.Error())
+		return handleFailure(ctx, r, notifier, migrateConfig, item, err)
+	}
+	return nil
+}
+
+// handleFailure handles a failed migration according to the class of the error.
+// Transient failures only record the attempt so the migration can be retried, until the maximum number of attempts is
+// reached. Other failures mark the work item as failed, and require an intervention if a previous attempt may have sent
+// the tokens. The returned error is always classified, so the command exits with the exit code of the class.
+func handleFailure(ctx context.Context, r *resty.Client, notifier *notify.Notifier, config config.MigrateConfig, item *store.WorkItem, err error) error {
+	class := errclass.Of(err)
+	if class == errclass.Terminal && item.Status == store.MIGRATING {
+		class = errclass.Intervention
+	}
+	err = errclass.Wrap(class, err)
+	attempts := store.RecordAttempt(item, time.Now().UTC())
+
+	if class == errclass.Transient {
+		if attempts < int(config.MaxAttempts) {
+			slog.Warn("Migration failed, it will be retried", "uuid", item.UUID, "attempt", attempts, "maxAttempts", config.MaxAttempts, "error", err)
+			if sErr := store.SaveState(ctx, item); sErr != nil {
+				return errors.WithMessage(err, sErr.Error())
+			}
+			return err
+		}
+
+		// Give up, the work item is rejected, unless a previous attempt may have sent the tokens
+		class = errclass.Terminal
+		if item.Status == store.MIGRATING {
+			class = errclass.Intervention
 		}
+		err = errclass.Wrap(class, errors.WithMessagef(err, "giving up after %d attempts", attempts))
 	}
-	return err
 
+	slog.Error("Migration failed", "class", class, "error", err)
+	errStr := err.Error()
+	if sErr := setAsFailed(ctx, r, item, &errStr, class); sErr != nil {
+		return errors.WithMessage(err, sErr.Error())
+	}
+	metrics.Failures.WithLabelValues(string(class)).Inc()
+	notifyFailure(notifier, item, err, class)
+
+	return err
 }
 
 func init() {
@@ -96,35 +236,39 @@ func init() {
 }
 
 // verifyManyAddressIsAllowed verifies that the manifest address is in the whitelist and allowed to migrate tokens.
-func verifyManyAddressIsAllowed(item *store.WorkItem, client *resty.Client) error {
-	txArgs, err := many.GetTxInfo(client, item.ManyHash)
+func verifyManyAddressIsAllowed(ctx context.Context, item *store.WorkItem, client *resty.Client) (err error) {
+	ctx, span := tracing.Start(ctx, "verifyManyAddressIsAllowed")
+	defer func() { tracing.End(span, err) }()
+
+	txArgs, err := many.GetTxInfo(ctx, client, item.ManyHash)
 	if err != nil {
 		return errors.WithMessage(err, "error getting MANY tx info")
 	}
 
 	resp, err := client.R().
+		SetContext(ctx).
 		SetPathParam("address", txArgs.From).
 		Get("migrations-whitelist/{address}")
 	if err != nil {
-		return errors.WithMessage(err, "error getting migration whitelisted addresses")
+		return errors.WithMessage(errclass.Request(err), "error getting migration whitelisted addresses")
 	}
 
 	if resp == nil {
-		return fmt.Errorf("no response returned when getting migration whitelisted addresses")
+		return errclass.Transientf("no response returned when getting migration whitelisted addresses")
 	}
 
 	statusCode := resp.StatusCode()
 	if statusCode != 200 {
-		return fmt.Errorf("response status code: %d", statusCode)
+		return errclass.StatusCode(statusCode)
 	}
 
 	var isAllowed bool
 	if err := json.Unmarshal(resp.Body(), &isAllowed); err != nil {
-		return errors.WithMessage(err, "error unmarshalling response")
+		return errors.WithMessage(errclass.Wrap(errclass.Terminal, err), "error unmarshalling response")
 	}
 
 	if !isAllowed {
-		return fmt.Errorf("address %s not allowed to migrate", txArgs.From)
+		return rejectedBy(policy.WhitelistRuleName, errclass.Terminalf("address %s not allowed to migrate", txArgs.From))
 	}
 
 	return nil
@@ -133,7 +277,7 @@ func verifyManyAddressIsAllowed(item *store.WorkItem, client *resty.Client) erro
 // verifyItemStatus verifies the status of the work item is valid for migration.
 func verifyItemStatus(item *store.WorkItem) error {
 	if !(item.Status == store.CLAIMED || item.Status == store.MIGRATING) {
-		return fmt.Errorf("work item status not valid for migration: %s, %s", item.UUID, item.Status)
+		return errclass.Terminalf("work item status not valid for migration: %s, %s", item.UUID, item.Status)
 	}
 	return nil
 }
@@ -141,7 +285,7 @@ func verifyItemStatus(item *store.WorkItem) error {
 // compareItems compares the local and remote work items to ensure they match.
 func compareItems(item *store.WorkItem, remoteItem *store.WorkItem) error {
 	if !item.Equal(*remoteItem) {
-		return fmt.Errorf("local and remote work items do not match: %s, %s", item.UUID, remoteItem.UUID)
+		return errclass.Terminalf("local and remote work items do not match: %s, %s", item.UUID, remoteItem.UUID)
 	}
 	return nil
 }
@@ -164,6 +308,8 @@ func setupStringCmdFlags(command *cobra.Command) {
 		{"binary", "binary", "manifestd", "Binary name of the blockchain to migrate to", false},
 		{"gas-denom", "gas-denom", "umfx", "Denomination of the gas price", false},
 		{"fee-granter", "fee-granter", "", "The address of the gas fee granter", false},
+		{"gas-price-source", "gas-price-source", config.GasPriceStatic, "Source of the gas price (static|min-gas-price|feemarket)", false},
+		{"kill-switch-file", "kill-switch-file", "", "Sentinel file stopping every send while it exists (disabled if empty)", false},
 	}
 
 	for _, arg := range args {
@@ -188,6 +334,9 @@ func setupUIntCmdFlags(command *cobra.Command) {
 	}{
 		{"wait-for-tx-timeout", "wait-for-tx-timeout", 15, "Number of seconds spent waiting for the transaction to be included in a block"},
 		{"wait-for-block-timeout", "wait-for-block-timeout", 30, "Number of seconds spent waiting for the block to be committed"},
+		{"max-attempts", "max-attempts", 5, "Number of attempts before a transiently failing migration is marked as failed"},
+		{"confirmation-depth", "confirmation-depth", 0, "Number of blocks past the inclusion height of the transaction before the migration is completed"},
+		{"max-fee", "max-fee", 0, "Maximum fee of a migration transaction, in base units of the gas denom (0 for no limit)"},
 	}
 
 	for _, arg := range args {
@@ -217,134 +366,409 @@ func setupFloatCmdFlags(command *cobra.Command) {
 	}
 }
 
+func setupDurationCmdFlags(command *cobra.Command) {
+	args := []struct {
+		name  string
+		key   string
+		value time.Duration
+		usage string
+	}{
+		{"retry-backoff", "retry-backoff", time.Minute, "Wait time before retrying a failed migration, increased exponentially"},
+		{"retry-max-backoff", "retry-max-backoff", time.Hour, "Maximum wait time before retrying a failed migration"},
+	}
+
+	for _, arg := range args {
+		command.Flags().Duration(arg.name, arg.value, arg.usage)
+		if err := viper.BindPFlag(arg.key, command.Flags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+}
+
+func setupBoolCmdFlags(command *cobra.Command) {
+	args := []struct {
+		name  string
+		key   string
+		value bool
+		usage string
+	}{
+		{"kill-switch-talib", "kill-switch-talib", false, "Stop every send while the kill switch flag of the remote database is set"},
+		{"kill-switch-chain", "kill-switch-chain", false, "Stop every send while the token cannot be sent on chain, per the bank send-enabled parameter"},
+	}
+
+	for _, arg := range args {
+		command.Flags().Bool(arg.name, arg.value, arg.usage)
+		if err := viper.BindPFlag(arg.key, command.Flags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+}
+
 func SetupMigrateCmdFlags(command *cobra.Command) {
 	setupStringCmdFlags(command)
 	setupUIntCmdFlags(command)
 	setupFloatCmdFlags(command)
+	setupDurationCmdFlags(command)
+	setupBoolCmdFlags(command)
+
+	command.Flags().Bool("dry-run", false, "Show what would be sent, without changing the work item status or broadcasting the transaction")
+	if err := viper.BindPFlag("dry-run", command.Flags().Lookup("dry-run")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
 }
 
 func mapToken(symbol string, tokenMap map[string]utils.TokenInfo) (*utils.TokenInfo, error) {
 	if _, ok := tokenMap[symbol]; !ok {
-		return nil, fmt.Errorf("token %s not found in token map", symbol)
+		return nil, rejectedBy(policy.TokenMapRuleName, errclass.Terminalf("token %s not found in token map", symbol))
 	}
 	info := tokenMap[symbol]
 	return &info, nil
 }
 
-// migrate migrates a work item to the Manifest Ledger.
-func migrate(r *resty.Client, item *store.WorkItem, config config.MigrateConfig) error {
-	slog.Info("Migrating work item...", "uuid", item.UUID)
+// evaluatePolicy evaluates the eligibility policy for the work item and records the decision in the work item audit
+// and in the audit log, which outlives the state file.
+// It returns an error wrapping errPolicyHold if the migration is on hold, and an error if the migration is rejected.
+func evaluatePolicy(ctx context.Context, item *store.WorkItem, policyConfig policy.Config, txArgs *many.Arguments, amount *big.Int) error {
+	decision, err := decidePolicy(item, policyConfig, txArgs, amount)
+	if err != nil {
+		return err
+	}
+
+	if err = recordDecision(ctx, item, decision); err != nil {
+		return err
+	}
+	return policyError(decision)
+}
+
+// recordRejection records the rejection of the migration by a built-in eligibility rule like a policy decision, if the
+// error is such a rejection. It returns the error.
+func recordRejection(ctx context.Context, item *store.WorkItem, err error) error {
+	var rErr *ruleError
+	if !errors.As(err, &rErr) {
+		return err
+	}
+
+	decision := &store.PolicyDecision{Rule: rErr.rule, Action: string(policy.Reject), Reason: rErr.err.Error(), Time: time.Now().UTC()}
+	slog.Info("Policy decision", "uuid", item.UUID, "rule", decision.Rule, "action", decision.Action, "reason", decision.Reason)
+	if dErr := recordDecision(ctx, item, decision); dErr != nil {
+		return dErr
+	}
+	return err
+}
+
+// recordDecision records the policy decision in the work item audit and in the audit log.
+func recordDecision(ctx context.Context, item *store.WorkItem, decision *store.PolicyDecision) error {
+	entry := store.AuditEntry{Time: decision.Time, Event: store.PolicyEvaluated, UUID: item.UUID, Policy: decision}
+	if err := store.AppendAuditLog(ctx, entry); err != nil {
+		return errclass.Wrap(errclass.Transient, errors.WithMessage(err, "error recording policy decision"))
+	}
+
+	store.UpdateAudit(item, func(audit *store.Audit) { audit.Policy = decision })
+	return nil
+}
 
-	remoteItem, err := store.GetWorkItem(r, item.UUID)
+// decidePolicy evaluates the eligibility policy for the work item and returns the decision, without recording it.
+func decidePolicy(item *store.WorkItem, policyConfig policy.Config, txArgs *many.Arguments, amount *big.Int) (*store.PolicyDecision, error) {
+	engine, err := policy.New(policyConfig)
 	if err != nil {
-		return errors.WithMessage(err, "error getting remote work item")
+		return nil, errors.WithMessage(err, "error loading policy")
+	}
+
+	now := time.Now().UTC()
+	decision := engine.Evaluate(policy.Input{
+		Sender:      txArgs.From,
+		Symbol:      txArgs.Symbol,
+		Amount:      amount,
+		Destination: item.ManifestAddress,
+		Time:        now,
+	})
+	slog.Info("Policy decision", "uuid", item.UUID, "rule", decision.Rule, "action", decision.Action, "reason", decision.Reason)
+
+	return &store.PolicyDecision{
+		Rule:   decision.Rule,
+		Action: string(decision.Action),
+		Reason: decision.Reason,
+		Time:   now,
+	}, nil
+}
+
+// policyError returns an error wrapping errPolicyHold if the decision puts the migration on hold, and an error if it
+// rejects the migration.
+func policyError(decision *store.PolicyDecision) error {
+	switch policy.Action(decision.Action) {
+	case policy.Allow:
+		return nil
+	case policy.Hold:
+		return errors.WithMessagef(errclass.Wrap(errclass.Transient, errPolicyHold), "rule %s", decision.Rule)
+	default:
+		return errclass.Terminalf("migration rejected by policy rule %s: %s", decision.Rule, decision.Reason)
+	}
+}
+
+// transfer is the token transfer of a migration, computed by the read-only steps of the migration.
+type transfer struct {
+	txArgs       *many.Arguments  // The MANY transaction of the migration
+	sourceAmount *big.Int         // Amount of tokens burnt on the MANY chain
+	token        *utils.TokenInfo // Token sent on the MANIFEST chain
+	amount       *big.Int         // Amount of tokens sent on the MANIFEST chain
+}
+
+// prepareTransfer runs the read-only steps of the migration of a work item and returns the token transfer to perform.
+func prepareTransfer(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig) (*transfer, error) {
+	remoteItem, err := store.GetWorkItem(ctx, r, item.UUID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error getting remote work item")
 	}
 
 	// Verify the item is ready for migration
 	if err = verifyItemStatus(remoteItem); err != nil {
-		return errors.WithMessage(err, "error verifying item status")
+		return nil, errors.WithMessage(err, "error verifying item status")
 	}
 
 	// Verify the local and remote items match
 	if err = compareItems(item, remoteItem); err != nil {
-		return errors.WithMessage(err, "error comparing items")
+		return nil, errors.WithMessage(err, "error comparing items")
 	}
 
-	txArgs, err := many.GetTxInfo(r, item.ManyHash)
+	txArgs, err := many.GetTxInfo(ctx, r, item.ManyHash)
 	if err != nil {
-		return errors.WithMessage(err, "error getting MANY tx info")
+		return nil, errors.WithMessage(err, "error getting MANY tx info")
 	}
 
 	// Check the MANY transaction info
-	if err = many.CheckTxInfo(txArgs, item.UUID, item.ManifestAddress); err != nil {
-		return errors.WithMessage(err, "error checking MANY tx info")
+	_, span := tracing.Start(ctx, "CheckTxInfo")
+	err = many.CheckTxInfo(txArgs, item.UUID, item.ManifestAddress)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, errors.WithMessage(rejectedBy(policy.TransactionRuleName, errclass.Wrap(errclass.Terminal, err)), "error checking MANY tx info")
 	}
 
 	// Map the MANY token symbol to the destination chain token
 	tokenInfo, err := mapToken(txArgs.Symbol, config.TokenMap)
 	if err != nil {
-		return errors.WithMessage(err, "error mapping token")
+		return nil, errors.WithMessage(err, "error mapping token")
 	}
 
 	slog.Debug("Original amount", "amount", txArgs.Amount)
 
-	var newItem = *item
+	amount, err := many.ParseAmount(txArgs.Amount)
+	if err != nil {
+		return nil, rejectedBy(policy.TransactionRuleName, errclass.Wrap(errclass.Terminal, err))
+	}
 
-	// If the item status is not MIGRATING, set it to MIGRATING
-	if newItem.Status != store.MIGRATING {
-		if err = setAsMigrating(r, newItem); err != nil {
-			return errors.WithMessage(err, "could not set status to MIGRATING")
+	newAmount, dust := many.ConvertAmount(amount)
+	slog.Debug("Converted amount", "amount", newAmount, "dust", dust)
+
+	return &transfer{txArgs: txArgs, sourceAmount: amount, token: tokenInfo, amount: newAmount}, nil
+}
+
+// migrate migrates a work item to the Manifest Ledger.
+// A work item left MIGRATING by a previous run is resumed: if the previous run sent the tokens, they are not sent
+// again and the work item is completed with the previous transfer, whatever the outcome of the other checks.
+func migrate(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, notifier *notify.Notifier) error {
+	slog.Info("Migrating work item...", "uuid", item.UUID)
+
+	admin.SetStep(ctx, admin.StepReconcile)
+	done, err := reconcile(ctx, r, item)
+	if err != nil || done {
+		return err
+	}
+
+	// Look for the transfer of a previous run first, never send the tokens twice
+	if item.Status == store.MIGRATING {
+		admin.SetStep(ctx, admin.StepFindTransfer)
+		tx, blockTime, err := manifest.FindTransfer(ctx, item, config)
+		if err != nil {
+			return errors.WithMessage(err, "error looking for a previous transfer")
+		}
+		if tx != nil {
+			slog.Warn("Tokens already sent by a previous run", "uuid", item.UUID, "hash", tx.TxHash, "height", tx.Height)
+			return complete(ctx, r, item, config, notifier, tx, blockTime, lastBroadcastDenom(item))
 		}
 	}
 
-	amount := new(big.Int)
-	_, ok := amount.SetString(txArgs.Amount, 10)
-	if !ok {
-		return fmt.Errorf("error parsing big.Int: %s", txArgs.Amount)
+	// An unauthorized address scheduled a migration, or the whitelist could not be checked
+	if err = verifyManyAddressIsAllowed(ctx, item, r); err != nil {
+		return recordRejection(ctx, item, err)
 	}
 
-	// The MANY chain supports 9 decimal places
-	// The MANIFEST chain supports 6 decimal places
-	// Perform a 1:10 split
-	// 1 MFX on the MANY chain = 10 MFX on the MANIFEST chain
-	newAmount := new(big.Int)
-	newAmount.Quo(amount, big.NewInt(100))
+	admin.SetStep(ctx, admin.StepPrepare)
+	t, err := prepareTransfer(ctx, r, item, config)
+	if err != nil {
+		return recordRejection(ctx, item, err)
+	}
 
-	slog.Info("NEW AMOUNT", "newAmount", newAmount.String())
+	// Evaluate the eligibility policy
+	admin.SetStep(ctx, admin.StepPolicy)
+	if err = evaluatePolicy(ctx, item, config.Policy, t.txArgs, t.sourceAmount); err != nil {
+		return err
+	}
 
-	// Send the tokens
-	txHash, blockTime, err := sendTokens(&newItem, config, tokenInfo.Denom, newAmount)
+	tx, blockTime, err := send(ctx, r, item, config, t)
 	if err != nil {
-		return errors.WithMessage(err, "error sending tokens")
+		return err
+	}
+
+	return complete(ctx, r, item, config, notifier, tx, blockTime, t.token.Denom)
+}
+
+// complete confirms the transfer of the tokens of the denom and completes the work item.
+func complete(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, notifier *notify.Notifier, tx *manifest.CosmosTx, blockTime *time.Time, denom string) error {
+	if err := failpoint.Inject(failpoint.AfterInclusion); err != nil {
+		return errclass.Wrap(errclass.Intervention, err)
+	}
+
+	// Wait for the confirmation depth before completing the work item
+	admin.SetStep(ctx, admin.StepConfirm)
+	if err := confirmTransfer(ctx, item, config, tx); err != nil {
+		return err
+	}
+
+	if err := failpoint.Inject(failpoint.AfterConfirmation); err != nil {
+		return errclass.Wrap(errclass.Intervention, err)
 	}
 
-	slog.Info("Migration succeeded on chain...", "hash", txHash, "timestamp", blockTime)
 	// Set the status to COMPLETED
-	if err = setAsCompleted(r, newItem, txHash, blockTime); err != nil {
-		return errors.WithMessage(err, "error setting status to COMPLETED")
+	// The tokens were sent, the work item must be reconciled by an operator if it cannot be completed
+	admin.SetStep(ctx, admin.StepComplete)
+	if err := setAsCompleted(ctx, r, item, &tx.TxHash, blockTime); err != nil {
+		return errclass.Wrap(errclass.Intervention, errors.WithMessage(err, "error setting status to COMPLETED"))
+	}
+	metrics.Completions.Inc()
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted, Item: item})
+	notifier.Go("bank balance check", func(ctx context.Context) { checkBankBalance(ctx, notifier, config, denom) })
+
+	if err := failpoint.Inject(failpoint.AfterCompleted); err != nil {
+		return err
 	}
 
 	// Delete the state file, as the work item is now completed and the state is stored in the database
-	if err = deleteState(&newItem); err != nil {
+	if err := deleteState(ctx, item); err != nil {
 		return errors.WithMessage(err, "error deleting state")
 	}
 
-	slog.Info("Migration complete", "uuid", newItem.UUID)
+	slog.Info("Migration complete", "uuid", item.UUID)
 
 	return nil
 }
 
-func deleteState(item *store.WorkItem) error {
+// lastBroadcastDenom returns the denom of the last migration transaction of the work item, empty if there is none.
+func lastBroadcastDenom(item *store.WorkItem) string {
+	if item.Audit == nil || len(item.Audit.Broadcasts) == 0 {
+		return ""
+	}
+	return strings.TrimLeft(item.Audit.Broadcasts[len(item.Audit.Broadcasts)-1].Coin, "0123456789")
+}
+
+// send simulates the transaction, sets the work item as MIGRATING and sends the tokens.
+func send(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, t *transfer) (*manifest.CosmosTx, *time.Time, error) {
+	// The sends were paused by an operator, nothing is sent until they are resumed
+	if admin.SendsPaused(ctx) {
+		return nil, nil, errclass.Wrap(errclass.Transient, errSendsPaused)
+	}
+
+	// Simulate the transaction, nothing is sent if the simulation fails or the fee is too high
+	admin.SetStep(ctx, admin.StepSimulate)
+	fee, err := manifest.Simulate(ctx, item, config, t.token.Denom, t.amount)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error simulating transaction")
+	}
+	slog.Info("Estimated fee", "fee", fee.Amount.String()+fee.Denom, "gas", fee.Gas, "gasPrice", fee.Price)
+
+	// If the item status is not MIGRATING, set it to MIGRATING
+	if item.Status != store.MIGRATING {
+		if err = setAsMigrating(ctx, r, item); err != nil {
+			return nil, nil, errors.WithMessage(err, "could not set status to MIGRATING")
+		}
+	}
+
+	if err = failpoint.Inject(failpoint.AfterMigrating); err != nil {
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
+	}
+
+	slog.Info("NEW AMOUNT", "newAmount", t.amount.String())
+
+	// Send the tokens
+	admin.SetStep(ctx, admin.StepSend)
+	tx, blockTime, err := sendTokens(ctx, r, item, config, t.token.Denom, t.amount, fee)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error sending tokens")
+	}
+
+	slog.Info("Migration succeeded on chain...", "hash", tx.TxHash, "height", tx.Height, "timestamp", blockTime)
+	amountSent, _ := new(big.Float).SetInt(t.amount).Float64()
+	metrics.MigratedAmount.WithLabelValues(t.token.Denom).Add(amountSent)
+
+	return tx, blockTime, nil
+}
+
+// reconcile catches up with the remote work item updated by a previous run which stopped before saving the local
+// state. It returns true if the work item was already completed, deleting its local state.
+func reconcile(ctx context.Context, r *resty.Client, item *store.WorkItem) (bool, error) {
+	remoteItem, err := store.GetWorkItem(ctx, r, item.UUID)
+	if err != nil {
+		return false, errors.WithMessage(err, "error getting remote work item")
+	}
+
+	switch {
+	case item.Status == store.CLAIMED && remoteItem.Status == store.MIGRATING:
+		slog.Warn("Work item already set as MIGRATING by a previous run", "uuid", item.UUID)
+		item.Status = store.MIGRATING
+		if err := store.SaveState(ctx, item); err != nil {
+			return false, err
+		}
+	case item.Status == store.MIGRATING && remoteItem.Status == store.COMPLETED:
+		slog.Warn("Work item already completed by a previous run", "uuid", item.UUID, "hash", remoteItem.ManifestHash)
+		if err := deleteState(ctx, item); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func deleteState(ctx context.Context, item *store.WorkItem) error {
 	slog.Info("Deleting local state file...")
-	if err := os.Remove(fmt.Sprintf("%s.json", item.UUID)); err != nil {
+	if err := os.Remove(store.StatePath(ctx, item.UUID.String())); err != nil {
 		return errors.WithMessage(err, "error deleting state")
 	}
 	return nil
 }
 
 // setAsMigrating sets the status of the work item to MIGRATING and updates the state.
-func setAsMigrating(r *resty.Client, newItem store.WorkItem) error {
+// The work item is only modified if the update succeeds.
+func setAsMigrating(ctx context.Context, r *resty.Client, item *store.WorkItem) error {
+	newItem := *item
 	newItem.Status = store.MIGRATING
-	if err := store.UpdateWorkItemAndSaveState(r, newItem); err != nil {
+	if err := store.UpdateWorkItemAndSaveState(ctx, r, &newItem); err != nil {
 		return errors.WithMessage(err, "error setting status to MIGRATING")
 	}
+	*item = newItem
 	return nil
 }
 
 // setAsCompleted sets the status of the work item to COMPLETED.
 // It also sets the manifest hash and updates the state.
-func setAsCompleted(r *resty.Client, newItem store.WorkItem, txHash *string, blockTime *time.Time) error {
+// The work item is only modified if the update succeeds.
+func setAsCompleted(ctx context.Context, r *resty.Client, item *store.WorkItem, txHash *string, blockTime *time.Time) error {
+	newItem := *item
 	newItem.Status = store.COMPLETED
 	newItem.ManifestHash = txHash
 	newItem.ManifestDatetime = blockTime
-	if err := store.UpdateWorkItemAndSaveState(r, newItem); err != nil {
+	if err := store.UpdateWorkItemAndSaveState(ctx, r, &newItem); err != nil {
 		return errors.WithMessage(err, "error setting status to COMPLETED")
 	}
+	*item = newItem
 	return nil
 }
 
-func setAsFailed(r *resty.Client, newItem store.WorkItem, errStr *string) error {
+// setAsFailed sets the status of the work item to FAILED with the error and its class, and updates the state.
+// The work item is only modified if the update succeeds.
+func setAsFailed(ctx context.Context, r *resty.Client, item *store.WorkItem, errStr *string, class errclass.Class) error {
+	newItem := *item
 	newItem.Status = store.FAILED
+	newItem.ErrorClass = class
 
 	// Truncate the error string if it is too long (Talib limitation)
 	maxLen := 8192
@@ -355,22 +779,87 @@ func setAsFailed(r *resty.Client, newItem store.WorkItem, errStr *string) error
 	}
 	newItem.Error = errStr
 
-	if err := store.UpdateWorkItemAndSaveState(r, newItem); err != nil {
+	if err := store.UpdateWorkItemAndSaveState(ctx, r, &newItem); err != nil {
 		return errors.WithMessage(err, "error setting status to FAILED")
 	}
+	*item = newItem
 	return nil
 }
 
-// sendTokens sends the tokens from the bank account to the user account.
-func sendTokens(item *store.WorkItem, config config.MigrateConfig, denom string, amount *big.Int) (*string, *time.Time, error) {
-	txResponse, blockTime, err := manifest.Migrate(item, config, denom, amount)
+// notifyFailure notifies the failure of the work item, or that an operator intervention is required if the tokens may
+// have been sent.
+func notifyFailure(notifier *notify.Notifier, item *store.WorkItem, err error, class errclass.Class) {
+	event := notify.ItemFailed
+	if class == errclass.Intervention {
+		event = notify.InterventionRequired
+	}
+	notifier.Notify(notify.Payload{Event: event, Item: item, Error: err.Error(), ErrorClass: string(class)})
+}
+
+// checkBankBalance notifies a low bank balance if the balance of the denom dropped below its threshold.
+// It queries the chain, so it runs on the worker of the notifier, off the migration path.
+func checkBankBalance(ctx context.Context, notifier *notify.Notifier, config config.MigrateConfig, denom string) {
+	threshold, ok := notifier.LowBalanceThreshold(denom)
+	if !ok {
+		return
+	}
+
+	balance, err := manifest.GetBankBalance(ctx, config, denom)
+	if err != nil {
+		slog.Warn("Could not check the bank balance", "denom", denom, "error", err)
+		return
+	}
+
+	if balance.Cmp(threshold) < 0 {
+		slog.Warn("Low bank balance", "denom", denom, "balance", balance.String(), "threshold", threshold.String())
+		notifier.Notify(notify.Payload{Event: notify.LowBalance, Balance: &notify.Balance{
+			Denom:     denom,
+			Amount:    balance.String(),
+			Threshold: threshold.String(),
+		}})
+	}
+}
+
+// sendTokens sends the tokens from the bank account to the user account, unless the kill switch is engaged.
+func sendTokens(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, denom string, amount *big.Int, fee *manifest.Fee) (*manifest.CosmosTx, *time.Time, error) {
+	// Nothing is broadcast while the kill switch is engaged
+	if err := checkKillSwitch(ctx, r, config, denom); err != nil {
+		return nil, nil, err
+	}
+
+	txResponse, blockTime, err := manifest.Migrate(ctx, item, config, denom, amount, fee, func() error {
+		return store.SaveState(ctx, item)
+	})
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "error during migration, operator intervention required")
+		// The outcome of the transfer is unknown
+		if errclass.Of(err) == errclass.Intervention {
+			return nil, nil, errors.WithMessage(err, "error during migration, operator intervention required")
+		}
+		return nil, nil, errors.WithMessage(err, "error during migration")
 	}
 
-	if txResponse.Code != 0 {
-		return nil, nil, errors.WithMessagef(err, "migration failed: %s", txResponse.RawLog)
+	return txResponse, blockTime, nil
+}
+
+// confirmTransfer waits until the transaction is the confirmation depth deep and records the confirmation in the work
+// item audit and in the audit log, which outlives the state file.
+func confirmTransfer(ctx context.Context, item *store.WorkItem, config config.MigrateConfig, tx *manifest.CosmosTx) error {
+	confirmation, err := manifest.Confirm(ctx, config, tx)
+	if err != nil {
+		if errclass.Of(err) == errclass.Intervention {
+			return errors.WithMessage(err, "error confirming transaction, operator intervention required")
+		}
+		return errors.WithMessage(err, "error confirming transaction")
+	}
+
+	// The transfer is found again by the next attempt, which confirms it again
+	entry := store.AuditEntry{Time: time.Now().UTC(), Event: store.TransferConfirmed, UUID: item.UUID, TxHash: tx.TxHash, Confirmation: confirmation}
+	if err = store.AppendAuditLog(ctx, entry); err != nil {
+		return errclass.Wrap(errclass.Transient, errors.WithMessage(err, "error recording confirmation"))
 	}
 
-	return &txResponse.TxHash, blockTime, nil
+	store.UpdateAudit(item, func(audit *store.Audit) { audit.Confirmation = confirmation })
+
+	slog.Info("Transaction confirmed", "hash", tx.TxHash, "height", confirmation.Height, "confirmedHeight", confirmation.ConfirmedHeight)
+	return nil
 }
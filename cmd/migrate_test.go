@@ -3,6 +3,7 @@ package cmd_test
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-resty/resty/v2"
@@ -10,6 +11,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
 	"github.com/manifest-network/mfx-migrator/cmd"
 	"github.com/manifest-network/mfx-migrator/testutils"
 )
@@ -79,3 +83,38 @@ func TestMigrateCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateCmd_FeeCeiling(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	testutils.SetupWorkItem(t)
+
+	binary := filepath.Join(t.TempDir(), "manifestd")
+	require.NoError(t, os.WriteFile(binary, []byte(fakeBinary), 0o755))
+
+	command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+	client := resty.New()
+	command.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupMigrateCmdFlags(command)
+
+	// The work item is not updated, setting it as MIGRATING would fail
+	httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+	httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.WhiteListResponder)
+	httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultMigrationUrl, testutils.MustMigrationGetResponder(store.CLAIMED))
+	httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultTransactionUrl, testutils.MustNewLedgerSendTransactionResponseResponder("12345"))
+
+	_, err := testutils.Execute(t, command, "--uuid", testutils.DummyUUIDStr, "--url", testutils.RootUrl, "--chain-home", "/tmp",
+		"--fee-granter", "feegranter", "--username", "user", "--password", "pass", "--binary", binary, "--max-fee", "89")
+	require.ErrorContains(t, err, "estimated fee 90umfx exceeds the maximum fee 89umfx")
+	require.Equal(t, errclass.Transient, errclass.Of(err))
+
+	// The failed attempt is recorded, the status is unchanged
+	item, err := store.LoadState(context.Background(), testutils.DummyUUIDStr)
+	require.NoError(t, err)
+	require.Equal(t, store.CLAIMED, item.Status)
+	require.Equal(t, 1, item.Audit.Attempts)
+}
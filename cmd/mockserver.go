@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+// mockServerCmd serves an in-memory talib, for local development
+var mockServerCmd = &cobra.Command{
+	Use:    "mock-server",
+	Short:  "Serve an in-memory remote database, for local development",
+	Hidden: true,
+	RunE:   MockServerCmdRunE,
+}
+
+func MockServerCmdRunE(cmd *cobra.Command, args []string) error {
+	authConfig, err := LoadAuthConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	if err := authConfig.Validate(); err != nil {
+		return err
+	}
+
+	talib := faketalib.New(authConfig.Username, authConfig.Password)
+	talib.Neighborhood = strconv.FormatUint(viper.GetUint64("neighborhood"), 10)
+
+	if path := viper.GetString("mock-server-migrations"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.WithMessage(err, "could not read migrations")
+		}
+
+		var migrations []faketalib.Migration
+		if err := json.Unmarshal(data, &migrations); err != nil {
+			return errors.WithMessage(err, "could not parse migrations")
+		}
+
+		for _, m := range migrations {
+			item := talib.Add(m)
+			slog.Info("Migration added", "uuid", item.UUID, "manyHash", item.ManyHash)
+		}
+	}
+
+	listener, err := net.Listen("tcp", viper.GetString("mock-server-listen"))
+	if err != nil {
+		return errors.WithMessage(err, "could not start mock server listener")
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Handler: talib, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Could not stop mock server", "error", err)
+		}
+	}()
+
+	slog.Info("Serving mock remote database", "url", "http://"+listener.Addr().String()+"/", "neighborhood", talib.Neighborhood)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.WithMessage(err, "mock server stopped")
+	}
+
+	return nil
+}
+
+func init() {
+	SetupMockServerCmdFlags(mockServerCmd)
+	rootCmd.AddCommand(mockServerCmd)
+}
+
+func SetupMockServerCmdFlags(command *cobra.Command) {
+	command.Flags().String("listen", "localhost:3001", "Address the mock server listens on")
+	if err := viper.BindPFlag("mock-server-listen", command.Flags().Lookup("listen")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().String("migrations", "", "JSON file holding the migrations to serve, as an array of {uuid, from, manifestAddress, amount, symbol, allowed, neighborhood}")
+	if err := viper.BindPFlag("mock-server-migrations", command.Flags().Lookup("migrations")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// neighborhoodsKey is the configuration key holding the neighborhoods served by the migrator, see serve.
+const neighborhoodsKey = "neighborhoods"
+
+// LoadNeighborhoodsFromCLI loads the neighborhoods served by the migrator from the configuration file
+func LoadNeighborhoodsFromCLI() ([]config.NeighborhoodConfig, error) {
+	var neighborhoods []config.NeighborhoodConfig
+	if err := viper.UnmarshalKey(neighborhoodsKey, &neighborhoods, strict); err != nil {
+		return nil, errors.WithMessage(err, "invalid neighborhoods configuration")
+	}
+	return neighborhoods, nil
+}
+
+// findNeighborhood returns the configuration of the neighborhood, if served by the migrator.
+func findNeighborhood(neighborhoods []config.NeighborhoodConfig, id uint64) (config.NeighborhoodConfig, bool) {
+	for _, n := range neighborhoods {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return config.NeighborhoodConfig{}, false
+}
+
+// neighborhoodDir returns the directory of the neighborhood in the given directory. When the migrator serves several
+// neighborhoods, every neighborhood has its own subdirectory named after the neighborhood, e.g. `neighborhood-1`, so the
+// UUIDs of two neighborhoods never collide. Otherwise, the directory is returned as is.
+func neighborhoodDir(dir string, neighborhood uint64) string {
+	if !viper.IsSet(neighborhoodsKey) {
+		return dir
+	}
+	return filepath.Join(dir, fmt.Sprintf("neighborhood-%d", neighborhood))
+}
+
+// stateContext returns the context of the state files of the work items of the neighborhood, see neighborhoodDir.
+func stateContext(ctx context.Context, neighborhood uint64) (context.Context, error) {
+	dir := neighborhoodDir(store.StateDir(ctx), neighborhood)
+	if err := checkStateLayout(store.StateDir(ctx), dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.WithMessage(err, "could not create state directory")
+	}
+	return store.WithStateDir(ctx, dir), nil
+}
+
+// checkStateLayout refuses the state directory, a subdirectory of the parent directory for a profile or a neighborhood,
+// while the parent directory holds state files. They were written before the profile or the neighborhoods were
+// configured, and their work items, e.g. left MIGRATING, would never be migrated.
+func checkStateLayout(parent string, stateDir string) error {
+	if filepath.Clean(parent) == filepath.Clean(stateDir) {
+		return nil
+	}
+
+	items, err := store.ReadStates(parent)
+	if err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		return fmt.Errorf("%d state files found in %s, outside of the state directory %s: move each one to the state directory of its profile and neighborhood", len(items), parent, stateDir)
+	}
+	return nil
+}
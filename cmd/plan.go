@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/manifest"
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what the migration of the claimed work items would send, without sending anything.",
+	Long: `The plan command runs the read-only steps of the migration of every claimed work item in the state directory,
+and simulates the token transfers. Nothing is sent and the work items are left untouched.
+
+It accepts the flags of the migrate command, but the UUID.`,
+	RunE: PlanCmdRunE,
+}
+
+func init() {
+	SetupPlanCmdFlags(planCmd, migrateCmd)
+	rootCmd.AddCommand(planCmd)
+}
+
+// SetupPlanCmdFlags shares the flags of the migrate command with the plan command, but the UUID and the dry run.
+// The flags are bound to the same configuration keys, whichever command is run.
+func SetupPlanCmdFlags(command *cobra.Command, migrateCommand *cobra.Command) {
+	migrateCommand.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "uuid" && flag.Name != "dry-run" {
+			command.Flags().AddFlag(flag)
+		}
+	})
+}
+
+func PlanCmdRunE(cmd *cobra.Command, args []string) error {
+	// No UUID, the plan covers all the claimed work items
+	c := LoadConfigFromCLI("")
+	slog.Debug("args", "c", c)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	migrateConfig, err := LoadMigrationConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	slog.Debug("args", "migrate-c", migrateConfig)
+	if err := migrateConfig.Validate(); err != nil {
+		return err
+	}
+
+	authConfig, err := LoadAuthConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	slog.Debug("args", "auth-c", authConfig)
+	if err := authConfig.Validate(); err != nil {
+		return err
+	}
+
+	httpConfig := LoadHttpConfigFromCLI()
+	slog.Debug("args", "http-c", httpConfig)
+	if err := httpConfig.Validate(); err != nil {
+		return err
+	}
+
+	ctx, err := stateContext(cmd.Context(), c.Neighborhood)
+	if err != nil {
+		return err
+	}
+
+	items, err := store.ReadStates(store.StateDir(ctx))
+	if err != nil {
+		return err
+	}
+
+	var claimed []*store.WorkItem
+	for _, item := range items {
+		if verifyItemStatus(item) == nil {
+			claimed = append(claimed, item)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if len(claimed) == 0 {
+		fmt.Fprintln(out, "No claimed work items")
+		return nil
+	}
+
+	r, err := CreateRestClient(ctx, c.Url, c.Neighborhood, httpConfig)
+	if err != nil {
+		return err
+	}
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
+		return err
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tRECIPIENT\tAMOUNT\tFEE\tPOLICY\tERROR")
+	for _, item := range claimed {
+		p, err := dryRun(ctx, r, item, migrateConfig)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\t%s\t\t\t\t%s\n", item.UUID, item.ManifestAddress, err)
+			continue
+		}
+		if p.sent != nil {
+			fmt.Fprintf(w, "%s\t%s\t\t\t\talready sent in %s\n", item.UUID, item.ManifestAddress, p.sent.TxHash)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s%s\t%s%s\t%s\t\n", item.UUID, item.ManifestAddress, p.amount, p.token.Denom, p.fee.Amount, p.fee.Denom, p.policy.Action)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d work items cannot be migrated", failed, len(claimed))
+	}
+	return nil
+}
+
+// plan is the outcome of the dry run of a migration.
+type plan struct {
+	*transfer
+	policy *store.PolicyDecision
+	fee    *manifest.Fee
+	sent   *manifest.CosmosTx // Transfer of a previous run, the migration would complete the work item with it
+}
+
+// dryRun runs the read-only steps of the migration of a work item, including the whitelist check and the policy
+// evaluation, and simulates the token transfer. Nothing is sent and the work item is left untouched.
+// A migration put on hold by the policy is not an error, its plan shows the policy decision.
+// Like the migration, the dry run of a work item left MIGRATING looks for the transfer of a previous run first.
+func dryRun(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig) (*plan, error) {
+	if item.Status == store.MIGRATING {
+		tx, _, err := manifest.FindTransfer(ctx, item, config)
+		if err != nil {
+			if errclass.Of(err) == errclass.Intervention {
+				return nil, errors.WithMessage(err, "unknown, intervention required")
+			}
+			return nil, errors.WithMessage(err, "error looking for a previous transfer")
+		}
+		if tx != nil {
+			return &plan{sent: tx}, nil
+		}
+	}
+
+	if err := verifyManyAddressIsAllowed(ctx, item, r); err != nil {
+		return nil, err
+	}
+
+	t, err := prepareTransfer(ctx, r, item, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// The policy decision is not recorded
+	decision, err := decidePolicy(item, config.Policy, t.txArgs, t.sourceAmount)
+	if err != nil {
+		return nil, err
+	}
+	if err = policyError(decision); err != nil && !errors.Is(err, errPolicyHold) {
+		return nil, err
+	}
+
+	fee, err := manifest.Simulate(ctx, item, config, t.token.Denom, t.amount)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error simulating transaction")
+	}
+
+	return &plan{transfer: t, policy: decision, fee: fee}, nil
+}
+
+// printPlan prints what the migration of the work item would send.
+func printPlan(out io.Writer, item *store.WorkItem, p *plan) {
+	fmt.Fprintln(out, "Dry run, nothing was sent")
+	fmt.Fprintf(out, "UUID:          %s\n", item.UUID)
+	if p.sent != nil {
+		fmt.Fprintf(out, "Recipient:     %s\n", item.ManifestAddress)
+		fmt.Fprintf(out, "Transfer:      already sent in %s, the migration would complete the work item\n", p.sent.TxHash)
+		return
+	}
+	fmt.Fprintf(out, "Sender:        %s\n", p.txArgs.From)
+	fmt.Fprintf(out, "Recipient:     %s\n", item.ManifestAddress)
+	fmt.Fprintf(out, "Amount:        %s%s\n", p.amount, p.token.Denom)
+	fmt.Fprintf(out, "Source amount: %s %s\n", p.sourceAmount, p.txArgs.Symbol)
+	fmt.Fprintf(out, "Estimated fee: %s%s (%d gas)\n", p.fee.Amount, p.fee.Denom, p.fee.Gas)
+	fmt.Fprintf(out, "Policy:        %s by rule %s", p.policy.Action, p.policy.Rule)
+	if p.policy.Action != string(policy.Allow) {
+		fmt.Fprintf(out, ", the migration would not be sent: %s", p.policy.Reason)
+	}
+	fmt.Fprintln(out)
+}
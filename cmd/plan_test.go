@@ -0,0 +1,239 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/fakechain"
+)
+
+// fakeBinary is a chain binary resolving the bank address, querying the node minimum gas price, simulating
+// transactions, and sending tokens in transactions included at block 42 with the matching transfer events.
+const fakeBinary = `#!/bin/sh
+for arg in "$@"; do
+  if [ "$arg" = "--dry-run" ]; then
+    echo "gas estimate: 81234" >&2
+    exit 0
+  fi
+done
+case "$1 $2" in
+  "keys show") echo "manifest1hj5fveer5cjtn4wd6wstzugjfdxzl0xp8ws9ct"; exit 0 ;;
+  "q node") echo '{"minimum_gas_price":"0.500000000000000000uatom,0.002000000000000000umfx"}'; exit 0 ;;
+  "tx bank")
+    echo "$5 $6" > "$0.sent"
+    echo '{"txhash":"8F5E3B9A0C1D2E3F","code":0}'; exit 0 ;;
+  "q event-query-tx-for")
+    read -r to amount < "$0.sent"
+    printf '{"txhash":"%s","height":"42","code":0,"events":[' "$3"
+    printf '{"type":"coin_received","attributes":[{"key":"receiver","value":"%s"},{"key":"amount","value":"%s"}]},' "$to" "$amount"
+    printf '{"type":"transfer","attributes":[{"key":"recipient","value":"%s"},{"key":"amount","value":"%s"}]}]}\n' "$to" "$amount"
+    exit 0 ;;
+  "q block") echo '{"header":{"time":"2024-06-01T12:00:00.123Z"}}'; exit 0 ;;
+esac
+echo "unexpected command: $*" >&2
+exit 1
+`
+
+func TestDryRun(t *testing.T) {
+	tmpdir := t.TempDir()
+	if err := os.Chdir(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	testutils.SetupWorkItem(t)
+
+	binary := filepath.Join(t.TempDir(), "manifestd")
+	require.NoError(t, os.WriteFile(binary, []byte(fakeBinary), 0o755))
+
+	args := []string{"--url", testutils.RootUrl, "--chain-home", "/tmp", "--fee-granter", "feegranter",
+		"--username", "user", "--password", "pass", "--binary", binary}
+
+	endpoints := []testutils.HttpResponder{
+		{Method: "POST", Url: testutils.LoginUrl, Responder: testutils.AuthResponder},
+		{Method: "GET", Url: "=~^" + testutils.WhiteListUrl, Responder: testutils.WhiteListResponder},
+		{Method: "GET", Url: "=~^" + testutils.DefaultMigrationUrl, Responder: testutils.MustMigrationGetResponder(store.CLAIMED)},
+		{Method: "GET", Url: "=~^" + testutils.DefaultTransactionUrl, Responder: testutils.MustNewLedgerSendTransactionResponseResponder("12345")},
+	}
+
+	tt := []struct {
+		name      string
+		command   func() *cobra.Command
+		args      []string
+		endpoints []testutils.HttpResponder
+		err       string
+		expected  []string
+	}{
+		{name: "migrate dry run", command: func() *cobra.Command {
+			command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(command)
+			return command
+		}, args: append([]string{"--uuid", testutils.DummyUUIDStr, "--dry-run"}, args...), endpoints: endpoints, expected: []string{
+			"Recipient:     " + testutils.ManifestAddress,
+			"Amount:        123umfx",
+			"Estimated fee: 90umfx (81234 gas)",
+			"Policy:        allow by rule default",
+		}},
+		{name: "migrate dry run with node minimum gas price", command: func() *cobra.Command {
+			command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(command)
+			return command
+		}, args: append([]string{"--uuid", testutils.DummyUUIDStr, "--dry-run", "--gas-price-source", "min-gas-price"}, args...), endpoints: endpoints, expected: []string{
+			"Estimated fee: 163umfx (81234 gas)",
+		}},
+		{name: "migrate dry run fee too high", command: func() *cobra.Command {
+			command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(command)
+			return command
+		}, args: append([]string{"--uuid", testutils.DummyUUIDStr, "--dry-run", "--max-fee", "89"}, args...), endpoints: endpoints, err: "estimated fee 90umfx exceeds the maximum fee 89umfx"},
+		{name: "migrate dry run not whitelisted", command: func() *cobra.Command {
+			command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(command)
+			return command
+		}, args: append([]string{"--uuid", testutils.DummyUUIDStr, "--dry-run"}, args...), endpoints: []testutils.HttpResponder{
+			{Method: "POST", Url: testutils.LoginUrl, Responder: testutils.AuthResponder},
+			{Method: "GET", Url: "=~^" + testutils.WhiteListUrl, Responder: testutils.InvalidWhiteListResponder},
+			{Method: "GET", Url: "=~^" + testutils.DefaultTransactionUrl, Responder: testutils.MustNewLedgerSendTransactionResponseResponder("12345")},
+		}, err: "not allowed to migrate"},
+		{name: "plan", command: func() *cobra.Command {
+			migrate := &cobra.Command{Use: "migrate"}
+			command := &cobra.Command{Use: "plan", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.PlanCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(migrate)
+			cmd.SetupPlanCmdFlags(command, migrate)
+			return command
+		}, args: args, endpoints: endpoints, expected: []string{testutils.DummyUUIDStr, "123umfx", "90umfx", "allow"}},
+	}
+
+	for _, tc := range tt {
+		command := tc.command()
+
+		// Create a new resty client and inject it into the command context
+		client := resty.New()
+		ctx := context.WithValue(context.Background(), cmd.RestyClientKey, client)
+		command.SetContext(ctx)
+
+		// Enable http mocking on the resty client, the work item must not be updated
+		httpmock.ActivateNonDefault(client.GetClient())
+
+		t.Run(tc.name, func(t *testing.T) {
+			for _, endpoint := range tc.endpoints {
+				httpmock.RegisterResponder(endpoint.Method, endpoint.Url, endpoint.Responder)
+			}
+
+			out, err := testutils.Execute(t, command, tc.args...)
+			t.Log(out)
+
+			if tc.err == "" {
+				require.NoError(t, err)
+				for _, expected := range tc.expected {
+					require.Contains(t, out, expected)
+				}
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+
+			// Nothing changed
+			item, err := store.LoadState(context.Background(), testutils.DummyUUIDStr)
+			require.NoError(t, err)
+			require.Equal(t, store.CLAIMED, item.Status)
+			require.Nil(t, item.Audit)
+			httpmock.Reset()
+		})
+	}
+}
+
+// TestDryRun_Migrating looks for the transfer of a previous run of the work items left MIGRATING, instead of showing a
+// send that would never happen.
+func TestDryRun_Migrating(t *testing.T) {
+	transfer := `{"txhash":"8F5E3B9A0C1D2E3F","height":"42","code":0,"events":[` +
+		`{"type":"coin_received","attributes":[{"key":"receiver","value":"` + testutils.ManifestAddress + `"},{"key":"amount","value":"123umfx"}]},` +
+		`{"type":"transfer","attributes":[{"key":"recipient","value":"` + testutils.ManifestAddress + `"},{"key":"amount","value":"123umfx"}]}]}`
+	chain := fakechain.New(t,
+		fakechain.Command{Pattern: "'q tx 8F5E3B9A0C1D2E3F '*", Response: fakechain.Response{Stdout: transfer}},
+		fakechain.Command{Pattern: "'q txs '*", Response: fakechain.Response{Stdout: `{"txs":[]}`}},
+		fakechain.Command{Pattern: "'q block '*", Response: fakechain.Response{Stdout: `{"header":{"time":"2024-06-01T12:00:00.123Z"}}`}},
+	)
+
+	args := []string{"--url", testutils.RootUrl, "--chain-home", "/tmp", "--fee-granter", "feegranter",
+		"--username", "user", "--password", "pass", "--binary", chain.Path}
+
+	sent := []store.Broadcast{{TxHash: "8F5E3B9A0C1D2E3F", Coin: "123umfx"}}
+	pending := []store.Broadcast{{Coin: "123umfx"}}
+
+	tt := []struct {
+		name       string
+		plan       bool
+		broadcasts []store.Broadcast
+		err        string
+		expected   string
+	}{
+		{name: "migrate dry run already sent", broadcasts: sent, expected: "Transfer:      already sent in 8F5E3B9A0C1D2E3F"},
+		{name: "migrate dry run unknown", broadcasts: pending, err: "unknown, intervention required"},
+		{name: "plan already sent", plan: true, broadcasts: sent, expected: "already sent in 8F5E3B9A0C1D2E3F"},
+		{name: "plan unknown", plan: true, broadcasts: pending, err: "1 of 1 work items cannot be migrated", expected: "unknown, intervention required"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, os.Chdir(t.TempDir()))
+			testutils.SetupWorkItem(t)
+			item, err := store.LoadState(context.Background(), testutils.DummyUUIDStr)
+			require.NoError(t, err)
+			item.Status = store.MIGRATING
+			item.Audit = &store.Audit{Broadcasts: tc.broadcasts}
+			require.NoError(t, store.SaveState(context.Background(), item))
+
+			var command *cobra.Command
+			commandArgs := args
+			if tc.plan {
+				migrate := &cobra.Command{Use: "migrate"}
+				command = &cobra.Command{Use: "plan", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.PlanCmdRunE}
+				cmd.SetupRootCmdFlags(command)
+				cmd.SetupMigrateCmdFlags(migrate)
+				cmd.SetupPlanCmdFlags(command, migrate)
+			} else {
+				command = &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+				cmd.SetupRootCmdFlags(command)
+				cmd.SetupMigrateCmdFlags(command)
+				commandArgs = append([]string{"--uuid", testutils.DummyUUIDStr, "--dry-run"}, args...)
+			}
+
+			client := resty.New()
+			command.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+			httpmock.ActivateNonDefault(client.GetClient())
+			defer httpmock.Reset()
+			httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+
+			out, err := testutils.Execute(t, command, commandArgs...)
+			t.Log(out)
+
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+			if tc.expected != "" {
+				require.Contains(t, out, tc.expected)
+			}
+
+			// Nothing changed
+			loaded, err := store.LoadState(context.Background(), testutils.DummyUUIDStr)
+			require.NoError(t, err)
+			require.Equal(t, item, loaded)
+		})
+	}
+}
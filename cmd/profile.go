@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// profilesKey is the configuration key holding the profiles, by name.
+const profilesKey = "profiles"
+
+// applyProfile merges the settings of the profile into the configuration and selects the state directory.
+// The settings of the profile override the top-level settings of the configuration file, the environment and the
+// flags override both. A map setting of the profile, e.g. the token map or the policy, replaces the top-level one
+// instead of being merged into it. The state files of a profile are in a subdirectory of the state directory named after the
+// profile, so the work items of a profile are never migrated with the settings of another profile.
+func applyProfile(profile string) error {
+	if profile != "" {
+		key := profilesKey + "." + profile
+		if !viper.IsSet(key) {
+			return fmt.Errorf("profile %s not found in the configuration file", profile)
+		}
+		settings := viper.GetStringMap(key)
+		if err := viper.MergeConfigMap(settings); err != nil {
+			return errors.WithMessagef(err, "could not apply profile %s", profile)
+		}
+
+		// Viper merges the maps recursively, the maps of the profile are set instead so that they replace the top-level
+		// ones. They cannot be set by the environment or the flags.
+		for name, value := range settings {
+			switch value.(type) {
+			case map[string]any, map[any]any:
+				viper.Set(name, value)
+			}
+		}
+	}
+
+	stateDir := filepath.Join(viper.GetString("state-dir"), profile)
+	if err := checkStateLayout(viper.GetString("state-dir"), stateDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return errors.WithMessage(err, "could not create state directory")
+	}
+	store.SetStateDir(stateDir)
+
+	return nil
+}
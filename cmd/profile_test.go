@@ -0,0 +1,131 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+func TestProfile(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.SetStateDir(".") })
+	// The maps of the profile override the configuration
+	tokenMap := viper.Get("token-map")
+	t.Cleanup(func() { viper.Set("token-map", tokenMap) })
+
+	talib := faketalib.New("user", "pass")
+	server := httptest.NewServer(talib)
+	defer server.Close()
+	item := talib.Add(faketalib.Migration{
+		From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+	})
+
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(fmt.Sprintf(`
+chain-id: manifest-1
+token-map:
+  dummy:
+    denom: umfx
+  other:
+    denom: uother
+profiles:
+  testnet:
+    url: %s
+    chain-id: manifest-testnet
+    fee-granter: testnet-granter
+    token-map:
+      dummy:
+        denom: utest
+  mainnet:
+    url: http://mainnet.invalid
+    state-dir: mainnet-state
+`, server.URL))))
+	t.Cleanup(func() { require.NoError(t, viper.ReadConfig(strings.NewReader(""))) })
+
+	credentials := []string{"--username", "user", "--password", "pass"}
+
+	t.Run("unknown profile", func(t *testing.T) {
+		command := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupClaimCmdFlags(command)
+		_, err := testutils.Execute(t, command, append([]string{"--profile", "devnet"}, credentials...)...)
+		require.ErrorContains(t, err, "profile devnet not found in the configuration file")
+	})
+
+	t.Run("claim in the state directory of the profile", func(t *testing.T) {
+		command := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupClaimCmdFlags(command)
+		_, err := testutils.Execute(t, command, append([]string{"--profile", "testnet"}, credentials...)...)
+		require.NoError(t, err)
+
+		require.FileExists(t, filepath.Join("testnet", item.UUID.String()+".json"))
+		require.NoFileExists(t, item.UUID.String()+".json")
+	})
+
+	t.Run("state files outside of the state directory of the profile", func(t *testing.T) {
+		claimed := &store.WorkItem{UUID: item.UUID, Status: store.CLAIMED}
+		require.NoError(t, store.SaveState(store.WithStateDir(context.Background(), "."), claimed))
+		t.Cleanup(func() { require.NoError(t, os.Remove(item.UUID.String()+".json")) })
+
+		command := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupClaimCmdFlags(command)
+		_, err := testutils.Execute(t, command, append([]string{"--profile", "testnet"}, credentials...)...)
+		require.ErrorContains(t, err, "1 state files found in ., outside of the state directory testnet")
+	})
+
+	t.Run("work item of another profile", func(t *testing.T) {
+		command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupMigrateCmdFlags(command)
+		_, err := testutils.Execute(t, command, append([]string{"--profile", "mainnet", "--uuid", item.UUID.String(),
+			"--chain-home", "/tmp", "--fee-granter", "feegranter", "--binary", "sh"}, credentials...)...)
+		require.ErrorContains(t, err, "unable to load state")
+		require.DirExists(t, filepath.Join("mainnet-state", "mainnet"))
+	})
+
+	t.Run("show the profile settings", func(t *testing.T) {
+		command := configCommand(cmd.ConfigShowCmdRunE)
+		command.PersistentPreRunE = cmd.RootCmdPersistentPreRunE
+		var out bytes.Buffer
+		command.SetOut(&out)
+		command.SetArgs([]string{"--profile", "testnet", "--fee-granter", "flag-granter"})
+		require.NoError(t, command.Execute())
+
+		lines := configLines(out.String())
+		require.Equal(t, []string{"manifest-testnet", "profile"}, lines["chain-id"])
+		require.Equal(t, []string{server.URL, "profile"}, lines["url"])
+		require.Equal(t, []string{"flag-granter", "flag"}, lines["fee-granter"])
+	})
+
+	t.Run("token map of the profile", func(t *testing.T) {
+		command := configCommand(cmd.ConfigShowCmdRunE)
+		command.PersistentPreRunE = cmd.RootCmdPersistentPreRunE
+		command.SetOut(&bytes.Buffer{})
+		command.SetArgs([]string{"--profile", "testnet"})
+		require.NoError(t, command.Execute())
+
+		// The token map of the profile does not inherit the top-level tokens
+		require.Len(t, viper.GetStringMap("token-map"), 1)
+		require.Equal(t, "utest", viper.GetString("token-map.dummy.denom"))
+	})
+
+}
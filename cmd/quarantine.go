@@ -0,0 +1,417 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/quarantine"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// maxErrorLen is the maximum length of the error displayed by the list command.
+const maxErrorLen = 60
+
+// unknownHistory is the reason returned by maybeSent for the work items failed before the audit was recorded.
+const unknownHistory = "unknown history"
+
+// quarantineCmd represents the quarantine command
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "Manage the failed work items moved to quarantine.",
+	Long: `The quarantine commands manage the state files of the failed work items moved to the quarantine directory.
+
+Requeued and purged work items are moved to the archive directory of the quarantine,
+and the action is recorded in its audit log.`,
+}
+
+var quarantineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the work items in quarantine.",
+	RunE:  QuarantineListCmdRunE,
+}
+
+var quarantineShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show a work item in quarantine, with its history.",
+	RunE:  QuarantineShowCmdRunE,
+}
+
+var quarantineRequeueCmd = &cobra.Command{
+	Use:   "requeue",
+	Short: "Claim a work item in quarantine again, so it can be migrated.",
+	RunE:  QuarantineRequeueCmdRunE,
+}
+
+var quarantineDirCmd = &cobra.Command{
+	Use:   "dir",
+	Short: "Print the quarantine directory of the neighborhood.",
+	RunE:  QuarantineDirCmdRunE,
+}
+
+var quarantinePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Archive resolved work items from the quarantine.",
+	RunE:  QuarantinePurgeCmdRunE,
+}
+
+func init() {
+	SetupQuarantineCmdFlags(quarantineCmd)
+	SetupQuarantineShowCmdFlags(quarantineShowCmd)
+	SetupQuarantineRequeueCmdFlags(quarantineRequeueCmd)
+	SetupQuarantinePurgeCmdFlags(quarantinePurgeCmd)
+	quarantineCmd.AddCommand(quarantineListCmd, quarantineShowCmd, quarantineRequeueCmd, quarantinePurgeCmd, quarantineDirCmd)
+	rootCmd.AddCommand(quarantineCmd)
+}
+
+// openQuarantine returns the quarantine of the neighborhood, see neighborhoodDir.
+func openQuarantine() *quarantine.Quarantine {
+	return quarantine.New(neighborhoodDir(viper.GetString("quarantine-dir"), viper.GetUint64("neighborhood")))
+}
+
+func SetupQuarantineCmdFlags(command *cobra.Command) {
+	command.PersistentFlags().String("quarantine-dir", "/quarantine", "Directory holding the work items in quarantine")
+	if err := viper.BindPFlag("quarantine-dir", command.PersistentFlags().Lookup("quarantine-dir")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
+
+func SetupQuarantineShowCmdFlags(command *cobra.Command) {
+	command.Flags().String("uuid", "", "UUID of the work item to show")
+	if err := viper.BindPFlag("quarantine-show-uuid", command.Flags().Lookup("uuid")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+	if err := command.MarkFlagRequired("uuid"); err != nil {
+		slog.Error(ErrorMarkingFlagRequired, "error", err)
+	}
+}
+
+func SetupQuarantineRequeueCmdFlags(command *cobra.Command) {
+	command.Flags().String("uuid", "", "UUID of the work item to requeue")
+	if err := viper.BindPFlag("quarantine-requeue-uuid", command.Flags().Lookup("uuid")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+	if err := command.MarkFlagRequired("uuid"); err != nil {
+		slog.Error(ErrorMarkingFlagRequired, "error", err)
+	}
+
+	command.Flags().String("reason", "", "Reason of the requeue, recorded in the audit log")
+	if err := viper.BindPFlag("quarantine-requeue-reason", command.Flags().Lookup("reason")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().BoolP("yes", "y", false, "Requeue without asking for confirmation")
+	if err := viper.BindPFlag("quarantine-requeue-yes", command.Flags().Lookup("yes")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().Bool("i-reconciled-on-chain", false, "Requeue a work item with an unknown history, after checking on chain that its tokens were not sent")
+	if err := viper.BindPFlag("quarantine-requeue-reconciled", command.Flags().Lookup("i-reconciled-on-chain")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
+
+func SetupQuarantinePurgeCmdFlags(command *cobra.Command) {
+	command.Flags().String("uuid", "", "UUID of the work item to purge")
+	if err := viper.BindPFlag("quarantine-purge-uuid", command.Flags().Lookup("uuid")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().Bool("all", false, "Purge all the work items in quarantine")
+	if err := viper.BindPFlag("quarantine-purge-all", command.Flags().Lookup("all")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().String("reason", "", "Reason of the purge, recorded in the audit log")
+	if err := viper.BindPFlag("quarantine-purge-reason", command.Flags().Lookup("reason")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().BoolP("yes", "y", false, "Purge without asking for confirmation")
+	if err := viper.BindPFlag("quarantine-purge-yes", command.Flags().Lookup("yes")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
+
+func QuarantineListCmdRunE(cmd *cobra.Command, args []string) error {
+	q := openQuarantine()
+	items, err := q.List()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(items) == 0 {
+		fmt.Fprintln(out, "No work items in quarantine")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tSTATUS\tCLASS\tATTEMPTS\tUPDATED\tERROR")
+	for _, item := range items {
+		var attempts int
+		var updated string
+		if item.Audit != nil {
+			attempts = item.Audit.Attempts
+			if n := len(item.Audit.History); n > 0 {
+				updated = item.Audit.History[n-1].Time.Format(time.RFC3339)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", item.UUID, item.Status, item.ErrorClass, attempts, updated, summarize(item.Error))
+	}
+	return w.Flush()
+}
+
+func QuarantineShowCmdRunE(cmd *cobra.Command, args []string) error {
+	id, err := uuid.Parse(viper.GetString("quarantine-show-uuid"))
+	if err != nil {
+		return errors.WithMessage(err, "could not parse UUID")
+	}
+
+	q := openQuarantine()
+	item, err := q.Get(id)
+	if err != nil {
+		return err
+	}
+
+	entries, err := q.History()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "UUID:             %s\n", item.UUID)
+	fmt.Fprintf(out, "Status:           %s\n", item.Status)
+	fmt.Fprintf(out, "Error class:      %s\n", item.ErrorClass)
+	if item.Error != nil {
+		fmt.Fprintf(out, "Error:            %s\n", *item.Error)
+	}
+	fmt.Fprintf(out, "MANY hash:        %s\n", item.ManyHash)
+	fmt.Fprintf(out, "Manifest address: %s\n", item.ManifestAddress)
+	if item.ManifestHash != nil {
+		fmt.Fprintf(out, "Manifest hash:    %s\n", *item.ManifestHash)
+	}
+
+	if audit := item.Audit; audit != nil {
+		if audit.LastAttempt != nil {
+			fmt.Fprintf(out, "Attempts:         %d, last at %s\n", audit.Attempts, audit.LastAttempt.Format(time.RFC3339))
+		}
+		if audit.Policy != nil {
+			fmt.Fprintf(out, "Policy:           %s by rule %s at %s: %s\n", audit.Policy.Action, audit.Policy.Rule, audit.Policy.Time.Format(time.RFC3339), audit.Policy.Reason)
+		}
+		fmt.Fprintln(out, "History:")
+		for _, change := range audit.History {
+			fmt.Fprintf(out, "  %s  %s\n", change.Time.Format(time.RFC3339), change.Status)
+		}
+	}
+
+	// Previous quarantines of the work item
+	for _, entry := range entries {
+		if entry.UUID == item.UUID {
+			fmt.Fprintf(out, "Previously %sd at %s by %s: %s\n", entry.Action, entry.Time.Format(time.RFC3339), entry.User, entry.Reason)
+		}
+	}
+
+	return nil
+}
+
+func QuarantineRequeueCmdRunE(cmd *cobra.Command, args []string) error {
+	c := LoadConfigFromCLI("quarantine-requeue-uuid")
+	slog.Debug("args", "c", c)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	authConfig, err := LoadAuthConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	slog.Debug("args", "auth-c", authConfig)
+	if err := authConfig.Validate(); err != nil {
+		return err
+	}
+
+	httpConfig := LoadHttpConfigFromCLI()
+	slog.Debug("args", "http-c", httpConfig)
+	if err := httpConfig.Validate(); err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.UUID)
+	if err != nil {
+		return errors.WithMessage(err, "could not parse UUID")
+	}
+
+	q := openQuarantine()
+	item, err := q.Get(id)
+	if err != nil {
+		return err
+	}
+
+	// The claimed work item would be sent the tokens without looking for a previous transfer
+	switch reason := maybeSent(item); {
+	case reason == unknownHistory && viper.GetBool("quarantine-requeue-reconciled"):
+		slog.Warn("Requeuing work item with an unknown history, reconciled on chain", "uuid", item.UUID)
+	case reason == unknownHistory:
+		return fmt.Errorf("work item %s may have been sent the tokens (%s), it cannot be requeued: check on chain that its tokens were not sent, then requeue it with --i-reconciled-on-chain", item.UUID, reason)
+	case reason != "":
+		return fmt.Errorf("work item %s may have been sent the tokens (%s), it cannot be requeued: reconcile it on chain, then purge it", item.UUID, reason)
+	}
+
+	prompt := fmt.Sprintf("Claim work item %s (%s, %s) again? The migration will be run again.", item.UUID, item.Status, item.ErrorClass)
+	if err := confirm(cmd, viper.GetBool("quarantine-requeue-yes"), prompt); err != nil {
+		return errors.WithMessage(err, "requeue aborted")
+	}
+
+	ctx, err := stateContext(cmd.Context(), c.Neighborhood)
+	if err != nil {
+		return err
+	}
+
+	r, err := CreateRestClient(ctx, c.Url, c.Neighborhood, httpConfig)
+	if err != nil {
+		return err
+	}
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
+		return err
+	}
+
+	// Force the claim, the work item is failed
+	if _, err := store.ClaimWorkItemFromUUID(ctx, r, id, true); err != nil {
+		return errors.WithMessage(err, "could not claim work item")
+	}
+
+	if err := q.Archive(id, quarantine.Requeue, viper.GetString("quarantine-requeue-reason"), time.Now()); err != nil {
+		return err
+	}
+
+	slog.Info("Work item requeued", "uuid", id)
+	return nil
+}
+
+// maybeSent returns why the tokens of the work item may have been sent, empty if they were not.
+// The work items failed before the audit and the error class were recorded have an unknown history.
+func maybeSent(item *store.WorkItem) string {
+	if item.ErrorClass == errclass.Intervention {
+		return "operator intervention required"
+	}
+	if item.Status == store.MIGRATING {
+		return "left migrating"
+	}
+	if item.Audit == nil || (item.Status == store.FAILED && item.ErrorClass == "") {
+		return unknownHistory
+	}
+	for _, change := range item.Audit.History {
+		if change.Status == store.MIGRATING {
+			return "previously migrating"
+		}
+	}
+	for _, b := range item.Audit.Broadcasts {
+		if !b.Rejected {
+			return "transaction broadcast"
+		}
+	}
+	return ""
+}
+
+func QuarantineDirCmdRunE(cmd *cobra.Command, args []string) error {
+	dir, err := filepath.Abs(openQuarantine().Dir)
+	if err != nil {
+		return errors.WithMessage(err, "could not resolve quarantine directory")
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), dir)
+	return nil
+}
+
+func QuarantinePurgeCmdRunE(cmd *cobra.Command, args []string) error {
+	q := openQuarantine()
+
+	var ids []uuid.UUID
+	switch uuidStr, all := viper.GetString("quarantine-purge-uuid"), viper.GetBool("quarantine-purge-all"); {
+	case uuidStr != "" && all:
+		return fmt.Errorf("--uuid and --all are mutually exclusive")
+	case uuidStr != "":
+		id, err := uuid.Parse(uuidStr)
+		if err != nil {
+			return errors.WithMessage(err, "could not parse UUID")
+		}
+		if _, err := q.Get(id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	case all:
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			ids = append(ids, item.UUID)
+		}
+	default:
+		return fmt.Errorf("--uuid or --all is required")
+	}
+
+	if len(ids) == 0 {
+		slog.Info("No work items in quarantine")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Archive %d work item(s) as resolved?", len(ids))
+	if err := confirm(cmd, viper.GetBool("quarantine-purge-yes"), prompt); err != nil {
+		return errors.WithMessage(err, "purge aborted")
+	}
+
+	reason := viper.GetString("quarantine-purge-reason")
+	for _, id := range ids {
+		if err := q.Archive(id, quarantine.Purge, reason, time.Now()); err != nil {
+			return err
+		}
+		slog.Info("Work item purged", "uuid", id)
+	}
+
+	return nil
+}
+
+// confirm asks the operator to confirm an action, unless it was confirmed beforehand.
+// It returns an error if the action was not confirmed.
+func confirm(cmd *cobra.Command, confirmed bool, prompt string) error {
+	if confirmed {
+		return nil
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.WithMessage(err, "could not read confirmation")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("not confirmed")
+	}
+}
+
+// summarize returns the first line of the error, truncated.
+func summarize(err *string) string {
+	if err == nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(*err, "\n")
+	if len(line) > maxErrorLen {
+		line = line[:maxErrorLen-3] + "..."
+	}
+	return line
+}
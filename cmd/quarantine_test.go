@@ -0,0 +1,159 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/quarantine"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+)
+
+func TestQuarantineCmd(t *testing.T) {
+	tmpdir := t.TempDir()
+	quarantineDir := filepath.Join(tmpdir, "quarantine")
+	require.NoError(t, os.Mkdir(quarantineDir, 0o755))
+
+	// Quarantine a work item
+	require.NoError(t, os.Chdir(quarantineDir))
+	testutils.SetupWorkItem(t)
+	require.NoError(t, os.Chdir(tmpdir))
+
+	quarantinedPath := filepath.Join(quarantineDir, testutils.DummyUUIDStr+".json")
+	workItemPath := filepath.Join(tmpdir, testutils.DummyUUIDStr+".json")
+
+	args := []string{"--url", testutils.RootUrl, "--username", "user", "--password", "pass", "--neighborhood", "1", "--quarantine-dir", quarantineDir}
+
+	tt := []struct {
+		name      string
+		args      []string
+		stdin     string
+		err       string
+		expected  string
+		endpoints []testutils.HttpResponder
+		check     func(t *testing.T)
+	}{
+		{name: "list", args: append([]string{"list"}, args...), expected: testutils.DummyUUIDStr},
+		{name: "show", args: append([]string{"show", "--uuid", testutils.DummyUUIDStr}, args...), expected: "Status:           claimed"},
+		{name: "show unknown", args: append([]string{"show", "--uuid", "00000000-0000-4000-8000-000000000000"}, args...), err: "not found in quarantine"},
+		{name: "requeue unknown history", args: append([]string{"requeue", "--uuid", testutils.DummyUUIDStr, "--yes"}, args...), err: "requeue it with --i-reconciled-on-chain", check: func(t *testing.T) {
+			require.FileExists(t, quarantinedPath)
+		}},
+		{name: "requeue not confirmed", args: append([]string{"requeue", "--uuid", testutils.DummyUUIDStr, "--i-reconciled-on-chain"}, args...), stdin: "n\n", err: "requeue aborted: not confirmed", check: func(t *testing.T) {
+			require.FileExists(t, quarantinedPath)
+		}},
+		{name: "requeue", args: append([]string{"requeue", "--uuid", testutils.DummyUUIDStr, "--reason", "node outage", "--i-reconciled-on-chain"}, args...), stdin: "y\n", endpoints: []testutils.HttpResponder{
+			{Method: "POST", Url: testutils.LoginUrl, Responder: testutils.AuthResponder},
+			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.MigrationClaimOneResponder(store.CLAIMED)},
+		}, check: func(t *testing.T) {
+			require.NoFileExists(t, quarantinedPath)
+			require.FileExists(t, workItemPath)
+
+			entries, err := quarantine.New(quarantineDir).History()
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			require.Equal(t, quarantine.Requeue, entries[0].Action)
+			require.Equal(t, "node outage", entries[0].Reason)
+		}},
+		{name: "list empty", args: append([]string{"list"}, args...), expected: "No work items in quarantine"},
+		{name: "purge nothing", args: append([]string{"purge"}, args...), err: "--uuid or --all is required"},
+	}
+
+	for _, tc := range tt {
+		command := &cobra.Command{Use: "quarantine", PersistentPreRunE: cmd.RootCmdPersistentPreRunE}
+		show := &cobra.Command{Use: "show", RunE: cmd.QuarantineShowCmdRunE}
+		requeue := &cobra.Command{Use: "requeue", RunE: cmd.QuarantineRequeueCmdRunE}
+		purge := &cobra.Command{Use: "purge", RunE: cmd.QuarantinePurgeCmdRunE}
+		command.AddCommand(&cobra.Command{Use: "list", RunE: cmd.QuarantineListCmdRunE}, show, requeue, purge)
+
+		// Create a new resty client and inject it into the command context
+		client := resty.New()
+		ctx := context.WithValue(context.Background(), cmd.RestyClientKey, client)
+		command.SetContext(ctx)
+		command.SetIn(strings.NewReader(tc.stdin))
+
+		// Enable http mocking on the resty client
+		httpmock.ActivateNonDefault(client.GetClient())
+		cmd.SetupRootCmdFlags(command)
+		cmd.SetupQuarantineCmdFlags(command)
+		cmd.SetupQuarantineShowCmdFlags(show)
+		cmd.SetupQuarantineRequeueCmdFlags(requeue)
+		cmd.SetupQuarantinePurgeCmdFlags(purge)
+
+		t.Run(tc.name, func(t *testing.T) {
+			for _, endpoint := range tc.endpoints {
+				httpmock.RegisterResponder(endpoint.Method, endpoint.Url, endpoint.Responder)
+			}
+
+			out, err := testutils.Execute(t, command, tc.args...)
+			t.Log(out)
+
+			if tc.err == "" {
+				require.NoError(t, err)
+				require.Contains(t, out, tc.expected)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+			if tc.check != nil {
+				tc.check(t)
+			}
+			httpmock.Reset()
+		})
+	}
+}
+
+// TestQuarantineCmd_RequeueMaybeSent refuses to requeue the work items whose tokens may have been sent, as the claimed
+// work item would be sent the tokens again.
+func TestQuarantineCmd_RequeueMaybeSent(t *testing.T) {
+	tt := []struct {
+		name   string
+		item   store.WorkItem
+		reason string
+	}{
+		{name: "intervention", item: store.WorkItem{Status: store.FAILED, ErrorClass: errclass.Intervention}, reason: "operator intervention required"},
+		{name: "migrating", item: store.WorkItem{Status: store.MIGRATING}, reason: "left migrating"},
+		{name: "previously migrating", item: store.WorkItem{Status: store.FAILED, ErrorClass: errclass.Terminal, Audit: &store.Audit{
+			History: []store.StatusChange{{Status: store.CLAIMED}, {Status: store.MIGRATING}, {Status: store.FAILED}},
+		}}, reason: "previously migrating"},
+		{name: "broadcast", item: store.WorkItem{Status: store.FAILED, ErrorClass: errclass.Terminal, Audit: &store.Audit{
+			Broadcasts: []store.Broadcast{{TxHash: "0A", Coin: "123umfx"}},
+		}}, reason: "transaction broadcast"},
+		{name: "no audit", item: store.WorkItem{Status: store.FAILED, ErrorClass: errclass.Terminal}, reason: "unknown history"},
+		{name: "no error class", item: store.WorkItem{Status: store.FAILED, Audit: &store.Audit{
+			History: []store.StatusChange{{Status: store.CLAIMED}, {Status: store.FAILED}},
+		}}, reason: "unknown history"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			quarantineDir := t.TempDir()
+			require.NoError(t, os.Chdir(t.TempDir()))
+
+			item := tc.item
+			item.UUID = uuid.MustParse(testutils.DummyUUIDStr)
+			require.NoError(t, store.SaveState(store.WithStateDir(context.Background(), quarantineDir), &item))
+
+			command := &cobra.Command{Use: "requeue", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.QuarantineRequeueCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupQuarantineCmdFlags(command)
+			cmd.SetupQuarantineRequeueCmdFlags(command)
+
+			_, err := testutils.Execute(t, command, "--uuid", testutils.DummyUUIDStr, "--yes", "--url", testutils.RootUrl,
+				"--username", "user", "--password", "pass", "--neighborhood", "1", "--quarantine-dir", quarantineDir)
+			require.ErrorContains(t, err, "may have been sent the tokens ("+tc.reason+"), it cannot be requeued")
+			require.FileExists(t, filepath.Join(quarantineDir, testutils.DummyUUIDStr+".json"))
+		})
+	}
+}
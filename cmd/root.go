@@ -1,17 +1,27 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 	"github.com/manifest-network/mfx-migrator/internal/utils"
 )
 
+// tracingShutdownTimeout is the time spent exporting the remaining spans when the command exits.
+const tracingShutdownTimeout = 5 * time.Second
+
 var rootCmd = &cobra.Command{
 	Use:               "mfx-migrator",
 	Short:             "Migrate your MFX tokens to the Manifest Ledger",
@@ -19,13 +29,27 @@ var rootCmd = &cobra.Command{
 }
 
 func RootCmdPersistentPreRunE(cmd *cobra.Command, args []string) error {
-	logLevelArg := viper.GetString("logLevel")
+	if err := applyProfile(viper.GetString("profile")); err != nil {
+		return err
+	}
+
+	logLevelArg := viper.GetString("log-level")
 	urlString := viper.GetString("url")
 	if err := setLogLevel(logLevelArg); err != nil {
 		return err
 	}
 
-	slog.Debug("Application initialized", "logLevel", logLevelArg, "url", urlString)
+	slog.Debug("Application initialized", "logLevel", logLevelArg, "url", urlString, "profile", viper.GetString("profile"), "stateDir", store.StateDir(cmd.Context()))
+
+	if metricsListen := viper.GetString("metrics-listen"); metricsListen != "" {
+		if _, err := metrics.Serve(cmd.Context(), metricsListen); err != nil {
+			return err
+		}
+	}
+
+	if err := tracing.Setup(cmd.Context(), viper.GetString("otlp-endpoint"), Version); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -33,15 +57,22 @@ func RootCmdPersistentPreRunE(cmd *cobra.Command, args []string) error {
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := viper.ReadInConfig(); err == nil {
-		slog.Info("Using config file", "file", viper.ConfigFileUsed())
-	} else {
-		slog.Info("No config file found")
+	err := LoadConfigFile()
+	if err == nil {
+		err = rootCmd.Execute()
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		slog.Error("An error occurred", "error", err)
-		os.Exit(1)
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if tErr := tracing.Shutdown(ctx); tErr != nil {
+		slog.Warn("Could not export traces", "error", tErr)
+	}
+
+	// The exit code tells whether the failed command can be retried, see errclass
+	if err != nil {
+		code := errclass.ExitCode(err)
+		slog.Error("An error occurred", "exitCode", code, "error", err)
+		os.Exit(code)
 	}
 }
 
@@ -57,7 +88,17 @@ var (
 
 func SetupRootCmdFlags(command *cobra.Command) {
 	command.PersistentFlags().StringP("logLevel", "l", "info", fmt.Sprintf("set log level (%s)", validLogLevelsStr))
-	if err := viper.BindPFlag("logLevel", command.PersistentFlags().Lookup("logLevel")); err != nil {
+	if err := viper.BindPFlag("log-level", command.PersistentFlags().Lookup("logLevel")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("profile", "", "Name of the profile of the configuration file to use")
+	if err := viper.BindPFlag("profile", command.PersistentFlags().Lookup("profile")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("state-dir", ".", "Directory holding the state files of the work items, in a subdirectory named after the profile with a profile")
+	if err := viper.BindPFlag("state-dir", command.PersistentFlags().Lookup("state-dir")); err != nil {
 		slog.Error(ErrorBindingFlag, "error", err)
 	}
 
@@ -76,11 +117,86 @@ func SetupRootCmdFlags(command *cobra.Command) {
 		slog.Error(ErrorBindingFlag, "error", err)
 	}
 
-	command.PersistentFlags().String("password", "", "Password for the remote database")
+	command.PersistentFlags().String("password", "", "Password for the remote database (visible in process listings, prefer --password-file)")
 	if err := viper.BindPFlag("password", command.PersistentFlags().Lookup("password")); err != nil {
 		slog.Error(ErrorBindingFlag, "error", err)
 	}
 
+	command.PersistentFlags().String("username-file", "", "File holding the username for the remote database")
+	if err := viper.BindPFlag("username-file", command.PersistentFlags().Lookup("username-file")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("password-file", "", "File holding the password for the remote database, must not be accessible by group or others")
+	if err := viper.BindPFlag("password-file", command.PersistentFlags().Lookup("password-file")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("credential-helper", "", "Command printing the remote database credentials, invoked as `<helper> get talib-username|talib-password`")
+	if err := viper.BindPFlag("credential-helper", command.PersistentFlags().Lookup("credential-helper")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Int("http-retry-count", 20, "Maximum number of retries of a remote database request")
+	if err := viper.BindPFlag("http-retry-count", command.PersistentFlags().Lookup("http-retry-count")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Duration("http-retry-wait", 10*time.Second, "Initial wait time between remote database request retries, increased exponentially")
+	if err := viper.BindPFlag("http-retry-wait", command.PersistentFlags().Lookup("http-retry-wait")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Duration("http-retry-max-wait", 2*time.Minute, "Maximum wait time between remote database request retries")
+	if err := viper.BindPFlag("http-retry-max-wait", command.PersistentFlags().Lookup("http-retry-max-wait")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Duration("http-retry-budget", 15*time.Minute, "Maximum time a command spends on remote database requests being retried (0 for no limit)")
+	if err := viper.BindPFlag("http-retry-budget", command.PersistentFlags().Lookup("http-retry-budget")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Duration("http-timeout", 45*time.Second, "Timeout of a single remote database request")
+	if err := viper.BindPFlag("http-timeout", command.PersistentFlags().Lookup("http-timeout")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("ca-file", "", "PEM bundle of additional certificate authorities trusted for the remote database")
+	if err := viper.BindPFlag("ca-file", command.PersistentFlags().Lookup("ca-file")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("client-cert", "", "PEM client certificate for mutual TLS with the remote database")
+	if err := viper.BindPFlag("client-cert", command.PersistentFlags().Lookup("client-cert")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("client-key", "", "PEM client key for mutual TLS with the remote database")
+	if err := viper.BindPFlag("client-key", command.PersistentFlags().Lookup("client-key")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("tls-min-version", "", "Minimum TLS version for the remote database (1.2|1.3), 1.2 if empty")
+	if err := viper.BindPFlag("tls-min-version", command.PersistentFlags().Lookup("tls-min-version")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().StringSlice("tls-pin", nil, "Remote database certificate public key pin (sha256//<base64>), can be repeated")
+	if err := viper.BindPFlag("tls-pin", command.PersistentFlags().Lookup("tls-pin")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("metrics-listen", "", "Address to serve the Prometheus metrics on, e.g. :9090 (disabled if empty)")
+	if err := viper.BindPFlag("metrics-listen", command.PersistentFlags().Lookup("metrics-listen")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("otlp-endpoint", "", "OTLP/HTTP traces endpoint of the trace collector, e.g. http://localhost:4318/v1/traces (disabled if empty)")
+	if err := viper.BindPFlag("otlp-endpoint", command.PersistentFlags().Lookup("otlp-endpoint")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
 	command.SilenceUsage = true
 	command.SilenceErrors = true
 }
@@ -93,6 +209,17 @@ func init() {
 	viper.SetConfigName("migrator-config")
 
 	viper.AutomaticEnv()
+	// The environment variable of the log level predates the renaming of its key
+	if err := viper.BindEnv("log-level", "LOGLEVEL"); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+	// USERNAME and PASSWORD are common environment variables, e.g. USERNAME is set on Windows, they are deprecated in
+	// favor of TALIB_USERNAME and TALIB_PASSWORD, see credentialValue
+	for key, env := range credentialEnvs {
+		if err := viper.BindEnv(key, env.name, env.deprecated); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
 }
 
 // setLogLevel sets the log level
@@ -103,7 +230,8 @@ func setLogLevel(logLevel string) error {
 	}
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
+		Level:       level,
+		ReplaceAttr: secrets.ReplaceAttr,
 	}))
 	slog.SetDefault(logger)
 
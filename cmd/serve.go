@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/manifest-network/mfx-migrator/internal/admin"
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/notify"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Claim and migrate the work items of one or more neighborhoods, until stopped.",
+	Long: `The serve command runs a claim loop per neighborhood. Every poll interval, each loop claims the work items of its
+neighborhood from the work queue, then migrates the claimed work items of the neighborhood, like the claim and migrate commands.
+
+The neighborhoods are listed in the neighborhoods key of the configuration file, each with its own token map and limits.
+Without neighborhoods, the neighborhood of the --neighborhood flag is served.
+
+The work items failing terminally, or requiring an operator intervention, are moved to the quarantine directory.
+
+The admin API, served on the --admin-listen address, lets an operator pause and resume the claims or the sends of tokens,
+drain the migrator, list the work items in flight, and reconcile or verify a single work item.
+
+It accepts the flags of the migrate command, but the UUID and the dry run.`,
+	RunE: ServeCmdRunE,
+}
+
+func init() {
+	SetupServeCmdFlags(serveCmd, migrateCmd, quarantineCmd)
+	rootCmd.AddCommand(serveCmd)
+}
+
+// SetupServeCmdFlags shares the flags of the migrate command, but the UUID and the dry run, and the quarantine directory
+// flag of the quarantine command with the serve command.
+// The flags are bound to the same configuration keys, whichever command is run.
+func SetupServeCmdFlags(command *cobra.Command, migrateCommand *cobra.Command, quarantineCommand *cobra.Command) {
+	migrateCommand.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "uuid" && flag.Name != "dry-run" {
+			command.Flags().AddFlag(flag)
+		}
+	})
+	command.Flags().AddFlag(quarantineCommand.PersistentFlags().Lookup("quarantine-dir"))
+
+	command.Flags().Duration("poll-interval", time.Minute, "Wait time between two claims from the work queue of a neighborhood")
+	if err := viper.BindPFlag("poll-interval", command.Flags().Lookup("poll-interval")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.Flags().Duration("shutdown-timeout", 5*time.Minute, "Maximum wait time for the migrations in flight on SIGINT or SIGTERM, before interrupting them")
+	if err := viper.BindPFlag("shutdown-timeout", command.Flags().Lookup("shutdown-timeout")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	setupAdminCmdFlags(command)
+}
+
+func ServeCmdRunE(cmd *cobra.Command, args []string) error {
+	c := LoadConfigFromCLI("")
+	slog.Debug("args", "c", c)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	baseConfig, err := loadBaseMigrationConfig()
+	if err != nil {
+		return err
+	}
+
+	neighborhoods, err := LoadNeighborhoodsFromCLI()
+	if err != nil {
+		return err
+	}
+	if len(neighborhoods) == 0 {
+		neighborhoods = []config.NeighborhoodConfig{{ID: c.Neighborhood}}
+	}
+	if err := config.ValidateNeighborhoods(neighborhoods); err != nil {
+		return err
+	}
+
+	pollInterval := viper.GetDuration("poll-interval")
+	if pollInterval <= 0 {
+		return fmt.Errorf("poll interval > 0 is required")
+	}
+
+	shutdownTimeout := viper.GetDuration("shutdown-timeout")
+	if shutdownTimeout < 0 {
+		return fmt.Errorf("shutdown timeout >= 0 is required")
+	}
+
+	notifyConfig, err := LoadNotifyConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	notifier, err := notify.New(notifyConfig)
+	if err != nil {
+		return errors.WithMessage(err, "invalid notification configuration")
+	}
+	defer notifier.Close()
+
+	authConfig, err := LoadAuthConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	slog.Debug("args", "auth-c", authConfig)
+	if err := authConfig.Validate(); err != nil {
+		return err
+	}
+
+	httpConfig := LoadHttpConfigFromCLI()
+	slog.Debug("args", "http-c", httpConfig)
+	if err := httpConfig.Validate(); err != nil {
+		return err
+	}
+
+	// Every neighborhood must be valid before any is served
+	controller := admin.New()
+	loops := make(map[uint64]*neighborhoodLoop, len(neighborhoods))
+	for _, n := range neighborhoods {
+		migrateConfig := n.Apply(baseConfig)
+		slog.Debug("args", "neighborhood", n.ID, "migrate-c", migrateConfig)
+		if err := migrateConfig.Validate(); err != nil {
+			return errors.WithMessagef(err, "invalid configuration of neighborhood %d", n.ID)
+		}
+
+		stateCtx, err := stateContext(cmd.Context(), n.ID)
+		if err != nil {
+			return err
+		}
+
+		loop := &neighborhoodLoop{
+			stateDir:      store.StateDir(stateCtx),
+			neighborhood:  n.ID,
+			url:           c.Url,
+			httpConfig:    httpConfig,
+			authConfig:    authConfig,
+			migrateConfig: migrateConfig,
+			notifier:      notifier,
+			pollInterval:  pollInterval,
+			quarantineDir: neighborhoodDir(viper.GetString("quarantine-dir"), n.ID),
+			controller:    controller,
+		}
+		if n.PollInterval > 0 {
+			loop.pollInterval = n.PollInterval
+		}
+		loops[n.ID] = loop
+	}
+
+	signalCtx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A signal drains the migrator, the migrations in flight are interrupted only past the shutdown timeout
+	ctx, cancel := context.WithCancel(context.WithoutCancel(signalCtx))
+	defer cancel()
+	go func() {
+		select {
+		case <-signalCtx.Done():
+		case <-ctx.Done():
+			return
+		}
+		slog.Info("Stopping, finishing the migrations in flight", "timeout", shutdownTimeout)
+		controller.Drain()
+
+		timer := time.NewTimer(shutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			slog.Warn("Migrations in flight not finished in time, interrupting them", "inFlight", len(controller.Status().InFlight))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if adminListen := viper.GetString("admin-listen"); adminListen != "" {
+		token, err := LoadAdminTokenFromCLI()
+		if err != nil {
+			return err
+		}
+		handler := admin.Handler(controller, adminActions{controller: controller, loops: loops}, token)
+		if _, err := admin.Serve(ctx, adminListen, handler); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, loop := range loops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if controller.Status().Draining {
+		slog.Info("Drained")
+	}
+	slog.Info("Stopped serving")
+	return nil
+}
+
+// neighborhoodLoop claims and migrates the work items of a neighborhood.
+type neighborhoodLoop struct {
+	neighborhood  uint64
+	stateDir      string // Directory holding the state files of the neighborhood, see stateContext
+	url           string
+	httpConfig    config.HttpConfig
+	authConfig    config.AuthConfig
+	migrateConfig config.MigrateConfig
+	notifier      *notify.Notifier
+	pollInterval  time.Duration // Wait time between two claims from the work queue
+	quarantineDir string        // Directory the failed work items are moved to
+	controller    *admin.Controller
+}
+
+// run polls the work queue of the neighborhood until the context is done, or the controller is draining.
+func (l *neighborhoodLoop) run(ctx context.Context) {
+	slog.Info("Serving neighborhood", "neighborhood", l.neighborhood, "pollInterval", l.pollInterval, "stateDir", l.stateDir)
+
+	ctx = store.WithStateDir(ctx, l.stateDir)
+	for {
+		if err := l.poll(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Could not serve neighborhood", "neighborhood", l.neighborhood, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.controller.Draining():
+			return
+		case <-time.After(l.pollInterval):
+		}
+	}
+}
+
+// poll claims the work items of the neighborhood from the work queue, unless the claims are paused, then migrates the
+// claimed work items, including the ones claimed by a previous poll and waiting for their next attempt.
+// No migration is started once the controller is draining.
+func (l *neighborhoodLoop) poll(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "serve", trace.WithAttributes(attribute.Int64("neighborhood", int64(l.neighborhood))))
+	defer func() { tracing.End(span, err) }()
+
+	r, err := l.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	if l.controller.ClaimsPaused() {
+		slog.Debug("Claims paused", "neighborhood", l.neighborhood)
+	} else if _, err := claimWorkItem(ctx, r, "", config.ClaimConfig{}); err != nil {
+		return err
+	}
+
+	items, err := store.ReadStates(store.StateDir(ctx))
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		select {
+		case <-l.controller.Draining():
+			return nil
+		default:
+		}
+		l.migrate(ctx, r, item)
+	}
+
+	return nil
+}
+
+// client returns a client of the remote database of the neighborhood, authenticated with the credentials.
+func (l *neighborhoodLoop) client(ctx context.Context) (*resty.Client, error) {
+	r, err := CreateRestClient(ctx, l.url, l.neighborhood, l.httpConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := AuthenticateRestClient(ctx, r, l.authConfig.Username, l.authConfig.Password); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// migrate migrates the claimed work item. The work item is moved to the quarantine if the migration failed terminally
+// or requires an operator intervention, and left for the next poll if it failed temporarily.
+// The work item is skipped if an operator is handling it through the admin API.
+func (l *neighborhoodLoop) migrate(ctx context.Context, r *resty.Client, item *store.WorkItem) {
+	ctx, done, ok := l.controller.Track(ctx, l.neighborhood, item.UUID)
+	if !ok {
+		slog.Debug("Work item in flight, skipped", "neighborhood", l.neighborhood, "uuid", item.UUID)
+		return
+	}
+	defer done()
+
+	// The local state may have been updated by an operator since it was read
+	item, err := store.LoadState(ctx, item.UUID.String())
+	if err != nil {
+		slog.Debug("Work item no longer claimed, skipped", "neighborhood", l.neighborhood, "error", err)
+		return
+	}
+
+	// Continue the trace of the work item started when it was claimed
+	if item.Audit != nil {
+		ctx = tracing.Extract(ctx, item.Audit.TraceContext)
+	}
+	ctx, span := tracing.Start(ctx, "migrate", trace.WithAttributes(attribute.String("uuid", item.UUID.String())))
+
+	checked, err := checkWorkItem(ctx, item, l.migrateConfig, false)
+	if !checked {
+		err = migrateWorkItem(ctx, r, l.notifier, l.migrateConfig, item)
+	}
+	tracing.End(span, err)
+
+	if err == nil {
+		return
+	}
+	if class := errclass.Of(err); class == errclass.Transient {
+		slog.Debug("Migration postponed to the next poll", "neighborhood", l.neighborhood, "uuid", item.UUID, "error", err)
+		return
+	}
+
+	slog.Warn("Moving work item to quarantine", "neighborhood", l.neighborhood, "uuid", item.UUID, "error", err)
+	if err := l.quarantine(ctx, item); err != nil {
+		slog.Error("Could not move work item to quarantine", "neighborhood", l.neighborhood, "uuid", item.UUID, "error", err)
+	}
+}
+
+// quarantine moves the state file of the work item to the quarantine directory.
+func (l *neighborhoodLoop) quarantine(ctx context.Context, item *store.WorkItem) error {
+	if err := os.MkdirAll(l.quarantineDir, 0o755); err != nil {
+		return errors.WithMessage(err, "could not create quarantine directory")
+	}
+
+	path := store.StatePath(ctx, item.UUID.String())
+	return os.Rename(path, filepath.Join(l.quarantineDir, filepath.Base(path)))
+}
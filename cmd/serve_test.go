@@ -0,0 +1,345 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+// setNeighborhoods sets the neighborhoods served by the migrator for the duration of the test, if any.
+func setNeighborhoods(t *testing.T, neighborhoods []map[string]any) {
+	if neighborhoods == nil {
+		return
+	}
+	t.Cleanup(func() { viper.Set("neighborhoods", nil) })
+	viper.Set("neighborhoods", neighborhoods)
+}
+
+// TestServe serves two neighborhoods of a fake talib, with their own policy, until their work items are handled.
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("token-map", map[string]utils.TokenInfo{
+		testutils.ManySymbol: {Denom: "umfx"},
+	})
+	setNeighborhoods(t, []map[string]any{
+		{"id": 1},
+		{"id": 2, "policy": map[string]any{"default-action": "reject"}, "poll-interval": "20ms"},
+	})
+
+	talib := faketalib.New("user", "pass")
+	server := httptest.NewServer(talib)
+	defer server.Close()
+
+	migration := faketalib.Migration{
+		From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+	}
+	migration.Neighborhood = "1"
+	allowed := talib.Add(migration)
+	migration.Neighborhood = "2"
+	rejected := talib.Add(migration)
+
+	binary := filepath.Join(t.TempDir(), "manifestd")
+	require.NoError(t, os.WriteFile(binary, []byte(fakeBinary), 0o755))
+	quarantineDir := filepath.Join(dir, "quarantine")
+
+	migrate := &cobra.Command{Use: "migrate"}
+	quarantine := &cobra.Command{Use: "quarantine"}
+	command := &cobra.Command{Use: "serve", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ServeCmdRunE}
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupMigrateCmdFlags(migrate)
+	cmd.SetupQuarantineCmdFlags(quarantine)
+	cmd.SetupServeCmdFlags(command, migrate, quarantine)
+	command.SetArgs([]string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-wait", "1ms",
+		"--chain-home", "/tmp", "--fee-granter", "feegranter", "--binary", binary,
+		"--quarantine-dir", quarantineDir, "--poll-interval", "10ms"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error)
+	go func() { served <- command.ExecuteContext(ctx) }()
+
+	handled := func(item store.WorkItem, status store.WorkItemStatus) func() bool {
+		return func() bool {
+			remote, ok := talib.Item(item.UUID)
+			return ok && remote.Status == status
+		}
+	}
+	require.Eventually(t, handled(allowed, store.COMPLETED), 10*time.Second, 10*time.Millisecond)
+	require.Eventually(t, handled(rejected, store.FAILED), 10*time.Second, 10*time.Millisecond)
+
+	// The rejected work item is moved to the quarantine of its neighborhood
+	rejectedPath := filepath.Join(quarantineDir, "neighborhood-2", rejected.UUID.String()+".json")
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(rejectedPath)
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve did not stop")
+	}
+
+	// The state files are namespaced by neighborhood
+	require.DirExists(t, filepath.Join(dir, "neighborhood-1"))
+	require.NoFileExists(t, filepath.Join(dir, "neighborhood-1", allowed.UUID.String()+".json"))
+	require.NoFileExists(t, filepath.Join(dir, "neighborhood-2", rejected.UUID.String()+".json"))
+	local, err := store.ReadState(rejectedPath)
+	require.NoError(t, err)
+	require.Equal(t, store.FAILED, local.Status)
+}
+
+// TestServe_Shutdown stops serving while a migration waits for its transaction: the migration is finished within the
+// shutdown timeout, or interrupted past it and left MIGRATING for the next run, never failed.
+func TestServe_Shutdown(t *testing.T) {
+	tt := []struct {
+		name     string
+		wait     string // Shell command run by the chain binary before the transaction is found
+		timeout  string
+		expected store.WorkItemStatus
+	}{
+		{name: "finished", wait: `sleep 1`, timeout: "1m", expected: store.COMPLETED},
+		{name: "interrupted", wait: `exec sleep 30`, timeout: "100ms", expected: store.MIGRATING},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.Chdir(dir))
+			viper.Set("token-map", map[string]utils.TokenInfo{
+				testutils.ManySymbol: {Denom: "umfx"},
+			})
+			setNeighborhoods(t, []map[string]any{{"id": 1}})
+
+			talib := faketalib.New("user", "pass")
+			server := httptest.NewServer(talib)
+			defer server.Close()
+			migration := faketalib.Migration{
+				From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+			}
+			migration.Neighborhood = "1"
+			item := talib.Add(migration)
+
+			binDir := t.TempDir()
+			chain := filepath.Join(binDir, "chain")
+			require.NoError(t, os.WriteFile(chain, []byte(fakeBinary), 0o755))
+			binary := filepath.Join(binDir, "manifestd")
+			slow := "#!/bin/sh\nif [ \"$1 $2\" = \"q event-query-tx-for\" ]; then\n  " + tc.wait + "\nfi\nexec " + chain + " \"$@\"\n"
+			require.NoError(t, os.WriteFile(binary, []byte(slow), 0o755))
+			quarantineDir := filepath.Join(dir, "quarantine")
+
+			migrate := &cobra.Command{Use: "migrate"}
+			quarantine := &cobra.Command{Use: "quarantine"}
+			command := &cobra.Command{Use: "serve", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ServeCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(migrate)
+			cmd.SetupQuarantineCmdFlags(quarantine)
+			cmd.SetupServeCmdFlags(command, migrate, quarantine)
+			command.SetArgs([]string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-wait", "1ms",
+				"--chain-home", "/tmp", "--fee-granter", "feegranter", "--binary", binary,
+				"--quarantine-dir", quarantineDir, "--poll-interval", "10ms", "--shutdown-timeout", tc.timeout})
+
+			// Cancelling the context of the command stops serving, like a signal
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			served := make(chan error)
+			go func() { served <- command.ExecuteContext(ctx) }()
+
+			require.Eventually(t, func() bool {
+				remote, ok := talib.Item(item.UUID)
+				return ok && remote.Status == store.MIGRATING
+			}, 10*time.Second, 10*time.Millisecond)
+			cancel()
+
+			select {
+			case err := <-served:
+				require.NoError(t, err)
+			case <-time.After(10 * time.Second):
+				t.Fatal("serve did not stop")
+			}
+
+			remote, ok := talib.Item(item.UUID)
+			require.True(t, ok)
+			require.Equal(t, tc.expected, remote.Status)
+			require.NoDirExists(t, quarantineDir)
+			statePath := filepath.Join(dir, "neighborhood-1", item.UUID.String()+".json")
+			if tc.expected == store.COMPLETED {
+				require.NoFileExists(t, statePath)
+				return
+			}
+			local, err := store.ReadState(statePath)
+			require.NoError(t, err)
+			require.Equal(t, store.MIGRATING, local.Status)
+		})
+	}
+}
+
+func TestLoadMigrationConfigFromCLI_Neighborhood(t *testing.T) {
+	previous := viper.Get("token-map")
+	t.Cleanup(func() {
+		viper.Set("token-map", previous)
+		viper.Set("neighborhood", nil)
+	})
+	viper.Set("token-map", map[string]utils.TokenInfo{"dummy": {Denom: "umfx"}})
+	setNeighborhoods(t, []map[string]any{
+		{"id": 1, "max-fee": 100},
+		{"id": 2, "token-map": map[string]any{"dummy": map[string]any{"denom": "utest"}}},
+	})
+
+	tt := []struct {
+		neighborhood uint64
+		denom        string
+		maxFee       uint64
+	}{
+		{neighborhood: 0, denom: "umfx"},
+		{neighborhood: 1, denom: "umfx", maxFee: 100},
+		{neighborhood: 2, denom: "utest"},
+	}
+
+	for _, tc := range tt {
+		viper.Set("neighborhood", tc.neighborhood)
+		migrateConfig, err := cmd.LoadMigrationConfigFromCLI()
+		require.NoError(t, err)
+		require.Equal(t, tc.denom, migrateConfig.TokenMap["dummy"].Denom, "neighborhood %d", tc.neighborhood)
+		require.Equal(t, tc.maxFee, migrateConfig.MaxFee, "neighborhood %d", tc.neighborhood)
+	}
+
+	setNeighborhoods(t, []map[string]any{{"id": 1, "max-fees": 100}})
+	_, err := cmd.LoadMigrationConfigFromCLI()
+	require.ErrorContains(t, err, "invalid neighborhoods configuration")
+}
+
+// TestServe_Admin pauses the claims and the sends of a served neighborhood through the admin API, reconciles and
+// verifies its work item, then drains the migrator.
+func TestServe_Admin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("token-map", map[string]utils.TokenInfo{
+		testutils.ManySymbol: {Denom: "umfx"},
+	})
+
+	talib := faketalib.New("user", "pass")
+	server := httptest.NewServer(talib)
+	defer server.Close()
+
+	binary := filepath.Join(t.TempDir(), "manifestd")
+	require.NoError(t, os.WriteFile(binary, []byte(fakeBinary), 0o755))
+
+	// Reserve a free address for the admin API
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	adminURL := "http://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	migrate := &cobra.Command{Use: "migrate"}
+	quarantine := &cobra.Command{Use: "quarantine"}
+	command := &cobra.Command{Use: "serve", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ServeCmdRunE}
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupMigrateCmdFlags(migrate)
+	cmd.SetupQuarantineCmdFlags(quarantine)
+	cmd.SetupServeCmdFlags(command, migrate, quarantine)
+	command.SetArgs([]string{"--url", server.URL, "--username", "user", "--password", "pass", "--http-retry-wait", "1ms",
+		"--chain-home", "/tmp", "--fee-granter", "feegranter", "--binary", binary, "--poll-interval", "10ms",
+		"--admin-listen", strings.TrimPrefix(adminURL, "http://"), "--admin-token", "admin-secret"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error)
+	go func() { served <- command.ExecuteContext(ctx) }()
+
+	call := func(method, path string, v any) int {
+		req, err := http.NewRequest(method, adminURL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+		return resp.StatusCode
+	}
+	require.Eventually(t, func() bool { return call(http.MethodGet, "/status", nil) == http.StatusOK }, 10*time.Second, 10*time.Millisecond)
+
+	// The work item is not claimed while the claims are paused
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/claims/pause", nil))
+	item := talib.Add(faketalib.Migration{
+		From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: true,
+	})
+	status := func(status store.WorkItemStatus) func() bool {
+		return func() bool {
+			remote, ok := talib.Item(item.UUID)
+			return ok && remote.Status == status
+		}
+	}
+	require.Never(t, func() bool { return !status(store.CREATED)() }, 100*time.Millisecond, 10*time.Millisecond)
+
+	// The work item is claimed but not sent while the sends are paused, and no attempt is recorded
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/sends/pause", nil))
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/claims/resume", nil))
+	require.Eventually(t, status(store.CLAIMED), 10*time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { return !status(store.CLAIMED)() }, 100*time.Millisecond, 10*time.Millisecond)
+
+	local, err := store.ReadState(filepath.Join(dir, item.UUID.String()+".json"))
+	require.NoError(t, err)
+	require.Equal(t, store.CLAIMED, local.Status)
+	require.Zero(t, local.Audit.Attempts)
+
+	var verification struct {
+		Match bool `json:"match"`
+	}
+	path := "/items/" + item.UUID.String()
+	require.Equal(t, http.StatusOK, call(http.MethodGet, path+"/verify?neighborhood=0", &verification))
+	require.True(t, verification.Match)
+	require.Equal(t, http.StatusNotFound, call(http.MethodGet, path+"/verify?neighborhood=1", nil))
+
+	// The reconciliation waits for the work item to be out of flight
+	var reconciliation struct {
+		Status    string `json:"status"`
+		Completed bool   `json:"completed"`
+	}
+	require.Eventually(t, func() bool {
+		return call(http.MethodPost, path+"/reconcile?neighborhood=0", &reconciliation) == http.StatusOK
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, store.CLAIMED.String(), reconciliation.Status)
+	require.False(t, reconciliation.Completed)
+
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/sends/resume", nil))
+	require.Eventually(t, status(store.COMPLETED), 10*time.Second, 10*time.Millisecond)
+
+	// Draining stops serving once the migrations in flight are finished
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/drain", nil))
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve did not drain")
+	}
+}
@@ -0,0 +1,75 @@
+package cmd_test
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/configfile"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+)
+
+// boundKeys are the configuration keys bound to the flags of the root command and its subcommands, by flag.
+// They are probed before any test binds the keys to the flags of its own commands.
+var boundKeys = probeBoundKeys()
+
+// probe is a flag value identifying the configuration keys bound to the flag.
+type probe string
+
+func (p probe) String() string   { return string(p) }
+func (p probe) Set(string) error { return nil }
+func (p probe) Type() string     { return "string" }
+
+func probeBoundKeys() map[string][]string {
+	bound := map[string][]string{}
+	var walk func(command *cobra.Command)
+	walk = func(command *cobra.Command) {
+		command.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+			name := command.CommandPath() + " --" + flag.Name
+			value, changed := flag.Value, flag.Changed
+			flag.Value, flag.Changed = probe(name), true
+			for _, key := range viper.AllKeys() {
+				if viper.GetString(key) == name {
+					bound[name] = append(bound[name], key)
+				}
+			}
+			flag.Value, flag.Changed = value, changed
+		})
+		for _, sub := range command.Commands() {
+			walk(sub)
+		}
+	}
+	walk(cmd.RootCmd)
+	return bound
+}
+
+// TestSettings_BoundKeys checks that every configuration key bound to a flag can be set in the configuration file and
+// its profiles, but the keys of a single invocation, e.g. the UUID of the work item to migrate.
+func TestSettings_BoundKeys(t *testing.T) {
+	var settings []string
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(configfile.Settings{})) {
+		tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		settings = append(settings, tag)
+	}
+
+	perInvocation := func(key string) bool {
+		return strings.HasSuffix(key, "-uuid") || key == "force" || key == "dry-run" ||
+			strings.HasPrefix(key, "quarantine-requeue-") || strings.HasPrefix(key, "quarantine-purge-")
+	}
+
+	require.NotEmpty(t, boundKeys)
+	for flag, keys := range boundKeys {
+		for _, key := range keys {
+			if !perInvocation(key) {
+				require.True(t, slices.Contains(settings, key), "key %s of flag %s is not in the configuration file settings", key, flag)
+			}
+		}
+	}
+}
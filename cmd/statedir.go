@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// stateDirCmd represents the state-dir command
+var stateDirCmd = &cobra.Command{
+	Use:   "state-dir",
+	Short: "Print the directory holding the state files of the work items of the neighborhood.",
+	Long: `The state-dir command prints the absolute path of the directory holding the state files of the work items of
+the neighborhood. With a profile, or with the neighborhoods of the configuration file, it is a subdirectory of
+--state-dir. Scripts looking for the state files must use this directory.`,
+	RunE: StateDirCmdRunE,
+}
+
+func init() {
+	rootCmd.AddCommand(stateDirCmd)
+}
+
+func StateDirCmdRunE(cmd *cobra.Command, args []string) error {
+	ctx, err := stateContext(cmd.Context(), viper.GetUint64("neighborhood"))
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(store.StateDir(ctx))
+	if err != nil {
+		return errors.WithMessage(err, "could not resolve state directory")
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), dir)
+	return nil
+}
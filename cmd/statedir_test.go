@@ -0,0 +1,61 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
+	"github.com/manifest-network/mfx-migrator/cmd"
+	"github.com/manifest-network/mfx-migrator/testutils"
+)
+
+func TestStateDirCmd(t *testing.T) {
+	tt := []struct {
+		name          string
+		neighborhoods []map[string]any
+		args          []string
+		stateFile     bool // A state file is in the state directory of a migrator without neighborhoods
+		expected      string
+		err           string
+	}{
+		{name: "without neighborhoods", args: []string{"--neighborhood", "2"}, stateFile: true, expected: "."},
+		{name: "neighborhood", neighborhoods: []map[string]any{{"id": 1}, {"id": 2}}, args: []string{"--neighborhood", "2"}, expected: "neighborhood-2"},
+		{name: "state files in the previous layout", neighborhoods: []map[string]any{{"id": 1}}, args: []string{"--neighborhood", "1"}, stateFile: true,
+			err: "1 state files found in ., outside of the state directory neighborhood-1"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.Chdir(dir))
+			setNeighborhoods(t, tc.neighborhoods)
+			if tc.stateFile {
+				testutils.SetupWorkItem(t)
+			}
+
+			command := &cobra.Command{Use: "state-dir", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.StateDirCmdRunE}
+			cmd.SetupRootCmdFlags(command)
+			out, err := testutils.Execute(t, command, tc.args...)
+
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				require.NoDirExists(t, filepath.Join(dir, "neighborhood-1"))
+				return
+			}
+			require.NoError(t, err)
+			expected, err := filepath.Abs(tc.expected)
+			require.NoError(t, err)
+			require.Equal(t, expected, out)
+
+			// The state files of the work items are in the printed directory
+			_, err = store.LoadState(store.WithStateDir(context.Background(), out), testutils.DummyUUIDStr)
+			require.Equal(t, tc.stateFile, err == nil)
+		})
+	}
+}
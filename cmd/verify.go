@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"log/slog"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -22,34 +24,32 @@ var verifyCmd = &cobra.Command{
 			return err
 		}
 
-		s, err := store.LoadState(c.UUID)
-		if err != nil {
-			slog.Warn("unable to load local state, continuing", "warning", err)
+		httpConfig := LoadHttpConfigFromCLI()
+		slog.Debug("args", "http-c", httpConfig)
+		if err := httpConfig.Validate(); err != nil {
+			return err
 		}
 
-		if s != nil {
-			slog.Info("Local state item", "item", s)
+		ctx, err := stateContext(cmd.Context(), c.Neighborhood)
+		if err != nil {
+			return err
 		}
 
 		// Verify the work item on the remote database
 		slog.Debug("verifying remote state", "url", c.Url, "uuid", c.UUID)
 
-		r := CreateRestClient(cmd.Context(), c.Url, c.Neighborhood)
-
-		item, err := store.GetWorkItem(r, uuid.MustParse(c.UUID))
+		r, err := CreateRestClient(ctx, c.Url, c.Neighborhood, httpConfig)
 		if err != nil {
-			return errors.WithMessage(err, "unable to get work item")
+			return err
 		}
 
-		if item == nil {
-			return errors.WithMessage(err, "work item not found")
+		v, err := verifyWorkItem(ctx, r, uuid.MustParse(c.UUID))
+		if err != nil {
+			return err
 		}
 
-		slog.Info("Remote state item", "item", item)
-
-		if s != nil {
-			slog.Debug("comparing local and remote states", "local", s, "remote", item)
-			if item.Equal(*s) {
+		if v.Local != nil {
+			if v.Match {
 				slog.Info("Local and remote states match")
 			} else {
 				slog.Info("Local and remote states do not match")
@@ -72,3 +72,40 @@ func init() {
 
 	rootCmd.AddCommand(verifyCmd)
 }
+
+// verification is the outcome of the verification of a work item.
+type verification struct {
+	Local  *store.WorkItem `json:"local,omitempty"` // Local state, nil if the work item has no state file
+	Remote *store.WorkItem `json:"remote"`
+	Match  bool            `json:"match"` // True if the local and remote states match
+}
+
+// verifyWorkItem compares the local state of the work item with the remote work item.
+func verifyWorkItem(ctx context.Context, r *resty.Client, id uuid.UUID) (*verification, error) {
+	local, err := store.LoadState(ctx, id.String())
+	if err != nil {
+		slog.Warn("unable to load local state, continuing", "warning", err)
+	}
+
+	if local != nil {
+		slog.Info("Local state item", "item", local)
+	}
+
+	remote, err := store.GetWorkItem(ctx, r, id)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to get work item")
+	}
+
+	if remote == nil {
+		return nil, errors.New("work item not found")
+	}
+
+	slog.Info("Remote state item", "item", remote)
+
+	v := &verification{Local: local, Remote: remote}
+	if local != nil {
+		slog.Debug("comparing local and remote states", "local", local, "remote", remote)
+		v.Match = remote.Equal(*local)
+	}
+	return v, nil
+}
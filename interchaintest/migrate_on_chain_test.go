@@ -178,7 +178,7 @@ func TestMigrateOnChain(t *testing.T) {
 				require.ErrorContains(t, err, tc.err)
 
 				// Check the status of the local work item
-				item, err := store.LoadState(workItemPath)
+				item, err := store.LoadState(context.Background(), workItemPath)
 				require.NoError(t, err)
 				require.Equal(t, item.Status, store.FAILED)
 				require.Contains(t, *item.Error, tc.err)
@@ -0,0 +1,182 @@
+// Package admin implements the operator controls of the serve command: pausing the claims and the sends, draining, and
+// tracking the work items being migrated. The controls are exposed by an authenticated HTTP API, see Handler.
+package admin
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Step is the step of the migration a work item is at.
+type Step string
+
+const (
+	StepReconcile    Step = "reconcile"     // Catching up with the remote work item
+	StepPrepare      Step = "prepare"       // Checking the MANY transaction and converting the amount
+	StepPolicy       Step = "policy"        // Evaluating the eligibility policy
+	StepFindTransfer Step = "find-transfer" // Looking for the transfer of a previous run
+	StepSimulate     Step = "simulate"      // Simulating the transaction
+	StepSend         Step = "send"          // Broadcasting the transaction and waiting for its inclusion
+	StepConfirm      Step = "confirm"       // Waiting for the confirmation depth
+	StepComplete     Step = "complete"      // Completing the work item
+)
+
+// Item is a work item being migrated, or handled by an operator.
+type Item struct {
+	UUID         uuid.UUID `json:"uuid"`
+	Neighborhood uint64    `json:"neighborhood"`
+	Step         Step      `json:"step"`
+	Started      time.Time `json:"started"`     // Start of the migration
+	StepStarted  time.Time `json:"stepStarted"` // Start of the current step
+}
+
+// Status is the state of the controls and of the work items in flight.
+type Status struct {
+	ClaimsPaused bool   `json:"claimsPaused"`
+	SendsPaused  bool   `json:"sendsPaused"`
+	Draining     bool   `json:"draining"`
+	InFlight     []Item `json:"inFlight"`
+}
+
+// key identifies a work item, the UUIDs of two neighborhoods may collide.
+type key struct {
+	neighborhood uint64
+	uuid         uuid.UUID
+}
+
+// Controller holds the operator controls and tracks the work items in flight. It is safe for concurrent use.
+type Controller struct {
+	mu           sync.Mutex
+	claimsPaused bool
+	sendsPaused  bool
+	drain        chan struct{} // Closed once draining
+	draining     bool
+	items        map[key]*Item
+}
+
+// New creates a controller with the claims and the sends running.
+func New() *Controller {
+	return &Controller{
+		drain: make(chan struct{}),
+		items: make(map[key]*Item),
+	}
+}
+
+// PauseClaims pauses or resumes the claims from the work queue.
+func (c *Controller) PauseClaims(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.claimsPaused = paused
+}
+
+// ClaimsPaused returns true if the claims are paused, or the controller is draining.
+func (c *Controller) ClaimsPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.claimsPaused || c.draining
+}
+
+// PauseSends pauses or resumes the sends of tokens.
+func (c *Controller) PauseSends(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendsPaused = paused
+}
+
+// SendsPaused returns true if the sends of tokens are paused.
+func (c *Controller) SendsPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendsPaused
+}
+
+// Drain stops the claims and the start of new migrations. The migrations in flight are finished.
+func (c *Controller) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.draining {
+		c.draining = true
+		close(c.drain)
+	}
+}
+
+// Draining returns a channel closed once the controller is draining.
+func (c *Controller) Draining() <-chan struct{} {
+	return c.drain
+}
+
+// Status returns the state of the controls and of the work items in flight, sorted by start time.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]Item, 0, len(c.items))
+	for _, item := range c.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Started.Before(items[j].Started) })
+
+	return Status{
+		ClaimsPaused: c.claimsPaused,
+		SendsPaused:  c.sendsPaused,
+		Draining:     c.draining,
+		InFlight:     items,
+	}
+}
+
+// tracked is a work item tracked in a context, see Track.
+type tracked struct {
+	c   *Controller
+	key key
+}
+
+type contextKey struct{}
+
+// Track tracks the work item of the neighborhood as in flight, until the returned function is called.
+// The returned context carries the work item, see SetStep and SendsPaused.
+// It returns false if the work item is already in flight, in which case it must be left alone.
+func (c *Controller) Track(ctx context.Context, neighborhood uint64, id uuid.UUID) (context.Context, func(), bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key{neighborhood: neighborhood, uuid: id}
+	if _, ok := c.items[k]; ok {
+		return ctx, func() {}, false
+	}
+
+	now := time.Now()
+	c.items[k] = &Item{UUID: id, Neighborhood: neighborhood, Started: now, StepStarted: now}
+
+	done := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.items, k)
+	}
+	return context.WithValue(ctx, contextKey{}, tracked{c: c, key: k}), done, true
+}
+
+// SetStep records the step of the work item tracked in the context, if any.
+func SetStep(ctx context.Context, step Step) {
+	t, ok := ctx.Value(contextKey{}).(tracked)
+	if !ok {
+		return
+	}
+
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	if item, ok := t.c.items[t.key]; ok {
+		item.Step = step
+		item.StepStarted = time.Now()
+	}
+}
+
+// SendsPaused returns true if the work item tracked in the context must not be sent.
+// The work items not tracked, e.g. migrated by the migrate command, are never paused.
+func SendsPaused(ctx context.Context) bool {
+	t, ok := ctx.Value(contextKey{}).(tracked)
+	return ok && t.c.SendsPaused()
+}
@@ -0,0 +1,216 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/admin"
+)
+
+const token = "secret-token"
+
+func TestController(t *testing.T) {
+	c := admin.New()
+	require.False(t, c.ClaimsPaused())
+	require.False(t, c.SendsPaused())
+
+	c.PauseClaims(true)
+	c.PauseSends(true)
+	require.True(t, c.ClaimsPaused())
+	require.True(t, c.SendsPaused())
+	c.PauseClaims(false)
+	c.PauseSends(false)
+	require.False(t, c.ClaimsPaused())
+	require.False(t, c.SendsPaused())
+
+	// Draining stops the claims and is idempotent
+	c.Drain()
+	c.Drain()
+	require.True(t, c.ClaimsPaused())
+	require.True(t, c.Status().Draining)
+	select {
+	case <-c.Draining():
+	default:
+		t.Fatal("draining channel not closed")
+	}
+}
+
+func TestController_Track(t *testing.T) {
+	c := admin.New()
+	id := uuid.New()
+
+	// Untracked work items have no step and are never paused
+	admin.SetStep(context.Background(), admin.StepSend)
+	c.PauseSends(true)
+	require.False(t, admin.SendsPaused(context.Background()))
+
+	ctx, done, ok := c.Track(context.Background(), 1, id)
+	require.True(t, ok)
+	require.True(t, admin.SendsPaused(ctx))
+
+	admin.SetStep(ctx, admin.StepSimulate)
+	items := c.Status().InFlight
+	require.Len(t, items, 1)
+	require.Equal(t, id, items[0].UUID)
+	require.Equal(t, uint64(1), items[0].Neighborhood)
+	require.Equal(t, admin.StepSimulate, items[0].Step)
+
+	// The same work item cannot be tracked twice, but the UUID of another neighborhood can
+	_, _, ok = c.Track(context.Background(), 1, id)
+	require.False(t, ok)
+	_, other, ok := c.Track(context.Background(), 2, id)
+	require.True(t, ok)
+	other()
+
+	done()
+	require.Empty(t, c.Status().InFlight)
+	_, done, ok = c.Track(context.Background(), 1, id)
+	require.True(t, ok)
+	done()
+}
+
+// actions is a fake implementation of the actions on a work item.
+type actions struct {
+	err error
+}
+
+func (a actions) Reconcile(_ context.Context, neighborhood uint64, id uuid.UUID) (any, error) {
+	return map[string]any{"action": "reconcile", "neighborhood": neighborhood, "uuid": id}, a.err
+}
+
+func (a actions) Verify(_ context.Context, neighborhood uint64, id uuid.UUID) (any, error) {
+	return map[string]any{"action": "verify", "neighborhood": neighborhood, "uuid": id}, a.err
+}
+
+func request(t *testing.T, handler http.Handler, method, path, bearer string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Unauthorized(t *testing.T) {
+	handler := admin.Handler(admin.New(), actions{}, token)
+
+	for _, bearer := range []string{"", "wrong"} {
+		rec := request(t, handler, http.MethodGet, "/status", bearer)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestHandler_Controls(t *testing.T) {
+	c := admin.New()
+	handler := admin.Handler(c, actions{}, token)
+
+	tt := []struct {
+		path  string
+		check func(status admin.Status) bool
+	}{
+		{path: "/claims/pause", check: func(s admin.Status) bool { return s.ClaimsPaused }},
+		{path: "/claims/resume", check: func(s admin.Status) bool { return !s.ClaimsPaused }},
+		{path: "/sends/pause", check: func(s admin.Status) bool { return s.SendsPaused }},
+		{path: "/sends/resume", check: func(s admin.Status) bool { return !s.SendsPaused }},
+		{path: "/drain", check: func(s admin.Status) bool { return s.Draining }},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			require.Equal(t, http.StatusMethodNotAllowed, request(t, handler, http.MethodGet, tc.path, token).Code)
+
+			rec := request(t, handler, http.MethodPost, tc.path, token)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var status admin.Status
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+			require.True(t, tc.check(status))
+			require.True(t, tc.check(c.Status()))
+		})
+	}
+}
+
+func TestHandler_Items(t *testing.T) {
+	c := admin.New()
+	handler := admin.Handler(c, actions{}, token)
+	id := uuid.New()
+
+	ctx, done, ok := c.Track(context.Background(), 3, id)
+	require.True(t, ok)
+	defer done()
+	admin.SetStep(ctx, admin.StepConfirm)
+
+	rec := request(t, handler, http.MethodGet, "/items", token)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var items []admin.Item
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	require.Equal(t, id, items[0].UUID)
+	require.Equal(t, uint64(3), items[0].Neighborhood)
+	require.Equal(t, admin.StepConfirm, items[0].Step)
+}
+
+func TestHandler_Actions(t *testing.T) {
+	id := uuid.New()
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		err    error
+		code   int
+	}{
+		{name: "reconcile", method: http.MethodPost, path: "/items/" + id.String() + "/reconcile?neighborhood=2", code: http.StatusOK},
+		{name: "verify", method: http.MethodGet, path: "/items/" + id.String() + "/verify?neighborhood=2", code: http.StatusOK},
+		{name: "invalid uuid", method: http.MethodGet, path: "/items/invalid/verify?neighborhood=2", code: http.StatusBadRequest},
+		{name: "missing neighborhood", method: http.MethodGet, path: "/items/" + id.String() + "/verify", code: http.StatusBadRequest},
+		{name: "not found", method: http.MethodGet, path: "/items/" + id.String() + "/verify?neighborhood=2", err: errors.WithMessage(admin.ErrNotFound, "neighborhood 2 not served"), code: http.StatusNotFound},
+		{name: "in flight", method: http.MethodPost, path: "/items/" + id.String() + "/reconcile?neighborhood=2", err: admin.ErrConflict, code: http.StatusConflict},
+		{name: "failure", method: http.MethodPost, path: "/items/" + id.String() + "/reconcile?neighborhood=2", err: errors.New("boom"), code: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := admin.Handler(admin.New(), actions{err: tc.err}, token)
+			rec := request(t, handler, tc.method, tc.path, token)
+			require.Equal(t, tc.code, rec.Code, rec.Body.String())
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			if tc.code != http.StatusOK {
+				require.Contains(t, body, "error")
+				return
+			}
+			require.Equal(t, tc.name, body["action"])
+			require.Equal(t, id.String(), body["uuid"])
+			require.EqualValues(t, 2, body["neighborhood"])
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr, err := admin.Serve(ctx, "127.0.0.1:0", admin.Handler(admin.New(), actions{}, token))
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr.String()+"/status", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+var (
+	// ErrNotFound is returned by the actions when the neighborhood is not served or the work item is unknown.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned by the actions when the work item is in flight.
+	ErrConflict = errors.New("work item in flight")
+)
+
+// Actions are the actions on a single work item, run with the functions of the commands.
+// The returned values are encoded as the JSON response.
+type Actions interface {
+	Reconcile(ctx context.Context, neighborhood uint64, id uuid.UUID) (any, error)
+	Verify(ctx context.Context, neighborhood uint64, id uuid.UUID) (any, error)
+}
+
+// Handler returns the admin API of the controller. Every request must carry the token as a bearer token.
+//
+//	GET  /status                              State of the controls and work items in flight
+//	GET  /items                               Work items in flight, with their current step
+//	POST /claims/pause, /claims/resume        Pause or resume the claims from the work queue
+//	POST /sends/pause, /sends/resume          Pause or resume the sends of tokens
+//	POST /drain                               Finish the migrations in flight, then stop serving
+//	POST /items/{uuid}/reconcile?neighborhood Reconcile the local state of the work item with the remote database
+//	GET  /items/{uuid}/verify?neighborhood    Compare the local and remote states of the work item
+func Handler(c *Controller, actions Actions, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.Status())
+	})
+	mux.HandleFunc("GET /items", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.Status().InFlight)
+	})
+	mux.HandleFunc("POST /claims/pause", control(c, "Claims paused", func() { c.PauseClaims(true) }))
+	mux.HandleFunc("POST /claims/resume", control(c, "Claims resumed", func() { c.PauseClaims(false) }))
+	mux.HandleFunc("POST /sends/pause", control(c, "Sends paused", func() { c.PauseSends(true) }))
+	mux.HandleFunc("POST /sends/resume", control(c, "Sends resumed", func() { c.PauseSends(false) }))
+	mux.HandleFunc("POST /drain", control(c, "Draining", c.Drain))
+	mux.HandleFunc("POST /items/{uuid}/reconcile", action(actions.Reconcile))
+	mux.HandleFunc("GET /items/{uuid}/verify", action(actions.Verify))
+
+	return authorized(token, mux)
+}
+
+// authorized rejects the requests without the bearer token.
+func authorized(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// control applies an operator control, then responds with the state of the controls.
+func control(c *Controller, message string, apply func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apply()
+		slog.Warn(message, "by", "admin API", "remote", r.RemoteAddr)
+		writeJSON(w, http.StatusOK, c.Status())
+	}
+}
+
+// action runs an action on the work item of the request.
+func action(run func(ctx context.Context, neighborhood uint64, id uuid.UUID) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("uuid"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.WithMessage(err, "invalid UUID"))
+			return
+		}
+
+		neighborhood, err := strconv.ParseUint(r.URL.Query().Get("neighborhood"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.WithMessage(err, "invalid or missing neighborhood"))
+			return
+		}
+
+		result, err := run(r.Context(), neighborhood, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, ErrConflict):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Could not write admin response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// Serve serves the handler on the given address until the context is done.
+// It returns once the address is bound, serving the admin API in the background.
+func Serve(ctx context.Context, addr string, handler http.Handler) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not start admin listener")
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		slog.Info("Serving admin API", "address", listener.Addr().String())
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin listener stopped", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Could not stop admin listener", "error", err)
+		}
+	}()
+
+	return listener.Addr(), nil
+}
@@ -2,11 +2,17 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"net/url"
 	"os/exec"
+	"regexp"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
 	"github.com/manifest-network/mfx-migrator/internal/utils"
 )
 
@@ -45,6 +51,58 @@ func (c Config) Validate() error {
 	return nil
 }
 
+// HttpConfig represents the configuration of the remote database HTTP client
+type HttpConfig struct {
+	RetryCount       int           // Maximum number of retries of a single request
+	RetryWaitTime    time.Duration // Initial wait time between retries, increased exponentially
+	RetryMaxWaitTime time.Duration // Maximum wait time between retries
+	RetryBudget      time.Duration // Maximum time spent by a command on requests being retried, 0 for no limit
+	Timeout          time.Duration // Timeout of a single request
+	CAFile           string        // PEM bundle of additional certificate authorities to trust
+	ClientCertFile   string        // PEM client certificate for mutual TLS
+	ClientKeyFile    string        // PEM client key for mutual TLS
+	TLSMinVersion    string        // Minimum TLS version (1.2 or 1.3)
+	TLSPins          []string      // Certificate public key pins (sha256//<base64>), one of them must match the server chain
+}
+
+// HasTLS returns true if any TLS option is set
+func (c HttpConfig) HasTLS() bool {
+	return c.CAFile != "" || c.ClientCertFile != "" || c.ClientKeyFile != "" || c.TLSMinVersion != "" || len(c.TLSPins) > 0
+}
+
+// Validate the HttpConfig making sure all durations are valid
+func (c HttpConfig) Validate() error {
+	if c.RetryCount < 0 {
+		return fmt.Errorf("http retry count must be >= 0")
+	}
+
+	if c.RetryWaitTime < 0 {
+		return fmt.Errorf("http retry wait time must be >= 0")
+	}
+
+	if c.RetryMaxWaitTime < c.RetryWaitTime {
+		return fmt.Errorf("http retry max wait time must be >= http retry wait time")
+	}
+
+	if c.RetryBudget < 0 {
+		return fmt.Errorf("http retry budget must be >= 0")
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("http timeout > 0 is required")
+	}
+
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return fmt.Errorf("client certificate and client key are both required for mutual TLS")
+	}
+
+	if c.TLSMinVersion != "" && c.TLSMinVersion != "1.2" && c.TLSMinVersion != "1.3" {
+		return fmt.Errorf("TLS min version must be 1.2 or 1.3")
+	}
+
+	return nil
+}
+
 type AuthConfig struct {
 	Username string // The username to authenticate with
 	Password string // The password to authenticate with
@@ -54,6 +112,14 @@ func (c AuthConfig) Print() {
 	fmt.Printf("Username: %v\n", c.Username)
 }
 
+// LogValue implements slog.LogValuer, masking the password
+func (c AuthConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", c.Username),
+		slog.String("password", secrets.Mask),
+	)
+}
+
 func (c AuthConfig) Validate() error {
 	if c.Username == "" {
 		return fmt.Errorf("username is required")
@@ -70,21 +136,46 @@ type ClaimConfig struct {
 	Force bool // Force re-claiming of a failed work item
 }
 
+// Sources of the gas price of the transactions
+const (
+	GasPriceStatic    = "static"        // The configured gas price
+	GasPriceMinimum   = "min-gas-price" // The minimum gas price of the node, at least the configured gas price
+	GasPriceFeemarket = "feemarket"     // The gas price of the fee market module, at least the configured gas price
+)
+
+// denomRegex is the format of a Cosmos SDK denom.
+var denomRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9/:._-]{2,127}$`)
+
 type MigrateConfig struct {
-	ChainID          string                     // The destination chain ID
-	AddressPrefix    string                     // The destination address prefix
-	NodeAddress      string                     // The destination RPC node address
-	KeyringBackend   string                     // The destination chain keyring backend to use
-	BankAddress      string                     // The destination chain address of the bank account to send tokens from
-	ChainHome        string                     // The root directory of the destination chain configuration
-	TokenMap         map[string]utils.TokenInfo // Map of source token address to destination token info
-	WaitTxTimeout    uint                       // Number of seconds spent waiting for the transaction to be included in a block
-	WaitBlockTimeout uint                       // Number of seconds spent waiting for the block to be committed
-	Binary           string                     // Binary name of the destination blockchain
-	GasPrice         float64                    // Minimum gas price to use for transactions
-	GasAdjustment    float64                    // Gas adjustment to use for transactions
-	GasDenom         string                     // Gas denomination to use for transactions
-	FeeGranter       string                     // The address of the gas fee granter
+	ChainID           string                     // The destination chain ID
+	AddressPrefix     string                     // The destination address prefix
+	NodeAddress       string                     // The destination RPC node address
+	KeyringBackend    string                     // The destination chain keyring backend to use
+	BankAddress       string                     // The destination chain address of the bank account to send tokens from
+	ChainHome         string                     // The root directory of the destination chain configuration
+	TokenMap          map[string]utils.TokenInfo // Map of source token address to destination token info
+	WaitTxTimeout     uint                       // Number of seconds spent waiting for the transaction to be included in a block
+	WaitBlockTimeout  uint                       // Number of seconds spent waiting for the block to be committed
+	ConfirmationDepth uint                       // Number of blocks past the inclusion height before a migration is completed
+	Binary            string                     // Binary name of the destination blockchain
+	GasPrice          float64                    // Minimum gas price to use for transactions
+	GasAdjustment     float64                    // Gas adjustment to use for transactions
+	GasDenom          string                     // Gas denomination to use for transactions
+	FeeGranter        string                     // The address of the gas fee granter
+	GasPriceSource    string                     // Source of the gas price, see GasPriceStatic, GasPriceMinimum and GasPriceFeemarket
+	MaxFee            uint64                     // Maximum fee of a transaction, in gas denom base units, 0 for no limit
+	Policy            policy.Config              // The migration eligibility policy
+	MaxAttempts       uint                       // Number of attempts before a transiently failing migration is marked as failed
+	RetryBackoff      time.Duration              // Wait time before retrying a failed migration, doubled after each attempt
+	RetryMaxBackoff   time.Duration              // Maximum wait time before retrying a failed migration
+	KillSwitch        KillSwitchConfig           // The kill switch checked before every broadcast
+}
+
+// KillSwitchConfig lists the sources of the kill switch. The kill switch is engaged if any source engages it.
+type KillSwitchConfig struct {
+	File  string // Sentinel file engaging the kill switch while it exists
+	Talib bool   // Check the kill switch flag of the remote database
+	Chain bool   // Check the bank send-enabled parameter of the token on chain
 }
 
 func (c MigrateConfig) Validate() error {
@@ -140,9 +231,100 @@ func (c MigrateConfig) Validate() error {
 		return fmt.Errorf("fee granter is required")
 	}
 
+	switch c.GasPriceSource {
+	case GasPriceStatic, GasPriceMinimum, GasPriceFeemarket:
+	default:
+		return fmt.Errorf("invalid gas price source: %s", c.GasPriceSource)
+	}
+
+	if c.MaxAttempts == 0 {
+		return fmt.Errorf("max attempts > 0 is required")
+	}
+
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("retry backoff must be >= 0")
+	}
+
+	if c.RetryMaxBackoff < c.RetryBackoff {
+		return fmt.Errorf("retry max backoff must be >= retry backoff")
+	}
+
+	if err := ValidateTokenMap(c.TokenMap); err != nil {
+		return err
+	}
+
+	if err := c.Policy.Validate(); err != nil {
+		return err
+	}
+
 	if _, err := exec.LookPath(c.Binary); err != nil {
 		return fmt.Errorf("binary %s not found in PATH", c.Binary)
 	}
 
 	return nil
 }
+
+// ValidateTokenMap makes sure every token maps to a valid denom, and no two tokens map to the same denom.
+func ValidateTokenMap(tokenMap map[string]utils.TokenInfo) error {
+	symbols := utils.GetKeys(tokenMap)
+	slices.Sort(symbols)
+
+	symbolByDenom := make(map[string]string, len(tokenMap))
+	for _, symbol := range symbols {
+		denom := tokenMap[symbol].Denom
+		if !denomRegex.MatchString(denom) {
+			return fmt.Errorf("invalid denom %q for token %s in token map", denom, symbol)
+		}
+		if other, ok := symbolByDenom[denom]; ok {
+			return fmt.Errorf("tokens %s and %s map to the same denom %s in token map", other, symbol, denom)
+		}
+		symbolByDenom[denom] = symbol
+	}
+
+	return nil
+}
+
+// NeighborhoodConfig is the configuration of a neighborhood served by the migrator.
+// The unset settings are the ones of the migration configuration.
+type NeighborhoodConfig struct {
+	ID           uint64                     `mapstructure:"id"`            // The neighborhood ID
+	TokenMap     map[string]utils.TokenInfo `mapstructure:"token-map"`     // Map of source token address to destination token info
+	Policy       *policy.Config             `mapstructure:"policy"`        // The migration eligibility policy
+	MaxFee       *uint64                    `mapstructure:"max-fee"`       // Maximum fee of a transaction, 0 for no limit
+	MaxAttempts  *uint                      `mapstructure:"max-attempts"`  // Number of attempts before a transiently failing migration is marked as failed
+	PollInterval time.Duration              `mapstructure:"poll-interval"` // Wait time between two claims from the work queue
+}
+
+// Apply returns the migration configuration of the neighborhood.
+func (n NeighborhoodConfig) Apply(c MigrateConfig) MigrateConfig {
+	if n.TokenMap != nil {
+		c.TokenMap = n.TokenMap
+	}
+	if n.Policy != nil {
+		c.Policy = *n.Policy
+	}
+	if n.MaxFee != nil {
+		c.MaxFee = *n.MaxFee
+	}
+	if n.MaxAttempts != nil {
+		c.MaxAttempts = *n.MaxAttempts
+	}
+	return c
+}
+
+// ValidateNeighborhoods makes sure every neighborhood is served once, with a valid poll interval.
+func ValidateNeighborhoods(neighborhoods []NeighborhoodConfig) error {
+	seen := make(map[uint64]bool, len(neighborhoods))
+	for _, n := range neighborhoods {
+		if seen[n.ID] {
+			return fmt.Errorf("neighborhood %d is configured more than once", n.ID)
+		}
+		seen[n.ID] = true
+
+		if n.PollInterval < 0 {
+			return fmt.Errorf("poll interval of neighborhood %d must be >= 0", n.ID)
+		}
+	}
+
+	return nil
+}
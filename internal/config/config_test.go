@@ -0,0 +1,102 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+)
+
+func TestValidateTokenMap(t *testing.T) {
+	tt := []struct {
+		name     string
+		tokenMap map[string]utils.TokenInfo
+		err      string
+	}{
+		{name: "empty"},
+		{name: "valid", tokenMap: map[string]utils.TokenInfo{
+			"dummy": {Denom: "umfx"},
+			"other": {Denom: "factory/manifest1hj5fveer5cjtn4wd6wstzugjfdxzl0xp8ws9ct/uother"},
+			"ibc":   {Denom: "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2"},
+		}},
+		{name: "missing denom", tokenMap: map[string]utils.TokenInfo{"dummy": {}}, err: `invalid denom "" for token dummy in token map`},
+		{name: "denom too short", tokenMap: map[string]utils.TokenInfo{"dummy": {Denom: "um"}}, err: `invalid denom "um" for token dummy`},
+		{name: "denom starting with a digit", tokenMap: map[string]utils.TokenInfo{"dummy": {Denom: "1mfx"}}, err: `invalid denom "1mfx"`},
+		{name: "denom with a space", tokenMap: map[string]utils.TokenInfo{"dummy": {Denom: "u mfx"}}, err: `invalid denom "u mfx"`},
+		{name: "duplicate denom", tokenMap: map[string]utils.TokenInfo{"b": {Denom: "umfx"}, "a": {Denom: "umfx"}},
+			err: "tokens a and b map to the same denom umfx in token map"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := config.ValidateTokenMap(tc.tokenMap)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNeighborhoodConfig_Apply(t *testing.T) {
+	maxFee := uint64(0)
+	maxAttempts := uint(10)
+	base := config.MigrateConfig{
+		ChainID:     "manifest-1",
+		TokenMap:    map[string]utils.TokenInfo{"dummy": {Denom: "umfx"}},
+		MaxFee:      1000,
+		MaxAttempts: 5,
+		Policy:      policy.Config{DefaultAction: policy.Allow},
+	}
+
+	// Unset settings are inherited
+	require.Equal(t, base, config.NeighborhoodConfig{ID: 1}.Apply(base))
+
+	n := config.NeighborhoodConfig{
+		ID:          2,
+		TokenMap:    map[string]utils.TokenInfo{"dummy": {Denom: "utest"}},
+		Policy:      &policy.Config{DefaultAction: policy.Hold},
+		MaxFee:      &maxFee,
+		MaxAttempts: &maxAttempts,
+	}
+	applied := n.Apply(base)
+	require.Equal(t, "manifest-1", applied.ChainID)
+	require.Equal(t, "utest", applied.TokenMap["dummy"].Denom)
+	require.Equal(t, policy.Hold, applied.Policy.DefaultAction)
+	require.Equal(t, uint64(0), applied.MaxFee)
+	require.Equal(t, uint(10), applied.MaxAttempts)
+
+	// The base configuration is left untouched
+	require.Equal(t, "umfx", base.TokenMap["dummy"].Denom)
+	require.Equal(t, uint64(1000), base.MaxFee)
+}
+
+func TestValidateNeighborhoods(t *testing.T) {
+	tt := []struct {
+		name          string
+		neighborhoods []config.NeighborhoodConfig
+		err           string
+	}{
+		{name: "empty"},
+		{name: "valid", neighborhoods: []config.NeighborhoodConfig{{ID: 0}, {ID: 1, PollInterval: time.Minute}}},
+		{name: "duplicate", neighborhoods: []config.NeighborhoodConfig{{ID: 1}, {ID: 2}, {ID: 1}}, err: "neighborhood 1 is configured more than once"},
+		{name: "negative poll interval", neighborhoods: []config.NeighborhoodConfig{{ID: 3, PollInterval: -time.Second}},
+			err: "poll interval of neighborhood 3 must be >= 0"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := config.ValidateNeighborhoods(tc.neighborhoods)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// Package configfile reads the configuration file, strictly, and migrates it from the older versions of its format.
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/notify"
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+)
+
+// CurrentVersion is the version of the configuration file format.
+// Files without a version are version 1.
+const CurrentVersion = 2
+
+// Settings are the settings of the configuration file, and of its profiles.
+// The keys are the configuration keys of the flags, e.g. `chain-id` for `--chain-id`.
+type Settings struct {
+	// Global settings
+	LogLevel         string        `mapstructure:"log-level"`
+	Url              string        `mapstructure:"url"`
+	Neighborhood     uint64        `mapstructure:"neighborhood"`
+	Username         string        `mapstructure:"username"`
+	Password         string        `mapstructure:"password"`
+	UsernameFile     string        `mapstructure:"username-file"`
+	PasswordFile     string        `mapstructure:"password-file"`
+	CredentialHelper string        `mapstructure:"credential-helper"`
+	HttpRetryCount   int           `mapstructure:"http-retry-count"`
+	HttpRetryWait    time.Duration `mapstructure:"http-retry-wait"`
+	HttpRetryMaxWait time.Duration `mapstructure:"http-retry-max-wait"`
+	HttpRetryBudget  time.Duration `mapstructure:"http-retry-budget"`
+	HttpTimeout      time.Duration `mapstructure:"http-timeout"`
+	CAFile           string        `mapstructure:"ca-file"`
+	ClientCert       string        `mapstructure:"client-cert"`
+	ClientKey        string        `mapstructure:"client-key"`
+	TLSMinVersion    string        `mapstructure:"tls-min-version"`
+	TLSPins          []string      `mapstructure:"tls-pin"`
+	MetricsListen    string        `mapstructure:"metrics-listen"`
+	OTLPEndpoint     string        `mapstructure:"otlp-endpoint"`
+	Profile          string        `mapstructure:"profile"`
+	StateDir         string        `mapstructure:"state-dir"`
+
+	// Migration settings
+	ChainID             string                     `mapstructure:"chain-id"`
+	AddressPrefix       string                     `mapstructure:"address-prefix"`
+	NodeAddress         string                     `mapstructure:"node-address"`
+	KeyringBackend      string                     `mapstructure:"keyring-backend"`
+	BankAddress         string                     `mapstructure:"bank-address"`
+	ChainHome           string                     `mapstructure:"chain-home"`
+	Binary              string                     `mapstructure:"binary"`
+	GasDenom            string                     `mapstructure:"gas-denom"`
+	FeeGranter          string                     `mapstructure:"fee-granter"`
+	GasPriceSource      string                     `mapstructure:"gas-price-source"`
+	WaitForTxTimeout    uint                       `mapstructure:"wait-for-tx-timeout"`
+	WaitForBlockTimeout uint                       `mapstructure:"wait-for-block-timeout"`
+	MaxAttempts         uint                       `mapstructure:"max-attempts"`
+	ConfirmationDepth   uint                       `mapstructure:"confirmation-depth"`
+	MaxFee              uint                       `mapstructure:"max-fee"`
+	GasPrice            float64                    `mapstructure:"gas-price"`
+	GasAdjustment       float64                    `mapstructure:"gas-adjustment"`
+	RetryBackoff        time.Duration              `mapstructure:"retry-backoff"`
+	RetryMaxBackoff     time.Duration              `mapstructure:"retry-max-backoff"`
+	TokenMap            map[string]utils.TokenInfo `mapstructure:"token-map"`
+	Policy              policy.Config              `mapstructure:"policy"`
+	Notify              notify.Config              `mapstructure:"notify"`
+	KillSwitchFile      string                     `mapstructure:"kill-switch-file"`
+	KillSwitchTalib     bool                       `mapstructure:"kill-switch-talib"`
+	KillSwitchChain     bool                       `mapstructure:"kill-switch-chain"`
+
+	// Other commands settings
+	QuarantineDir        string                      `mapstructure:"quarantine-dir"`
+	MockServerListen     string                      `mapstructure:"mock-server-listen"`
+	MockServerMigrations string                      `mapstructure:"mock-server-migrations"`
+	PollInterval         time.Duration               `mapstructure:"poll-interval"`
+	ShutdownTimeout      time.Duration               `mapstructure:"shutdown-timeout"`
+	Neighborhoods        []config.NeighborhoodConfig `mapstructure:"neighborhoods"`
+	AdminListen          string                      `mapstructure:"admin-listen"`
+	AdminToken           string                      `mapstructure:"admin-token"`
+	AdminTokenFile       string                      `mapstructure:"admin-token-file"`
+}
+
+// File is the configuration file.
+type File struct {
+	Version  int `mapstructure:"version"`
+	Settings `mapstructure:",squash"`
+	Profiles map[string]Settings `mapstructure:"profiles"`
+}
+
+// migrations migrate the settings of a file, or of a profile, from the version to the next version.
+// The keys are lower case, as read by viper.
+var migrations = map[int]func(settings map[string]any){
+	// Version 2 renames `logLevel` to `log-level`, like the other keys
+	1: func(settings map[string]any) { renameKey(settings, "loglevel", "log-level") },
+}
+
+func renameKey(settings map[string]any, from string, to string) {
+	if value, ok := settings[from]; ok {
+		delete(settings, from)
+		if _, exists := settings[to]; !exists {
+			settings[to] = value
+		}
+	}
+}
+
+// Read reads the configuration file, migrates it to the current version, and decodes it strictly.
+// It returns the version of the file as read, and the migrated settings, with lower case keys.
+func Read(path string) (*File, map[string]any, int, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, 0, errors.WithMessagef(err, "could not read config file %s", path)
+	}
+
+	settings := v.AllSettings()
+	version, err := Migrate(settings)
+	if err != nil {
+		return nil, nil, 0, errors.WithMessagef(err, "invalid config file %s", path)
+	}
+
+	file, err := Decode(settings)
+	if err != nil {
+		return nil, nil, 0, errors.WithMessagef(err, "invalid config file %s", path)
+	}
+	return file, settings, version, nil
+}
+
+// Write writes the settings to the configuration file, in the format of its extension.
+// The previous file is kept as a backup, whose path is returned. Comments and key order are not preserved.
+func Write(path string, settings map[string]any) (string, error) {
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.WithMessage(err, "could not read config file")
+	}
+
+	backup := path + ".bak"
+	if err = os.WriteFile(backup, previous, 0o600); err != nil {
+		return "", errors.WithMessage(err, "could not back up config file")
+	}
+
+	v := viper.New()
+	if err = v.MergeConfigMap(settings); err != nil {
+		return "", err
+	}
+	if err = v.WriteConfigAs(path); err != nil {
+		return "", errors.WithMessage(err, "could not write config file")
+	}
+	return backup, nil
+}
+
+// Migrate migrates the settings of a configuration file to the current version, in place.
+// It returns the version of the settings before the migration.
+func Migrate(settings map[string]any) (int, error) {
+	version := 1
+	if raw, ok := settings["version"]; ok {
+		if err := mapstructure.WeakDecode(raw, &version); err != nil {
+			return 0, fmt.Errorf("invalid version: %v", raw)
+		}
+	}
+	if version < 1 || version > CurrentVersion {
+		return 0, fmt.Errorf("unsupported version %d, the latest version is %d", version, CurrentVersion)
+	}
+
+	profiles, _ := settings["profiles"].(map[string]any)
+	for from := version; from < CurrentVersion; from++ {
+		migrations[from](settings)
+		for _, profile := range profiles {
+			if p, ok := profile.(map[string]any); ok {
+				migrations[from](p)
+			}
+		}
+	}
+	settings["version"] = CurrentVersion
+
+	return version, nil
+}
+
+// Decode decodes the settings of a configuration file, rejecting unknown keys and values of the wrong type.
+func Decode(settings map[string]any) (*File, error) {
+	var file File
+	var metadata mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		Metadata:         &metadata,
+		Result:           &file,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = decoder.Decode(settings); err != nil {
+		var decodeErr *mapstructure.Error
+		if errors.As(err, &decodeErr) {
+			slices.Sort(decodeErr.Errors)
+			return nil, errors.New(strings.Join(decodeErr.Errors, ", "))
+		}
+		return nil, err
+	}
+
+	if len(metadata.Unused) > 0 {
+		slices.Sort(metadata.Unused)
+		return nil, fmt.Errorf("unknown key(s): %s", strings.Join(metadata.Unused, ", "))
+	}
+
+	return &file, nil
+}
@@ -0,0 +1,160 @@
+package configfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/configfile"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRead(t *testing.T) {
+	tt := []struct {
+		name    string
+		file    string
+		content string
+		version int
+		err     string
+	}{
+		{name: "latest version", file: "migrator-config.yaml", content: `
+version: 2
+log-level: debug
+http-timeout: 30s
+tls-pin: [sha256//a, sha256//b]
+token-map:
+  dummy:
+    denom: umfx
+policy:
+  default-action: hold
+notify:
+  webhooks:
+    - url: https://hooks.example.com
+      secret: secret
+profiles:
+  testnet:
+    chain-id: manifest-testnet
+`, version: 2},
+		{name: "no version", file: "migrator-config.yaml", content: `
+logLevel: debug
+profiles:
+  testnet:
+    logLevel: warn
+`, version: 1},
+		{name: "json", file: "migrator-config.json", content: `{"version": 2, "log-level": "debug", "neighborhood": 1}`, version: 2},
+		{name: "toml", file: "migrator-config.toml", content: "version = 2\nlog-level = \"debug\"\n", version: 2},
+		{name: "unsupported version", file: "migrator-config.yaml", content: "version: 3\n", err: "unsupported version 3, the latest version is 2"},
+		{name: "invalid version", file: "migrator-config.yaml", content: "version: latest\n", err: "invalid version: latest"},
+		{name: "unknown key", file: "migrator-config.yaml", content: "version: 2\ngas-prices: 0.01\n", err: "unknown key(s): gas-prices"},
+		{name: "unknown profile key", file: "migrator-config.yaml", content: "version: 2\nprofiles:\n  testnet:\n    chainid: manifest-testnet\n",
+			err: "unknown key(s): profiles[testnet].chainid"},
+		{name: "nested profile", file: "migrator-config.yaml", content: "version: 2\nprofiles:\n  testnet:\n    profiles:\n      devnet:\n        chain-id: manifest-devnet\n",
+			err: "unknown key(s): profiles[testnet].profiles"},
+		{name: "unknown token map key", file: "migrator-config.yaml", content: "version: 2\ntoken-map:\n  dummy:\n    demon: umfx\n",
+			err: "unknown key(s): token-map[dummy].demon"},
+		{name: "unknown policy key", file: "migrator-config.yaml", content: "version: 2\npolicy:\n  rules:\n    - name: big\n      max: 100\n",
+			err: "unknown key(s): policy.rules[0].max"},
+		{name: "unknown neighborhood key", file: "migrator-config.yaml", content: "version: 2\nneighborhoods:\n  - id: 1\n    max-fees: 100\n",
+			err: "unknown key(s): neighborhoods[0].max-fees"},
+		{name: "wrong type", file: "migrator-config.yaml", content: "version: 2\nhttp-retry-count: many\n", err: "http-retry-count"},
+		{name: "invalid duration", file: "migrator-config.yaml", content: "version: 2\nretry-backoff: 1 minute\n", err: "retry-backoff"},
+		{name: "malformed file", file: "migrator-config.yaml", content: "version: [\n", err: "could not read config file"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, tc.file, tc.content)
+
+			file, settings, version, err := configfile.Read(path)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				require.ErrorContains(t, err, path)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.version, version)
+			require.Equal(t, configfile.CurrentVersion, file.Version)
+			require.Equal(t, configfile.CurrentVersion, settings["version"])
+			require.Equal(t, "debug", file.LogLevel)
+		})
+	}
+}
+
+func TestRead_Values(t *testing.T) {
+	path := writeFile(t, "migrator-config.yaml", `
+logLevel: debug
+http-timeout: 30s
+tls-pin: [sha256//a, sha256//b]
+token-map:
+  dummy:
+    denom: umfx
+neighborhoods:
+  - id: 1
+    poll-interval: 30s
+    max-fee: 1000
+  - id: 2
+    token-map:
+      dummy:
+        denom: utest
+profiles:
+  testnet:
+    logLevel: warn
+    neighborhood: "1"
+`)
+
+	file, settings, version, err := configfile.Read(path)
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+
+	require.Equal(t, "debug", file.LogLevel)
+	require.Equal(t, 30*time.Second, file.HttpTimeout)
+	require.Equal(t, []string{"sha256//a", "sha256//b"}, file.TLSPins)
+	require.Equal(t, "umfx", file.TokenMap["dummy"].Denom)
+	require.Len(t, file.Neighborhoods, 2)
+	require.Equal(t, uint64(1), file.Neighborhoods[0].ID)
+	require.Equal(t, 30*time.Second, file.Neighborhoods[0].PollInterval)
+	require.Equal(t, uint64(1000), *file.Neighborhoods[0].MaxFee)
+	require.Nil(t, file.Neighborhoods[0].TokenMap)
+	require.Equal(t, "utest", file.Neighborhoods[1].TokenMap["dummy"].Denom)
+	require.Nil(t, file.Neighborhoods[1].MaxFee)
+	require.Equal(t, "warn", file.Profiles["testnet"].LogLevel)
+	require.Equal(t, uint64(1), file.Profiles["testnet"].Neighborhood)
+
+	// The keys are renamed in the settings too
+	require.Equal(t, "debug", settings["log-level"])
+	require.NotContains(t, settings, "loglevel")
+	require.Equal(t, "warn", settings["profiles"].(map[string]any)["testnet"].(map[string]any)["log-level"])
+}
+
+func TestWrite(t *testing.T) {
+	original := "# Production\nlogLevel: debug\nchain-id: manifest-1\n"
+	path := writeFile(t, "migrator-config.yaml", original)
+
+	_, settings, _, err := configfile.Read(path)
+	require.NoError(t, err)
+
+	backup, err := configfile.Write(path, settings)
+	require.NoError(t, err)
+	require.Equal(t, path+".bak", backup)
+
+	saved, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	require.Equal(t, original, string(saved))
+
+	file, _, version, err := configfile.Read(path)
+	require.NoError(t, err)
+	require.Equal(t, configfile.CurrentVersion, version)
+	require.Equal(t, "debug", file.LogLevel)
+	require.Equal(t, "manifest-1", file.ChainID)
+}
@@ -0,0 +1,108 @@
+package errclass
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Class tells how a failed migration must be handled.
+type Class string
+
+const (
+	Transient    Class = "transient"    // Nothing happened, the migration can be retried later
+	Terminal     Class = "terminal"     // The migration can never succeed, the work item is rejected
+	Intervention Class = "intervention" // The tokens may have been sent, an operator must reconcile the work item
+)
+
+// Process exit codes of a failed command, by class.
+// Errors without a class, e.g. an invalid configuration, exit with ExitUnclassified.
+const (
+	ExitUnclassified = 1
+	ExitTransient    = 3
+	ExitTerminal     = 4
+	ExitIntervention = 5
+)
+
+var exitCodes = map[Class]int{
+	Transient:    ExitTransient,
+	Terminal:     ExitTerminal,
+	Intervention: ExitIntervention,
+}
+
+// Error is a classified error.
+type Error struct {
+	Class Class
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap classifies the error. It returns nil if err is nil.
+func Wrap(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// Transientf returns a formatted transient error.
+func Transientf(format string, args ...interface{}) error {
+	return Wrap(Transient, fmt.Errorf(format, args...))
+}
+
+// Terminalf returns a formatted terminal error.
+func Terminalf(format string, args ...interface{}) error {
+	return Wrap(Terminal, fmt.Errorf(format, args...))
+}
+
+// StatusCode returns the error of an unexpected HTTP status code.
+// Throttling and server errors are transient, other status codes are terminal.
+func StatusCode(statusCode int) error {
+	if statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError {
+		return Transientf("response status code: %d", statusCode)
+	}
+	return Terminalf("response status code: %d", statusCode)
+}
+
+// Request classifies the error of an HTTP request.
+// Response decoding errors are terminal, other errors, e.g. network errors, are transient.
+func Request(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return Wrap(Terminal, err)
+	}
+	return Wrap(Transient, err)
+}
+
+// Of returns the class of the error, the outermost one if the error was classified several times.
+// Unclassified errors are terminal.
+func Of(err error) Class {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	return Terminal
+}
+
+// ExitCode returns the process exit code of the error.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var classified *Error
+	if !errors.As(err, &classified) {
+		return ExitUnclassified
+	}
+	return exitCodes[classified.Class]
+}
@@ -0,0 +1,52 @@
+package errclass_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+)
+
+func TestErrclass(t *testing.T) {
+	syntaxErr := json.Unmarshal([]byte("{"), &struct{}{})
+
+	tt := []struct {
+		name     string
+		err      error
+		class    errclass.Class
+		exitCode int
+	}{
+		{name: "nil", err: nil, class: errclass.Terminal, exitCode: 0},
+		{name: "unclassified", err: errors.New("boom"), class: errclass.Terminal, exitCode: errclass.ExitUnclassified},
+		{name: "transient", err: errclass.Transientf("boom"), class: errclass.Transient, exitCode: errclass.ExitTransient},
+		{name: "terminal", err: errclass.Terminalf("boom"), class: errclass.Terminal, exitCode: errclass.ExitTerminal},
+		{name: "intervention", err: errclass.Wrap(errclass.Intervention, errors.New("boom")), class: errclass.Intervention, exitCode: errclass.ExitIntervention},
+		{name: "wrapped", err: errors.WithMessage(errclass.Transientf("boom"), "context"), class: errclass.Transient, exitCode: errclass.ExitTransient},
+		{name: "outermost class", err: errclass.Wrap(errclass.Intervention, fmt.Errorf("context: %w", errclass.Transientf("boom"))), class: errclass.Intervention, exitCode: errclass.ExitIntervention},
+		{name: "status code 404", err: errclass.StatusCode(404), class: errclass.Terminal, exitCode: errclass.ExitTerminal},
+		{name: "status code 429", err: errclass.StatusCode(429), class: errclass.Transient, exitCode: errclass.ExitTransient},
+		{name: "status code 502", err: errclass.StatusCode(502), class: errclass.Transient, exitCode: errclass.ExitTransient},
+		{name: "request error", err: errclass.Request(errors.New("connection refused")), class: errclass.Transient, exitCode: errclass.ExitTransient},
+		{name: "decoding error", err: errclass.Request(syntaxErr), class: errclass.Terminal, exitCode: errclass.ExitTerminal},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.class, errclass.Of(tc.err))
+			require.Equal(t, tc.exitCode, errclass.ExitCode(tc.err))
+		})
+	}
+}
+
+func TestErrclass_Message(t *testing.T) {
+	err := errors.New("boom")
+	classified := errclass.Wrap(errclass.Terminal, err)
+	require.Equal(t, err.Error(), classified.Error())
+	require.ErrorIs(t, classified, err)
+	require.Nil(t, errclass.Wrap(errclass.Terminal, nil))
+	require.EqualError(t, errclass.StatusCode(404), "response status code: 404")
+}
@@ -0,0 +1,8 @@
+//go:build !failpoint
+
+package failpoint
+
+// Inject does nothing, the failpoints are only compiled with the `failpoint` build tag.
+func Inject(string) error {
+	return nil
+}
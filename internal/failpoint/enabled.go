@@ -0,0 +1,44 @@
+//go:build failpoint
+
+package failpoint
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	mu      sync.RWMutex
+	enabled = map[string]Action{}
+)
+
+// Enable enables the failpoint.
+func Enable(name string, action Action) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled[name] = action
+}
+
+// Disable disables the failpoint.
+func Disable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(enabled, name)
+}
+
+// Inject triggers the failpoint if it is enabled. It returns an error, or panics with Crashed, depending on the
+// action of the failpoint. The error is not classified, the caller classifies it like the other errors of the step.
+func Inject(name string) error {
+	mu.RLock()
+	action, ok := enabled[name]
+	mu.RUnlock()
+
+	switch {
+	case !ok:
+		return nil
+	case action == Crash:
+		panic(Crashed{Name: name})
+	default:
+		return fmt.Errorf("failpoint %s", name)
+	}
+}
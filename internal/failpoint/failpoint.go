@@ -0,0 +1,37 @@
+// Package failpoint injects failures at the boundaries of the migration, to test how a migration recovers when the
+// migrator crashes or fails between two steps. Failpoints are only compiled with the `failpoint` build tag, and are
+// disabled unless enabled by a test. Without the tag, Inject does nothing.
+package failpoint
+
+import (
+	"fmt"
+)
+
+// Failpoints, in the order they are reached by a migration.
+const (
+	AfterMigrating    = "after-migrating"    // The work item was set as MIGRATING
+	AfterBroadcast    = "after-broadcast"    // The transaction was broadcast
+	AfterInclusion    = "after-inclusion"    // The transaction was included in a block
+	AfterConfirmation = "after-confirmation" // The transaction was confirmed
+	AfterCompleted    = "after-completed"    // The work item was set as COMPLETED, its local state is not deleted yet
+)
+
+// Points are the failpoints, in the order they are reached by a migration.
+var Points = []string{AfterMigrating, AfterBroadcast, AfterInclusion, AfterConfirmation, AfterCompleted}
+
+// Action is what an enabled failpoint does.
+type Action int
+
+const (
+	Error Action = iota + 1 // Return an error
+	Crash                   // Panic with Crashed, as if the migrator was killed
+)
+
+// Crashed is the value of the panic of a crashing failpoint.
+type Crashed struct {
+	Name string
+}
+
+func (c Crashed) String() string {
+	return fmt.Sprintf("crashed at failpoint %s", c.Name)
+}
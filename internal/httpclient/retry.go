@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+)
+
+type contextKey string
+
+// nonIdempotentKey marks a request that must not be replayed when its outcome is unknown.
+const nonIdempotentKey contextKey = "nonIdempotent"
+
+// alwaysRetryableStatusCodes are status codes meaning the request was not processed, so it is safe to retry any request.
+var alwaysRetryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+}
+
+// NonIdempotent marks the request as non-idempotent.
+// A non-idempotent request is only retried when the server signals it was not processed.
+func NonIdempotent(req *resty.Request) *resty.Request {
+	return req.SetContext(context.WithValue(req.Context(), nonIdempotentKey, true))
+}
+
+// IsIdempotent returns true if replaying the request has the same effect as sending it once.
+func IsIdempotent(req *resty.Request) bool {
+	if req.Context().Value(nonIdempotentKey) != nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryPolicy decides which requests are retried and for how long.
+type retryPolicy struct {
+	maxWaitTime time.Duration
+	deadline    time.Time // Zero if the retry budget is unlimited
+}
+
+// ApplyRetryPolicy configures the retry policy of the client.
+// The retry budget starts when the policy is applied and is shared by all the requests of the client.
+func ApplyRetryPolicy(client *resty.Client, c config.HttpConfig) *resty.Client {
+	p := &retryPolicy{maxWaitTime: c.RetryMaxWaitTime}
+	if c.RetryBudget > 0 {
+		p.deadline = time.Now().Add(c.RetryBudget)
+	}
+
+	return client.
+		SetRetryCount(c.RetryCount).             // Retry a request at most RetryCount times, with an exponential backoff
+		SetRetryWaitTime(c.RetryWaitTime).       // Starting with RetryWaitTime between retries
+		SetRetryMaxWaitTime(c.RetryMaxWaitTime). // And at most RetryMaxWaitTime between retries
+		SetTimeout(c.Timeout).                   // Each request times out after Timeout
+		AddRetryCondition(p.shouldRetry).
+		SetRetryAfter(p.retryAfter)
+}
+
+// shouldRetry is a resty retry condition.
+// Idempotent requests are retried on errors and server errors, other requests only on status codes meaning the
+// request was not processed. No request is retried once the retry budget is exhausted.
+func (p *retryPolicy) shouldRetry(resp *resty.Response, err error) bool {
+	if resp == nil || resp.Request == nil {
+		return false
+	}
+
+	retry := false
+	if resp.RawResponse != nil && alwaysRetryableStatusCodes[resp.StatusCode()] {
+		retry = true
+	} else if IsIdempotent(resp.Request) {
+		retry = err != nil || (resp.RawResponse != nil && resp.StatusCode() >= http.StatusInternalServerError)
+	}
+
+	if retry && p.exhausted() {
+		slog.Warn("HTTP retry budget exhausted", "method", resp.Request.Method, "url", resp.Request.URL)
+		return false
+	}
+
+	return retry
+}
+
+// retryAfter is a resty retry-after function honoring the Retry-After header.
+// It returns an error if the server asks to wait longer than the maximum wait time or the remaining budget.
+func (p *retryPolicy) retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	wait, ok := parseRetryAfter(resp.Header().Get("Retry-After"), time.Now())
+	if !ok {
+		return 0, nil // Use the default backoff
+	}
+
+	if p.maxWaitTime > 0 && wait > p.maxWaitTime {
+		return 0, fmt.Errorf("server asked to retry after %s, more than the maximum wait time %s", wait, p.maxWaitTime)
+	}
+
+	if !p.deadline.IsZero() && time.Now().Add(wait).After(p.deadline) {
+		return 0, fmt.Errorf("server asked to retry after %s, exceeding the retry budget", wait)
+	}
+
+	return wait, nil
+}
+
+// exhausted returns true if the retry budget is exhausted.
+func (p *retryPolicy) exhausted() bool {
+	return !p.deadline.IsZero() && time.Now().After(p.deadline)
+}
+
+// parseRetryAfter parses a Retry-After header value, either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,110 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/httpclient"
+)
+
+var testHttpConfig = config.HttpConfig{
+	RetryCount:       3,
+	RetryWaitTime:    time.Millisecond,
+	RetryMaxWaitTime: 10 * time.Millisecond,
+	Timeout:          time.Second,
+}
+
+// statusServer returns a server responding with the given status code and headers, and counting the requests.
+func statusServer(t *testing.T, statusCode int, headers map[string]string) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tt := []struct {
+		name          string
+		method        string
+		nonIdempotent bool
+		statusCode    int
+		headers       map[string]string
+		config        config.HttpConfig
+		calls         int32
+	}{
+		{name: "success", method: http.MethodGet, statusCode: http.StatusOK, config: testHttpConfig, calls: 1},
+		{name: "client error", method: http.MethodGet, statusCode: http.StatusNotFound, config: testHttpConfig, calls: 1},
+		{name: "idempotent server error", method: http.MethodGet, statusCode: http.StatusInternalServerError, config: testHttpConfig, calls: 4},
+		{name: "idempotent put", method: http.MethodPut, statusCode: http.StatusGatewayTimeout, config: testHttpConfig, calls: 4},
+		{name: "post server error", method: http.MethodPost, statusCode: http.StatusInternalServerError, config: testHttpConfig, calls: 1},
+		{name: "non-idempotent server error", method: http.MethodPut, nonIdempotent: true, statusCode: http.StatusInternalServerError, config: testHttpConfig, calls: 1},
+		{name: "non-idempotent gateway timeout", method: http.MethodPut, nonIdempotent: true, statusCode: http.StatusGatewayTimeout, config: testHttpConfig, calls: 1},
+		{name: "non-idempotent too many requests", method: http.MethodPut, nonIdempotent: true, statusCode: http.StatusTooManyRequests, config: testHttpConfig, calls: 4},
+		{name: "non-idempotent bad gateway", method: http.MethodPut, nonIdempotent: true, statusCode: http.StatusBadGateway, config: testHttpConfig, calls: 4},
+		{name: "non-idempotent service unavailable", method: http.MethodPut, nonIdempotent: true, statusCode: http.StatusServiceUnavailable, config: testHttpConfig, calls: 4},
+		{name: "retry after within max wait", method: http.MethodGet, statusCode: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": "0"}, config: testHttpConfig, calls: 4},
+		{name: "retry after exceeding max wait", method: http.MethodGet, statusCode: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": "120"}, config: testHttpConfig, calls: 1},
+		{name: "retry after exceeding budget", method: http.MethodGet, statusCode: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": "1"},
+			config: config.HttpConfig{RetryCount: 3, RetryWaitTime: time.Millisecond, RetryMaxWaitTime: time.Minute, RetryBudget: 100 * time.Millisecond, Timeout: time.Second}, calls: 1},
+		{name: "budget exhausted", method: http.MethodGet, statusCode: http.StatusServiceUnavailable,
+			config: config.HttpConfig{RetryCount: 100, RetryWaitTime: 20 * time.Millisecond, RetryMaxWaitTime: 20 * time.Millisecond, RetryBudget: 50 * time.Millisecond, Timeout: time.Second}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := statusServer(t, tc.statusCode, tc.headers)
+			client := httpclient.ApplyRetryPolicy(resty.New().SetBaseURL(server.URL), tc.config)
+
+			req := client.R()
+			if tc.nonIdempotent {
+				req = httpclient.NonIdempotent(req)
+			}
+			resp, err := req.Execute(tc.method, "/")
+			if err == nil {
+				require.Equal(t, tc.statusCode, resp.StatusCode())
+			}
+
+			if tc.calls > 0 {
+				require.Equal(t, tc.calls, calls.Load())
+			} else {
+				require.Greater(t, calls.Load(), int32(1))
+				require.Less(t, calls.Load(), int32(100))
+			}
+		})
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	client := resty.New()
+
+	tt := []struct {
+		method   string
+		req      *resty.Request
+		expected bool
+	}{
+		{method: http.MethodGet, req: client.R(), expected: true},
+		{method: http.MethodPut, req: client.R(), expected: true},
+		{method: http.MethodDelete, req: client.R(), expected: true},
+		{method: http.MethodPost, req: client.R(), expected: false},
+		{method: http.MethodPatch, req: client.R(), expected: false},
+		{method: http.MethodPut, req: httpclient.NonIdempotent(client.R()), expected: false},
+	}
+
+	for _, tc := range tt {
+		tc.req.Method = tc.method
+		require.Equal(t, tc.expected, httpclient.IsIdempotent(tc.req), tc.method)
+	}
+}
@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+)
+
+// PinPrefix is the prefix of a certificate pin, followed by the base64 encoded SHA-256 digest of the
+// certificate public key (SubjectPublicKeyInfo), as used by `curl --pinnedpubkey`.
+const PinPrefix = "sha256//"
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ApplyTLS configures the TLS settings of the client, if any TLS option is set.
+func ApplyTLS(client *resty.Client, c config.HttpConfig) error {
+	if !c.HasTLS() {
+		return nil
+	}
+
+	tlsConfig, err := NewTLSConfig(c)
+	if err != nil {
+		return err
+	}
+
+	transport, err := client.Transport()
+	if err != nil {
+		return errors.WithMessage(err, "could not configure TLS")
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return nil
+}
+
+// NewTLSConfig creates the TLS configuration from the CA bundle, the client certificate, the minimum TLS version and
+// the certificate pins.
+func NewTLSConfig(c config.HttpConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.TLSMinVersion != "" {
+		version, ok := tlsVersions[c.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version: %s", c.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if c.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "could not read CA bundle")
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "could not load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(c.TLSPins) > 0 {
+		pins, err := parsePins(c.TLSPins)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = verifyPins(pins)
+	}
+
+	return tlsConfig, nil
+}
+
+// parsePins decodes the certificate pins.
+func parsePins(values []string) (map[[sha256.Size]byte]bool, error) {
+	pins := make(map[[sha256.Size]byte]bool, len(values))
+	for _, value := range values {
+		encoded, ok := strings.CutPrefix(value, PinPrefix)
+		if !ok {
+			return nil, fmt.Errorf("invalid certificate pin %s: missing %s prefix", value, PinPrefix)
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %s: not a base64 encoded SHA-256 digest", value)
+		}
+		pins[[sha256.Size]byte(digest)] = true
+	}
+	return pins, nil
+}
+
+// verifyPins returns a tls.Config.VerifyConnection function requiring a certificate of the verified chains, including
+// the trusted root, to match one of the pins. It runs after the standard certificate verification.
+func verifyPins(pins map[[sha256.Size]byte]bool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no certificate of the server chain matches the configured pins")
+	}
+}
+
+// Pin returns the pin of a certificate.
+func Pin(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return PinPrefix + base64.StdEncoding.EncodeToString(digest[:])
+}
@@ -0,0 +1,186 @@
+package httpclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/httpclient"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	tlsCert tls.Certificate
+}
+
+// newTestCert creates a certificate signed by the parent, or self-signed if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{cert: cert, key: key, tlsCert: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}}
+}
+
+// writePEM writes the certificate and key of c to PEM files and returns their paths.
+func (c *testCert) writePEM(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	certPath := filepath.Join(dir, c.cert.Subject.CommonName+".crt")
+	keyPath := filepath.Join(dir, c.cert.Subject.CommonName+".key")
+
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	return certPath, keyPath
+}
+
+func TestApplyTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "ca", nil, true)
+	serverCert := newTestCert(t, "server", ca, false)
+	clientCert := newTestCert(t, "client", ca, false)
+	otherCert := newTestCert(t, "other", nil, false)
+
+	caFile, _ := ca.writePEM(t, dir)
+	clientCertFile, clientKeyFile := clientCert.writePEM(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert.tlsCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MaxVersion:   tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	mtls := config.HttpConfig{CAFile: caFile, ClientCertFile: clientCertFile, ClientKeyFile: clientKeyFile}
+
+	tt := []struct {
+		name   string
+		config func() config.HttpConfig
+		err    string
+	}{
+		{name: "unknown authority", config: func() config.HttpConfig {
+			return config.HttpConfig{TLSMinVersion: "1.2"}
+		}, err: "certificate signed by unknown authority"},
+		{name: "missing client certificate", config: func() config.HttpConfig {
+			return config.HttpConfig{CAFile: caFile}
+		}, err: "handshake failure"},
+		{name: "mutual TLS", config: func() config.HttpConfig {
+			return mtls
+		}},
+		{name: "matching server pin", config: func() config.HttpConfig {
+			c := mtls
+			c.TLSPins = []string{httpclient.Pin(otherCert.cert), httpclient.Pin(serverCert.cert)}
+			return c
+		}},
+		{name: "matching CA pin", config: func() config.HttpConfig {
+			c := mtls
+			c.TLSPins = []string{httpclient.Pin(ca.cert)}
+			return c
+		}},
+		{name: "pin mismatch", config: func() config.HttpConfig {
+			c := mtls
+			c.TLSPins = []string{httpclient.Pin(otherCert.cert)}
+			return c
+		}, err: "no certificate of the server chain matches the configured pins"},
+		{name: "minimum version not supported by server", config: func() config.HttpConfig {
+			c := mtls
+			c.TLSMinVersion = "1.3"
+			return c
+		}, err: "protocol version"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := resty.New().SetBaseURL(server.URL)
+			require.NoError(t, httpclient.ApplyTLS(client, tc.config()))
+
+			resp, err := client.R().Get("/")
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode())
+		})
+	}
+}
+
+func TestNewTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "garbage.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("garbage"), 0o600))
+
+	tt := []struct {
+		name   string
+		config config.HttpConfig
+		err    string
+	}{
+		{name: "unsupported version", config: config.HttpConfig{TLSMinVersion: "1.1"}, err: "unsupported TLS version"},
+		{name: "missing CA bundle", config: config.HttpConfig{CAFile: filepath.Join(dir, "missing.pem")}, err: "could not read CA bundle"},
+		{name: "invalid CA bundle", config: config.HttpConfig{CAFile: notPEM}, err: "no certificate found in CA bundle"},
+		{name: "invalid client certificate", config: config.HttpConfig{ClientCertFile: notPEM, ClientKeyFile: notPEM}, err: "could not load client certificate"},
+		{name: "pin without prefix", config: config.HttpConfig{TLSPins: []string{"abc"}}, err: "missing sha256// prefix"},
+		{name: "pin not a digest", config: config.HttpConfig{TLSPins: []string{"sha256//YWJj"}}, err: "not a base64 encoded SHA-256 digest"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := httpclient.NewTLSConfig(tc.config)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
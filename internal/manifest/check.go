@@ -0,0 +1,102 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+)
+
+type DenomSupply struct {
+	Amount struct {
+		Denom  string `json:"denom"`
+		Amount string `json:"amount"`
+	} `json:"amount"`
+}
+
+// LatestHeight returns the height of the latest block of the node, checking the node is reachable.
+func LatestHeight(ctx context.Context, migrateConfig config.MigrateConfig) (int64, error) {
+	return latestHeight(ctx, migrateConfig)
+}
+
+// KeyAddress returns the address of the bank key, checking the key exists in the keyring.
+// The bank address can be the name or the address of the key.
+func KeyAddress(ctx context.Context, migrateConfig config.MigrateConfig) (string, error) {
+	keysShow := []string{"keys", "show", migrateConfig.BankAddress, "--address",
+		"--keyring-backend", migrateConfig.KeyringBackend, "--home", migrateConfig.ChainHome}
+	o, err := executeCommand(ctx, migrateConfig.Binary, keysShow...)
+	if err != nil {
+		return "", errors.WithMessagef(err, "failed to find key %s", migrateConfig.BankAddress)
+	}
+	return strings.TrimSpace(string(o)), nil
+}
+
+// GetDenomSupply returns the total supply of the denom. The supply of a denom unknown to the chain is zero.
+func GetDenomSupply(ctx context.Context, migrateConfig config.MigrateConfig, denom string) (*big.Int, error) {
+	qSupply := []string{"q", "bank", "total-supply-of", denom,
+		"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	o, err := executeCommand(ctx, migrateConfig.Binary, qSupply...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to query denom supply")
+	}
+
+	var supply DenomSupply
+	if err = unmarshalOutput(o, &supply); err != nil {
+		return nil, err
+	}
+
+	amount, ok := new(big.Int).SetString(supply.Amount.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid denom supply: %s", supply.Amount.Amount)
+	}
+	return amount, nil
+}
+
+type SendEnabled struct {
+	SendEnabled []struct {
+		Denom   string `json:"denom"`
+		Enabled bool   `json:"enabled"`
+	} `json:"send_enabled"`
+}
+
+type BankParams struct {
+	Params struct {
+		DefaultSendEnabled bool `json:"default_send_enabled"`
+	} `json:"params"`
+}
+
+// IsSendEnabled returns true if the denom can be sent on chain, according to the send-enabled parameter of the bank
+// module. A denom without its own parameter follows the default of the bank module.
+func IsSendEnabled(ctx context.Context, migrateConfig config.MigrateConfig, denom string) (bool, error) {
+	node := []string{"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+
+	o, err := executeCommand(ctx, migrateConfig.Binary, append([]string{"q", "bank", "send-enabled", denom}, node...)...)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to query send enabled")
+	}
+
+	var sendEnabled SendEnabled
+	if err = unmarshalOutput(o, &sendEnabled); err != nil {
+		return false, err
+	}
+	for _, s := range sendEnabled.SendEnabled {
+		if s.Denom == denom {
+			return s.Enabled, nil
+		}
+	}
+
+	o, err = executeCommand(ctx, migrateConfig.Binary, append([]string{"q", "bank", "params"}, node...)...)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to query bank params")
+	}
+
+	var params BankParams
+	if err = unmarshalOutput(o, &params); err != nil {
+		return false, err
+	}
+	return params.Params.DefaultSendEnabled, nil
+}
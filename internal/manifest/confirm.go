@@ -0,0 +1,105 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// pollInterval is the time between two queries of the latest block height.
+var pollInterval = time.Second
+
+type NodeStatus struct {
+	SyncInfo struct {
+		LatestBlockHeight string `json:"latest_block_height"`
+	} `json:"sync_info"`
+}
+
+// Confirm waits until the chain is the confirmation depth past the inclusion height of the transaction, then checks
+// the transaction is still included in a committed block at the same height and succeeded.
+// Waiting for each block times out after the wait for block timeout.
+// Errors are classified: a timeout or a failed query is transient, as the transaction hash is recorded and FindTransfer
+// finds the transfer again on the next attempt; a transaction moved to another block or failed on the second look is
+// intervention required.
+func Confirm(ctx context.Context, migrateConfig config.MigrateConfig, tx *CosmosTx) (*store.Confirmation, error) {
+	height, err := strconv.ParseInt(tx.Height, 10, 64)
+	if err != nil {
+		return nil, errclass.Wrap(errclass.Intervention, fmt.Errorf("invalid inclusion height: %s", tx.Height))
+	}
+
+	confirmation := &store.Confirmation{Depth: migrateConfig.ConfirmationDepth, Height: height, ConfirmedHeight: height}
+	if migrateConfig.ConfirmationDepth == 0 {
+		return confirmation, nil
+	}
+
+	target := height + int64(migrateConfig.ConfirmationDepth)
+	timeout := time.Duration(migrateConfig.ConfirmationDepth) * time.Duration(migrateConfig.WaitBlockTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	slog.Info("Waiting for confirmations...", "hash", tx.TxHash, "height", height, "depth", migrateConfig.ConfirmationDepth)
+	for {
+		latest, err := latestHeight(ctx, migrateConfig)
+		if err != nil {
+			return nil, errclass.Wrap(errclass.Transient, err)
+		}
+		if latest >= target {
+			confirmation.ConfirmedHeight = latest
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errclass.Transientf("timed out waiting for block %d, latest block is %d", target, latest)
+		case <-time.After(pollInterval):
+		}
+	}
+
+	// The transaction must still be included at the same height
+	qTx := []string{"q", "tx", tx.TxHash,
+		"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	o, err := executeCommand(ctx, migrateConfig.Binary, qTx...)
+	if err != nil {
+		return nil, errclass.Wrap(errclass.Transient, errors.WithMessage(err, "failed to query transaction"))
+	}
+
+	var committed CosmosTx
+	if err = unmarshalOutput(o, &committed); err != nil {
+		return nil, errclass.Wrap(errclass.Transient, err)
+	}
+	if committed.Height != tx.Height {
+		return nil, errclass.Wrap(errclass.Intervention, fmt.Errorf("transaction %s moved from block %s to block %s", tx.TxHash, tx.Height, committed.Height))
+	}
+	if committed.Code != 0 {
+		return nil, errclass.Wrap(errclass.Intervention, fmt.Errorf("transaction %s failed: %s", tx.TxHash, committed.RawLog))
+	}
+
+	return confirmation, nil
+}
+
+// latestHeight returns the height of the latest block of the chain.
+func latestHeight(ctx context.Context, migrateConfig config.MigrateConfig) (int64, error) {
+	o, err := executeCommand(ctx, migrateConfig.Binary, "status", "--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to query node status")
+	}
+
+	var status NodeStatus
+	if err = unmarshalOutput(o, &status); err != nil {
+		return 0, err
+	}
+
+	height, err := strconv.ParseInt(status.SyncInfo.LatestBlockHeight, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid latest block height: %s", status.SyncInfo.LatestBlockHeight)
+	}
+	return height, nil
+}
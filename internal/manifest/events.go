@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"fmt"
+)
+
+// Types and attributes of the bank events emitted by a transfer.
+const (
+	transferEvent      = "transfer"
+	coinReceivedEvent  = "coin_received"
+	recipientAttribute = "recipient"
+	receiverAttribute  = "receiver"
+	amountAttribute    = "amount"
+)
+
+type EventAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type Event struct {
+	Type       string           `json:"type"`
+	Attributes []EventAttribute `json:"attributes"`
+}
+
+// attribute returns the value of the first attribute with the given key.
+func (e Event) attribute(key string) (string, bool) {
+	for _, a := range e.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// VerifyTransfer checks the events of the included transaction show the coin, e.g. `123umfx`, was transferred to and
+// received by the recipient, and nothing else was sent to the recipient.
+// Fee transfers go to the fee collector and are ignored.
+func VerifyTransfer(tx *CosmosTx, recipient string, coin string) error {
+	if err := verifyEvent(tx.Events, transferEvent, recipientAttribute, recipient, coin); err != nil {
+		return err
+	}
+	return verifyEvent(tx.Events, coinReceivedEvent, receiverAttribute, recipient, coin)
+}
+
+// verifyEvent checks exactly one event of the given type names the recipient in the given attribute, with the coin as
+// amount.
+func verifyEvent(events []Event, eventType string, key string, recipient string, coin string) error {
+	var amounts []string
+	for _, e := range events {
+		if e.Type != eventType {
+			continue
+		}
+		if value, _ := e.attribute(key); value != recipient {
+			continue
+		}
+		amount, _ := e.attribute(amountAttribute)
+		amounts = append(amounts, amount)
+	}
+
+	switch {
+	case len(amounts) == 0:
+		return fmt.Errorf("no %s event to %s", eventType, recipient)
+	case len(amounts) > 1:
+		return fmt.Errorf("%d %s events to %s, expected 1", len(amounts), eventType, recipient)
+	case amounts[0] != coin:
+		return fmt.Errorf("%s event to %s has amount %s, expected %s", eventType, recipient, amounts[0], coin)
+	}
+	return nil
+}
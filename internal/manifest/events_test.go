@@ -0,0 +1,58 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/manifest"
+)
+
+const (
+	bank         = "manifest1hj5fveer5cjtn4wd6wstzugjfdxzl0xp8ws9ct"
+	recipient    = "manifest1ztq8wjzpyen4s5jqfyrd6tnj9cntnxetgf7t7z"
+	feeCollector = "manifest17xpfvakm2amg962yls6f84z3kell8c5l6s5ye9"
+)
+
+func transfer(to string, amount string) []manifest.Event {
+	return []manifest.Event{
+		{Type: "coin_spent", Attributes: []manifest.EventAttribute{{Key: "spender", Value: bank}, {Key: "amount", Value: amount}}},
+		{Type: "coin_received", Attributes: []manifest.EventAttribute{{Key: "receiver", Value: to}, {Key: "amount", Value: amount}}},
+		{Type: "transfer", Attributes: []manifest.EventAttribute{{Key: "recipient", Value: to}, {Key: "sender", Value: bank}, {Key: "amount", Value: amount}}},
+	}
+}
+
+func TestVerifyTransfer(t *testing.T) {
+	fee := transfer(feeCollector, "90umfx")
+
+	tt := []struct {
+		name   string
+		events []manifest.Event
+		err    string
+	}{
+		{name: "transfer", events: append(fee, transfer(recipient, "123umfx")...)},
+		{name: "fee only", events: fee, err: "no transfer event to " + recipient},
+		{name: "no events", err: "no transfer event to " + recipient},
+		{name: "wrong amount", events: append(fee, transfer(recipient, "124umfx")...), err: "transfer event to " + recipient + " has amount 124umfx, expected 123umfx"},
+		{name: "wrong denom", events: transfer(recipient, "123uatom"), err: "has amount 123uatom, expected 123umfx"},
+		{name: "several coins", events: transfer(recipient, "123umfx,1uatom"), err: "has amount 123umfx,1uatom, expected 123umfx"},
+		{name: "wrong recipient", events: transfer(bank, "123umfx"), err: "no transfer event to " + recipient},
+		{name: "several transfers", events: append(transfer(recipient, "123umfx"), transfer(recipient, "123umfx")...), err: "2 transfer events to " + recipient + ", expected 1"},
+		{name: "not received", events: transfer(recipient, "123umfx")[2:], err: "no coin_received event to " + recipient},
+		{name: "received amount mismatch", events: []manifest.Event{
+			{Type: "coin_received", Attributes: []manifest.EventAttribute{{Key: "receiver", Value: recipient}, {Key: "amount", Value: "1umfx"}}},
+			{Type: "transfer", Attributes: []manifest.EventAttribute{{Key: "recipient", Value: recipient}, {Key: "amount", Value: "123umfx"}}},
+		}, err: "coin_received event to " + recipient + " has amount 1umfx, expected 123umfx"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := manifest.VerifyTransfer(&manifest.CosmosTx{Events: tc.events}, recipient, "123umfx")
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// gasEstimateRegexp matches the gas estimate, already adjusted, printed by a simulation
+var gasEstimateRegexp = regexp.MustCompile(`gas estimate: (\d+)`)
+
+// Fee is the estimated fee of a transaction.
+type Fee struct {
+	Gas    uint64   // Gas wanted, adjusted with the gas adjustment
+	Price  float64  // Gas price
+	Amount *big.Int // Fee amount, the gas wanted times the gas price, rounded up
+	Denom  string
+}
+
+type NodeConfig struct {
+	MinimumGasPrice string `json:"minimum_gas_price"`
+}
+
+type FeemarketGasPrice struct {
+	Price struct {
+		Denom  string `json:"denom"`
+		Amount string `json:"amount"`
+	} `json:"price"`
+}
+
+// Simulate simulates the transaction sending the given amount of tokens to the specified address, without
+// broadcasting it, and returns its fee. The fee must not exceed the maximum fee.
+// Errors are transient, nothing is sent.
+func Simulate(ctx context.Context, item *store.WorkItem, migrateConfig config.MigrateConfig, denom string, amount *big.Int) (*Fee, error) {
+	fee, err := simulate(ctx, item, migrateConfig, denom, amount)
+	if err != nil {
+		return nil, errclass.Wrap(errclass.Transient, err)
+	}
+
+	if migrateConfig.MaxFee > 0 && fee.Amount.Cmp(new(big.Int).SetUint64(migrateConfig.MaxFee)) > 0 {
+		return nil, errclass.Transientf("estimated fee %s%s exceeds the maximum fee %d%s", fee.Amount, fee.Denom, migrateConfig.MaxFee, fee.Denom)
+	}
+
+	return fee, nil
+}
+
+func simulate(ctx context.Context, item *store.WorkItem, migrateConfig config.MigrateConfig, denom string, amount *big.Int) (*Fee, error) {
+	price, err := gasPrice(ctx, migrateConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// A simulation does not access the keyring, the bank account must be an address
+	from, err := bankAddress(ctx, migrateConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	txSend := sendArgs(from, item, migrateConfig, denom, amount)
+	txSend = append(txSend, "--gas", "auto", "--gas-adjustment", fmt.Sprintf("%f", migrateConfig.GasAdjustment), "--dry-run")
+
+	// The gas estimate is written to stderr
+	o, err := execute(ctx, (*exec.Cmd).CombinedOutput, migrateConfig.Binary, txSend...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to simulate transaction")
+	}
+
+	match := gasEstimateRegexp.FindSubmatch(o)
+	if match == nil {
+		return nil, fmt.Errorf("no gas estimate in simulation output: %s", strings.TrimSpace(string(o)))
+	}
+	gasWanted, err := strconv.ParseUint(string(match[1]), 10, 64)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid gas estimate")
+	}
+
+	// The fee is the gas wanted times the gas price, rounded up
+	fee, accuracy := new(big.Float).Mul(new(big.Float).SetUint64(gasWanted), big.NewFloat(price)).Int(nil)
+	if accuracy == big.Below {
+		fee.Add(fee, big.NewInt(1))
+	}
+
+	return &Fee{Gas: gasWanted, Price: price, Amount: fee, Denom: migrateConfig.GasDenom}, nil
+}
+
+// gasPrice returns the gas price of the transactions.
+// When the gas price is queried from the chain, the configured gas price is the minimum gas price.
+func gasPrice(ctx context.Context, migrateConfig config.MigrateConfig) (float64, error) {
+	var price float64
+	var err error
+	switch migrateConfig.GasPriceSource {
+	case config.GasPriceMinimum:
+		price, err = minimumGasPrice(ctx, migrateConfig)
+	case config.GasPriceFeemarket:
+		price, err = feemarketGasPrice(ctx, migrateConfig)
+	default:
+		return migrateConfig.GasPrice, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return max(price, migrateConfig.GasPrice), nil
+}
+
+// minimumGasPrice returns the minimum gas price of the node in the gas denom, 0 if the node has none.
+func minimumGasPrice(ctx context.Context, migrateConfig config.MigrateConfig) (float64, error) {
+	qConfig := []string{"q", "node", "config",
+		"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	o, err := executeCommand(ctx, migrateConfig.Binary, qConfig...)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to query node minimum gas price")
+	}
+
+	var nodeConfig NodeConfig
+	if err = unmarshalOutput(o, &nodeConfig); err != nil {
+		return 0, err
+	}
+
+	// The minimum gas price is a list of decimal coins, e.g. `0.001000000000000000umfx,0.5uatom`
+	for _, coin := range strings.Split(nodeConfig.MinimumGasPrice, ",") {
+		coin = strings.TrimSpace(coin)
+		if value, ok := strings.CutSuffix(coin, migrateConfig.GasDenom); ok && value != "" {
+			return parseGasPrice(value)
+		}
+	}
+	return 0, nil
+}
+
+// feemarketGasPrice returns the gas price of the gas denom set by the fee market module.
+func feemarketGasPrice(ctx context.Context, migrateConfig config.MigrateConfig) (float64, error) {
+	qGasPrice := []string{"q", "feemarket", "gas-price", migrateConfig.GasDenom,
+		"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	o, err := executeCommand(ctx, migrateConfig.Binary, qGasPrice...)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to query fee market gas price")
+	}
+
+	var gasPrice FeemarketGasPrice
+	if err = unmarshalOutput(o, &gasPrice); err != nil {
+		return 0, err
+	}
+	return parseGasPrice(gasPrice.Price.Amount)
+}
+
+func parseGasPrice(value string) (float64, error) {
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		return 0, fmt.Errorf("invalid gas price: %s", value)
+	}
+	return price, nil
+}
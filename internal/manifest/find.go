@@ -0,0 +1,162 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// searchPageLimit is the number of transactions per page of a transaction search.
+const searchPageLimit = 100
+
+// IndexedTx is a transaction returned by a transaction search.
+type IndexedTx struct {
+	CosmosTx
+	Tx struct {
+		Body struct {
+			Memo string `json:"memo"`
+		} `json:"body"`
+	} `json:"tx"`
+}
+
+type SearchTxsResult struct {
+	Txs []IndexedTx `json:"txs"`
+}
+
+// FindTransfer looks for the transfer made by a previous migration attempt of the work item which stopped before
+// completing it, so that the tokens are never sent twice. It returns nil if the previous attempts sent no tokens.
+//
+// The transactions recorded in the work item audit by Migrate are looked up by hash, as they may not be indexed yet.
+// Every page of the successful transactions sending tokens to the address of the work item with the UUID of the work
+// item as memo is then searched. The transfer must be of the coin recorded with its broadcast.
+//
+// Errors are transient as no token is sent, except finding an unexpected transfer, or a recorded transaction that can
+// be neither found nor ruled out, which require an intervention.
+func FindTransfer(ctx context.Context, item *store.WorkItem, migrateConfig config.MigrateConfig) (*CosmosTx, *time.Time, error) {
+	var broadcasts []store.Broadcast
+	if item.Audit != nil {
+		broadcasts = item.Audit.Broadcasts
+	}
+
+	coins := map[string]string{} // Coin of the recorded transactions, by hash
+	var found []*CosmosTx
+	var unknown []string
+	for _, b := range broadcasts {
+		switch {
+		case b.Rejected:
+			continue
+		case b.Pending():
+			unknown = append(unknown, "transaction broadcast at "+b.Time.Format(time.RFC3339))
+			continue
+		}
+
+		coins[b.TxHash] = b.Coin
+		tx, err := getTx(ctx, migrateConfig, b.TxHash)
+		switch {
+		case err != nil:
+			return nil, nil, errclass.Wrap(errclass.Transient, err)
+		case tx == nil:
+			unknown = append(unknown, "transaction "+b.TxHash)
+		case tx.Code == 0:
+			found = append(found, tx)
+		}
+	}
+
+	txs, err := searchTransfers(ctx, item, migrateConfig)
+	if err != nil {
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
+	}
+	for _, tx := range txs {
+		if !slices.ContainsFunc(found, func(f *CosmosTx) bool { return f.TxHash == tx.TxHash }) {
+			found = append(found, tx)
+		}
+	}
+
+	switch {
+	case len(found) == 0 && len(unknown) > 0:
+		return nil, nil, errclass.Wrap(errclass.Intervention, fmt.Errorf("%s of work item %s neither found nor ruled out",
+			strings.Join(unknown, ", "), item.UUID))
+	case len(found) == 0:
+		return nil, nil, nil
+	case len(found) > 1:
+		return nil, nil, errclass.Wrap(errclass.Intervention, fmt.Errorf("%d transfers found for work item %s", len(found), item.UUID))
+	}
+
+	// A transfer found by the search only, e.g. the transaction of a pending broadcast, must be of the last recorded coin
+	tx := found[0]
+	coin, ok := coins[tx.TxHash]
+	if !ok && len(broadcasts) > 0 {
+		coin, ok = broadcasts[len(broadcasts)-1].Coin, true
+	}
+	if !ok {
+		return nil, nil, errclass.Wrap(errclass.Intervention, fmt.Errorf("unexpected transfer %s: no transaction recorded", tx.TxHash))
+	}
+	if err = VerifyTransfer(tx, item.ManifestAddress, coin); err != nil {
+		return nil, nil, errclass.Wrap(errclass.Intervention, errors.WithMessagef(err, "unexpected transfer %s", tx.TxHash))
+	}
+
+	blockTime, err := getBlockTime(ctx, migrateConfig, tx.Height)
+	if err != nil {
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
+	}
+
+	return tx, blockTime, nil
+}
+
+// getTx looks up the transaction by hash. It returns nil if the chain does not know the transaction, e.g. it was not
+// included in a block yet.
+func getTx(ctx context.Context, migrateConfig config.MigrateConfig, txHash string) (*CosmosTx, error) {
+	qTx := []string{"q", "tx", txHash, "--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	o, err := executeCommand(ctx, migrateConfig.Binary, qTx...)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, nil
+		}
+		return nil, errors.WithMessagef(err, "failed to look up transaction %s", txHash)
+	}
+
+	var tx CosmosTx
+	if err = unmarshalOutput(o, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// searchTransfers returns the successful transactions sending tokens to the address of the work item with the UUID of
+// the work item as memo, searching every page of results.
+func searchTransfers(ctx context.Context, item *store.WorkItem, migrateConfig config.MigrateConfig) ([]*CosmosTx, error) {
+	var found []*CosmosTx
+	for page := 1; ; page++ {
+		qTxs := []string{"q", "txs", "--query", fmt.Sprintf("transfer.recipient='%s'", item.ManifestAddress),
+			"--page", strconv.Itoa(page), "--limit", strconv.Itoa(searchPageLimit),
+			"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+		o, err := executeCommand(ctx, migrateConfig.Binary, qTxs...)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to search transactions")
+		}
+
+		var res SearchTxsResult
+		if err = unmarshalOutput(o, &res); err != nil {
+			return nil, err
+		}
+
+		for i := range res.Txs {
+			if res.Txs[i].Code == 0 && res.Txs[i].Tx.Body.Memo == item.UUID.String() {
+				found = append(found, &res.Txs[i].CosmosTx)
+			}
+		}
+
+		if len(res.Txs) < searchPageLimit {
+			return found, nil
+		}
+	}
+}
@@ -1,16 +1,26 @@
 package manifest
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"math/big"
 	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/failpoint"
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 
 	"github.com/manifest-network/mfx-migrator/internal/store"
 )
@@ -19,15 +29,32 @@ const (
 	OutputFormat = "json"
 )
 
+// commandWaitDelay is the time spent waiting for the output of a killed command to be closed.
+const commandWaitDelay = time.Second
+
 var (
-	gas = []string{"--gas", "auto"}
 	yes = []string{"--yes"}
 )
 
+// bankLocks serialize the transactions of every bank account, by bank address, from their broadcast to their inclusion
+// in a block. The sequence of the account is read from the last block, so that the work items of several neighborhoods
+// migrated at the same time would otherwise send transactions with the same sequence.
+var bankLocks sync.Map
+
+// lockBank locks the bank account and returns the function unlocking it.
+func lockBank(bankAddress string) func() {
+	lock, _ := bankLocks.LoadOrStore(bankAddress, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
 type CosmosTx struct {
-	TxHash string `json:"txhash"`
-	Code   int    `json:"code"`
-	RawLog string `json:"raw_log"`
+	TxHash string  `json:"txhash"`
+	Height string  `json:"height"` // Inclusion height, set once the transaction is included in a block
+	Code   int     `json:"code"`
+	RawLog string  `json:"raw_log"`
+	Events []Event `json:"events"` // Events of the transaction, set once the transaction is included in a block
 }
 
 type EventQueryTxFor struct {
@@ -40,16 +67,46 @@ type BlockHeader struct {
 	}
 }
 
+// subcommand returns the subcommand of the chain binary arguments, e.g. `tx bank send` or `q block`.
+func subcommand(arg []string) string {
+	n := 2
+	if len(arg) > 1 && (arg[0] == "tx" || (arg[0] == "q" && arg[1] == "bank")) {
+		n = 3
+	}
+	return strings.Join(arg[:min(n, len(arg))], " ")
+}
+
 // executeCommand executes the provided command and returns the output.
-func executeCommand(name string, arg ...string) ([]byte, error) {
-	cmd := exec.Command(name, arg...)
+func executeCommand(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	return execute(ctx, (*exec.Cmd).Output, name, arg...)
+}
+
+// execute executes the provided command and returns the output collected by the given function.
+// The command is killed when the context is done.
+func execute(ctx context.Context, collect func(*exec.Cmd) ([]byte, error), name string, arg ...string) (_ []byte, err error) {
+	_, span := tracing.Start(ctx, "executeCommand", trace.WithAttributes(
+		attribute.String("binary", name),
+		attribute.String("subcommand", subcommand(arg)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.WaitDelay = commandWaitDelay
 	slog.Debug("Executing command", "command", cmd.String())
-	output, err := cmd.Output()
+	start := time.Now()
+	output, err := collect(cmd)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.CommandDuration.WithLabelValues(subcommand(arg), outcome).Observe(time.Since(start).Seconds())
 	slog.Debug("Command output", "output", string(output))
 	if err != nil {
 		var exitErr *exec.ExitError
 		var resErr error
-		if errors.As(err, &exitErr) {
+		if ctx.Err() != nil {
+			resErr = errors.WithMessage(err, fmt.Sprintf("command interrupted: %s", ctx.Err()))
+		} else if errors.As(err, &exitErr) {
 			resErr = errors.WithMessage(err, fmt.Sprintf("failed to execute command: %s", string(exitErr.Stderr)))
 		} else {
 			resErr = errors.WithMessage(err, "failed to execute command")
@@ -67,43 +124,79 @@ func unmarshalOutput(output []byte, dest interface{}) error {
 	return nil
 }
 
-// Migrate migrates the given amount of tokens to the specified address.
-func Migrate(item *store.WorkItem, migrateConfig config.MigrateConfig, denom string, amount *big.Int) (*CosmosTx, *time.Time, error) {
+// sendArgs returns the arguments of the transaction sending the given amount of tokens from the bank account to the
+// specified address, without the gas and fee arguments.
+// The memo of the transaction is the UUID of the work item, so that the transfer can be found again.
+func sendArgs(from string, item *store.WorkItem, migrateConfig config.MigrateConfig, denom string, amount *big.Int) []string {
 	node := []string{"--node", migrateConfig.NodeAddress}
 	chainId := []string{"--chain-id", migrateConfig.ChainID}
 	keyringBackend := []string{"--keyring-backend", migrateConfig.KeyringBackend}
 	home := []string{"--home", migrateConfig.ChainHome}
-	from := []string{"--from", migrateConfig.BankAddress}
-	gasAdjustment := []string{"--gas-adjustment", fmt.Sprintf("%f", migrateConfig.GasAdjustment)}
-	gasPrice := []string{"--gas-prices", fmt.Sprintf("%f%s", migrateConfig.GasPrice, migrateConfig.GasDenom)}
 	feeGranter := []string{"--fee-granter", migrateConfig.FeeGranter}
 	output := []string{"--output", OutputFormat}
 
-	// Send the tokens to the manifest address
-	txSend := []string{"tx", "bank", "send", migrateConfig.BankAddress, item.ManifestAddress, amount.String() + denom}
+	txSend := []string{"tx", "bank", "send", from, item.ManifestAddress, amount.String() + denom}
 	txSend = append(txSend, node...)
 	txSend = append(txSend, chainId...)
 	txSend = append(txSend, keyringBackend...)
 	txSend = append(txSend, home...)
-	txSend = append(txSend, from...)
-	txSend = append(txSend, gas...)
-	txSend = append(txSend, gasAdjustment...)
-	txSend = append(txSend, gasPrice...)
+	txSend = append(txSend, "--from", from)
 	txSend = append(txSend, feeGranter...)
+	txSend = append(txSend, "--note", item.UUID.String())
 	txSend = append(txSend, output...)
 	txSend = append(txSend, yes...)
-	o, err := executeCommand(migrateConfig.Binary, txSend...)
+	return txSend
+}
+
+// Migrate migrates the given amount of tokens to the specified address, paying the fee estimated by Simulate.
+// The broadcast is recorded in the work item audit and saved with save right before the transaction is sent, then again
+// with the hash returned by the chain, before waiting for the transaction to be included. See FindTransfer.
+// The events of the included transaction must show the exact amount was transferred to the specified address.
+// Waiting for the transaction and fetching its block time out after the wait for tx and wait for block timeouts.
+// Errors are classified: a transaction rejected by the chain is transient as no token was sent, and so are the errors
+// once the hash is recorded, e.g. a timeout waiting for the transaction, as FindTransfer settles the outcome when the
+// migration is retried. The other errors, and a transaction with unexpected events, are intervention required.
+func Migrate(ctx context.Context, item *store.WorkItem, migrateConfig config.MigrateConfig, denom string, amount *big.Int, fee *Fee, save func() error) (*CosmosTx, *time.Time, error) {
+	node := []string{"--node", migrateConfig.NodeAddress}
+	home := []string{"--home", migrateConfig.ChainHome}
+	output := []string{"--output", OutputFormat}
+	coin := amount.String() + denom
+
+	unlock := lockBank(migrateConfig.BankAddress)
+	defer unlock()
+
+	// Nothing is sent unless the broadcast is recorded
+	store.RecordBroadcast(item, store.Broadcast{Coin: coin, Time: time.Now().UTC()})
+	if err := save(); err != nil {
+		return nil, nil, errclass.Wrap(errclass.Transient, errors.WithMessage(err, "failed to record broadcast"))
+	}
+
+	// Send the tokens to the manifest address
+	txSend := sendArgs(migrateConfig.BankAddress, item, migrateConfig, denom, amount)
+	txSend = append(txSend, "--gas", strconv.FormatUint(fee.Gas, 10), "--fees", fee.Amount.String()+fee.Denom)
+	o, err := executeCommand(ctx, migrateConfig.Binary, txSend...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, errclass.Wrap(errclass.Intervention, err)
 	}
 
 	// Unmarshal the transaction response
 	var tx CosmosTx
 	if err = unmarshalOutput(o, &tx); err != nil {
-		return nil, nil, err
+		return nil, nil, errclass.Wrap(errclass.Intervention, err)
+	}
+
+	// Record the hash, so that the transaction can be looked up even if it is not indexed yet
+	store.RecordBroadcast(item, store.Broadcast{TxHash: tx.TxHash, Coin: coin, Rejected: tx.Code != 0, Time: time.Now().UTC()})
+	if err = save(); err != nil {
+		return nil, nil, errclass.Wrap(errclass.Intervention, errors.WithMessagef(err, "failed to record broadcast of %s", tx.TxHash))
 	}
 	if tx.Code != 0 {
-		return nil, nil, errors.Errorf("failed to execute transaction: %s", tx.RawLog)
+		return nil, nil, errclass.Transientf("failed to execute transaction: %s", tx.RawLog)
+	}
+
+	// From now on, the transfer is looked up by hash by FindTransfer when the migration is retried
+	if err = failpoint.Inject(failpoint.AfterBroadcast); err != nil {
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
 	}
 
 	// Wait for the transaction to be included in a block
@@ -111,39 +204,105 @@ func Migrate(item *store.WorkItem, migrateConfig config.MigrateConfig, denom str
 	qWaitTx = append(qWaitTx, node...)
 	qWaitTx = append(qWaitTx, home...)
 	qWaitTx = append(qWaitTx, output...)
-	o, err = executeCommand(migrateConfig.Binary, qWaitTx...)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(migrateConfig.WaitTxTimeout)*time.Second)
+	defer cancel()
+	o, err = executeCommand(waitCtx, migrateConfig.Binary, qWaitTx...)
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "failed to wait for transaction")
+		return nil, nil, errclass.Wrap(errclass.Transient, errors.WithMessage(err, "failed to wait for transaction"))
 	}
 
 	var txWait CosmosTx
 	if err = unmarshalOutput(o, &txWait); err != nil {
-		return nil, nil, err
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
 	}
 	if txWait.Code != 0 {
-		return nil, nil, errors.Errorf("failed to execute transaction: %s", txWait.RawLog)
+		return nil, nil, errclass.Transientf("failed to execute transaction: %s", txWait.RawLog)
+	}
+
+	// The transaction succeeded, make sure it did what was intended
+	if err = VerifyTransfer(&txWait, item.ManifestAddress, coin); err != nil {
+		return nil, nil, errclass.Wrap(errclass.Intervention, errors.WithMessage(err, "unexpected transaction events"))
 	}
 
 	var res EventQueryTxFor
 	if err = unmarshalOutput(o, &res); err != nil {
-		return nil, nil, err
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
 	}
 
-	// Fetch the block header for the transaction to get the block time
-	qBlock := []string{"q", "block", "--type", "height", res.Height}
-	qBlock = append(qBlock, node...)
-	qBlock = append(qBlock, home...)
-	qBlock = append(qBlock, output...)
-	o, err = executeCommand(migrateConfig.Binary, qBlock...)
+	blockTime, err := getBlockTime(ctx, migrateConfig, res.Height)
+	if err != nil {
+		return nil, nil, errclass.Wrap(errclass.Transient, err)
+	}
+
+	tx.Height = res.Height
+	return &tx, blockTime, nil
+}
+
+// getBlockTime fetches the header of the block at the given height and returns its time.
+// It times out after the wait for block timeout.
+func getBlockTime(ctx context.Context, migrateConfig config.MigrateConfig, height string) (*time.Time, error) {
+	qBlock := []string{"q", "block", "--type", "height", height,
+		"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(migrateConfig.WaitBlockTimeout)*time.Second)
+	defer cancel()
+	o, err := executeCommand(ctx, migrateConfig.Binary, qBlock...)
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "failed to fetch block")
+		return nil, errors.WithMessage(err, "failed to fetch block")
 	}
 
 	var block BlockHeader
 	if err = unmarshalOutput(o, &block); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	blockTime := block.Header.Time.UTC().Truncate(time.Millisecond)
-	return &tx, &blockTime, nil
+	return &blockTime, nil
+}
+
+type BankBalance struct {
+	Balance struct {
+		Denom  string `json:"denom"`
+		Amount string `json:"amount"`
+	} `json:"balance"`
+}
+
+// bankAddress returns the address of the bank account, resolving the key name from the keyring if needed.
+func bankAddress(ctx context.Context, migrateConfig config.MigrateConfig) (string, error) {
+	if strings.HasPrefix(migrateConfig.BankAddress, migrateConfig.AddressPrefix+"1") {
+		return migrateConfig.BankAddress, nil
+	}
+
+	keysShow := []string{"keys", "show", migrateConfig.BankAddress, "--address",
+		"--keyring-backend", migrateConfig.KeyringBackend, "--home", migrateConfig.ChainHome}
+	o, err := executeCommand(ctx, migrateConfig.Binary, keysShow...)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to resolve bank address")
+	}
+	return strings.TrimSpace(string(o)), nil
+}
+
+// GetBankBalance returns the balance of the bank account in the given denom.
+func GetBankBalance(ctx context.Context, migrateConfig config.MigrateConfig, denom string) (*big.Int, error) {
+	address, err := bankAddress(ctx, migrateConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	qBalance := []string{"q", "bank", "balance", address, denom,
+		"--node", migrateConfig.NodeAddress, "--home", migrateConfig.ChainHome, "--output", OutputFormat}
+	o, err := executeCommand(ctx, migrateConfig.Binary, qBalance...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to query bank balance")
+	}
+
+	var balance BankBalance
+	if err = unmarshalOutput(o, &balance); err != nil {
+		return nil, err
+	}
+
+	amount, ok := new(big.Int).SetString(balance.Balance.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid bank balance: %s", balance.Balance.Amount)
+	}
+	return amount, nil
 }
@@ -0,0 +1,355 @@
+package manifest_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/config"
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/manifest"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
+	"github.com/manifest-network/mfx-migrator/testutils/fakechain"
+)
+
+const txHash = "8F5E3B9A0C1D2E3F"
+
+var itemUUID = uuid.MustParse("5aa19d2a-4bdf-4687-a850-1804756b3f1f")
+
+func migrateConfig(binary string) config.MigrateConfig {
+	return config.MigrateConfig{
+		ChainID:          "manifest-1",
+		NodeAddress:      "http://localhost:26657",
+		KeyringBackend:   "test",
+		BankAddress:      "bank",
+		ChainHome:        "/tmp",
+		WaitTxTimeout:    1,
+		WaitBlockTimeout: 1,
+		Binary:           binary,
+		FeeGranter:       "feegranter",
+	}
+}
+
+// included is the output of `q event-query-tx-for` for a transaction included at the given height with the given
+// code, sending the coin to the recipient.
+func included(code int, to string, coin string) string {
+	return fmt.Sprintf(`{"txhash":"%s","height":"42","code":%d,"raw_log":"out of gas","events":[`+
+		`{"type":"coin_received","attributes":[{"key":"receiver","value":"%s"},{"key":"amount","value":"%s"}]},`+
+		`{"type":"transfer","attributes":[{"key":"recipient","value":"%s"},{"key":"amount","value":"%s"}]}]}`,
+		txHash, code, to, coin, to, coin)
+}
+
+func TestMigrate(t *testing.T) {
+	send := fakechain.Command{Pattern: "'tx bank send '*", Response: fakechain.Response{Stdout: `{"txhash":"` + txHash + `","code":0}`}}
+	wait := fakechain.Command{Pattern: "'q event-query-tx-for " + txHash + " '*", Response: fakechain.Response{Stdout: included(0, recipient, "123umfx")}}
+	block := fakechain.Command{Pattern: "'q block --type height 42 '*", Response: fakechain.Response{Stdout: `{"header":{"time":"2024-06-01T12:00:00.123456Z"}}`}}
+
+	// The broadcasts recorded by a migration: pending, rejected or accepted by the chain
+	pending := []store.Broadcast{{Coin: "123umfx"}}
+	rejected := []store.Broadcast{{TxHash: txHash, Coin: "123umfx", Rejected: true}}
+	accepted := []store.Broadcast{{TxHash: txHash, Coin: "123umfx"}}
+
+	tt := []struct {
+		name       string
+		commands   []fakechain.Command
+		saveErr    int // Number of the failing save, if any
+		broadcasts []store.Broadcast
+		class      errclass.Class
+		err        string
+	}{
+		{name: "migrated", commands: []fakechain.Command{send, wait, block}, broadcasts: accepted},
+		{name: "broadcast not recorded", saveErr: 1, broadcasts: pending, class: errclass.Transient, err: "failed to record broadcast"},
+		{name: "broadcast failure", commands: []fakechain.Command{
+			{Pattern: send.Pattern, Response: fakechain.Response{Stderr: "connection refused", Exit: 1}},
+		}, broadcasts: pending, class: errclass.Intervention, err: "connection refused"},
+		{name: "broadcast malformed output", commands: []fakechain.Command{
+			{Pattern: send.Pattern, Response: fakechain.Response{Stdout: `{"txhash":`}},
+		}, broadcasts: pending, class: errclass.Intervention, err: "failed to unmarshal output"},
+		{name: "broadcast rejected", commands: []fakechain.Command{
+			{Pattern: send.Pattern, Response: fakechain.Response{Stdout: `{"txhash":"` + txHash + `","code":5,"raw_log":"insufficient funds"}`}},
+		}, broadcasts: rejected, class: errclass.Transient, err: "insufficient funds"},
+		{name: "hash not recorded", commands: []fakechain.Command{send}, saveErr: 2, broadcasts: accepted,
+			class: errclass.Intervention, err: "failed to record broadcast of " + txHash},
+		{name: "wait failure", commands: []fakechain.Command{send,
+			{Pattern: wait.Pattern, Response: fakechain.Response{Stderr: "tx not found", Exit: 1}},
+		}, class: errclass.Transient, err: "tx not found"},
+		{name: "wait timeout", commands: []fakechain.Command{send,
+			{Pattern: wait.Pattern, Response: fakechain.Response{Sleep: 10}},
+		}, class: errclass.Transient, err: "context deadline exceeded"},
+		{name: "wait malformed output", commands: []fakechain.Command{send,
+			{Pattern: wait.Pattern, Response: fakechain.Response{Stdout: "not json"}},
+		}, class: errclass.Transient, err: "failed to unmarshal output"},
+		{name: "execution failure", commands: []fakechain.Command{send,
+			{Pattern: wait.Pattern, Response: fakechain.Response{Stdout: included(11, recipient, "123umfx")}},
+		}, class: errclass.Transient, err: "out of gas"},
+		{name: "unexpected recipient", commands: []fakechain.Command{send,
+			{Pattern: wait.Pattern, Response: fakechain.Response{Stdout: included(0, bank, "123umfx")}},
+		}, class: errclass.Intervention, err: "no transfer event to " + recipient},
+		{name: "unexpected amount", commands: []fakechain.Command{send,
+			{Pattern: wait.Pattern, Response: fakechain.Response{Stdout: included(0, recipient, "1230umfx")}},
+		}, class: errclass.Intervention, err: "has amount 1230umfx, expected 123umfx"},
+		{name: "block failure", commands: []fakechain.Command{send, wait,
+			{Pattern: block.Pattern, Response: fakechain.Response{Stderr: "height 42 is not available", Exit: 1}},
+		}, class: errclass.Transient, err: "height 42 is not available"},
+		{name: "block timeout", commands: []fakechain.Command{send, wait,
+			{Pattern: block.Pattern, Response: fakechain.Response{Sleep: 10}},
+		}, class: errclass.Transient, err: "context deadline exceeded"},
+		{name: "block malformed output", commands: []fakechain.Command{send, wait,
+			{Pattern: block.Pattern, Response: fakechain.Response{Stdout: `{"header":{"time":"yesterday"}}`}},
+		}, class: errclass.Transient, err: "failed to unmarshal output"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			binary := fakechain.New(t, tc.commands...)
+			item := &store.WorkItem{UUID: itemUUID, ManifestAddress: recipient}
+			fee := &manifest.Fee{Gas: 81234, Amount: big.NewInt(90), Denom: "umfx"}
+
+			saves := 0
+			save := func() error {
+				saves++
+				if saves == tc.saveErr {
+					return errors.New("disk full")
+				}
+				return nil
+			}
+
+			start := time.Now()
+			tx, blockTime, err := manifest.Migrate(context.Background(), item, migrateConfig(binary.Path), "umfx", big.NewInt(123), fee, save)
+			require.Less(t, time.Since(start), 5*time.Second)
+
+			// The broadcast is recorded before sending the transaction, then with its hash before waiting for it
+			broadcasts := tc.broadcasts
+			if broadcasts == nil {
+				broadcasts = accepted
+			}
+			for i := range item.Audit.Broadcasts {
+				require.False(t, item.Audit.Broadcasts[i].Time.IsZero())
+				item.Audit.Broadcasts[i].Time = time.Time{}
+			}
+			require.Equal(t, broadcasts, item.Audit.Broadcasts)
+			if tc.saveErr == 1 {
+				require.Empty(t, binary.Calls(t), "nothing is sent unless the broadcast is recorded")
+			}
+
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				require.Equal(t, tc.class, errclass.Of(err))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, txHash, tx.TxHash)
+			require.Equal(t, "42", tx.Height)
+			require.Equal(t, time.Date(2024, 6, 1, 12, 0, 0, 123000000, time.UTC), *blockTime)
+
+			calls := binary.Calls(t)
+			require.Len(t, calls, 3)
+			require.Equal(t, "tx bank send bank "+recipient+" 123umfx --node http://localhost:26657 --chain-id manifest-1 "+
+				"--keyring-backend test --home /tmp --from bank --fee-granter feegranter --note "+itemUUID.String()+" --output json --yes --gas 81234 --fees 90umfx", calls[0])
+		})
+	}
+}
+
+// indexed is a transaction returned by `q txs`, with the given code and memo, sending the coin to the recipient.
+func indexed(hash string, code int, memo string, coin string) string {
+	return fmt.Sprintf(`{"txhash":"%s","height":"42","code":%d,"tx":{"body":{"memo":"%s"}},"events":[`+
+		`{"type":"coin_received","attributes":[{"key":"receiver","value":"%s"},{"key":"amount","value":"%s"}]},`+
+		`{"type":"transfer","attributes":[{"key":"recipient","value":"%s"},{"key":"amount","value":"%s"}]}]}`,
+		hash, code, memo, recipient, coin, recipient, coin)
+}
+
+func TestFindTransfer(t *testing.T) {
+	block := fakechain.Command{Pattern: "'q block --type height 42 '*", Response: fakechain.Response{Stdout: `{"header":{"time":"2024-06-01T12:00:00.123Z"}}`}}
+	searchPage := func(page int, txs ...string) fakechain.Command {
+		return fakechain.Command{Pattern: fmt.Sprintf(`"q txs --query transfer.recipient='%s' --page %d --limit 100 "*`, recipient, page), Response: fakechain.Response{
+			Stdout: `{"total_count":"1","txs":[` + strings.Join(txs, ",") + `]}`,
+		}}
+	}
+	search := func(txs ...string) fakechain.Command { return searchPage(1, txs...) }
+	lookup := func(response fakechain.Response) fakechain.Command {
+		return fakechain.Command{Pattern: "'q tx " + txHash + " '*", Response: response}
+	}
+	transfer := indexed(txHash, 0, itemUUID.String(), "123umfx")
+	found := lookup(fakechain.Response{Stdout: transfer})
+	notFound := lookup(fakechain.Response{Stderr: "tx (" + txHash + ") not found", Exit: 1})
+	other := indexed("0A0B0C", 0, "2ab6f7ad-b3a5-4f2d-9fae-13d4ed6c4b4e", "1000umfx")
+
+	// A full page of transfers of other work items
+	var others []string
+	for range 100 {
+		others = append(others, other)
+	}
+
+	// The broadcasts recorded by a previous migration: pending, rejected or accepted by the chain
+	pending := []store.Broadcast{{Coin: "123umfx", Time: time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC)}}
+	rejected := []store.Broadcast{{TxHash: txHash, Coin: "123umfx", Rejected: true}}
+	accepted := []store.Broadcast{{TxHash: txHash, Coin: "123umfx"}}
+
+	tt := []struct {
+		name       string
+		broadcasts []store.Broadcast
+		commands   []fakechain.Command
+		hash       string
+		class      errclass.Class
+		err        string
+	}{
+		{name: "no transfer", commands: []fakechain.Command{search()}},
+		{name: "transfers of other work items", commands: []fakechain.Command{search(other)}},
+		{name: "rejected transaction", broadcasts: rejected, commands: []fakechain.Command{search()}},
+		{name: "failed transfer", broadcasts: accepted, commands: []fakechain.Command{
+			lookup(fakechain.Response{Stdout: indexed(txHash, 5, itemUUID.String(), "123umfx")}),
+			search(other, indexed(txHash, 5, itemUUID.String(), "123umfx")),
+		}},
+		{name: "transfer", broadcasts: accepted, commands: []fakechain.Command{found, search(other, transfer), block}, hash: txHash},
+		{name: "transfer not indexed yet", broadcasts: accepted, commands: []fakechain.Command{found, search(), block}, hash: txHash},
+		{name: "transfer of a pending broadcast", broadcasts: pending, commands: []fakechain.Command{search(transfer), block}, hash: txHash},
+		{name: "transfer on a later page", broadcasts: pending, commands: []fakechain.Command{searchPage(1, others...), searchPage(2, transfer), block}, hash: txHash},
+		{name: "transaction not found", broadcasts: accepted, commands: []fakechain.Command{notFound, search()},
+			class: errclass.Intervention, err: "transaction " + txHash + " of work item " + itemUUID.String() + " neither found nor ruled out"},
+		{name: "pending broadcast not found", broadcasts: pending, commands: []fakechain.Command{search(other)},
+			class: errclass.Intervention, err: "transaction broadcast at 2024-06-01T11:00:00Z of work item " + itemUUID.String() + " neither found nor ruled out"},
+		{name: "several transfers", broadcasts: accepted, commands: []fakechain.Command{found, search(transfer, indexed("0D0E0F", 0, itemUUID.String(), "123umfx"))},
+			class: errclass.Intervention, err: "2 transfers found for work item " + itemUUID.String()},
+		{name: "unexpected amount", broadcasts: accepted, commands: []fakechain.Command{lookup(fakechain.Response{Stdout: indexed(txHash, 0, itemUUID.String(), "12umfx")}), search()},
+			class: errclass.Intervention, err: "unexpected transfer " + txHash},
+		{name: "unrecorded transfer", commands: []fakechain.Command{search(transfer)},
+			class: errclass.Intervention, err: "unexpected transfer " + txHash + ": no transaction recorded"},
+		{name: "lookup failure", broadcasts: accepted, commands: []fakechain.Command{lookup(fakechain.Response{Stderr: "connection refused", Exit: 1})},
+			class: errclass.Transient, err: "failed to look up transaction " + txHash},
+		{name: "search failure", commands: []fakechain.Command{{Pattern: "'q txs '*", Response: fakechain.Response{Stderr: "tx indexing is disabled", Exit: 1}}},
+			class: errclass.Transient, err: "tx indexing is disabled"},
+		{name: "block failure", broadcasts: accepted, commands: []fakechain.Command{found, search()},
+			class: errclass.Transient, err: "failed to fetch block"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			binary := fakechain.New(t, tc.commands...)
+			item := &store.WorkItem{UUID: itemUUID, ManifestAddress: recipient, Audit: &store.Audit{Broadcasts: tc.broadcasts}}
+
+			tx, blockTime, err := manifest.FindTransfer(context.Background(), item, migrateConfig(binary.Path))
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				require.Equal(t, tc.class, errclass.Of(err))
+				return
+			}
+
+			require.NoError(t, err)
+			if tc.hash == "" {
+				require.Nil(t, tx)
+				return
+			}
+			require.Equal(t, tc.hash, tx.TxHash)
+			require.Equal(t, "42", tx.Height)
+			require.Equal(t, time.Date(2024, 6, 1, 12, 0, 0, 123000000, time.UTC), *blockTime)
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	status := func(height int) fakechain.Command {
+		return fakechain.Command{Pattern: "'status '*", Response: fakechain.Response{Stdout: fmt.Sprintf(`{"sync_info":{"latest_block_height":"%d"}}`, height)}}
+	}
+	lookup := func(response fakechain.Response) fakechain.Command {
+		return fakechain.Command{Pattern: "'q tx " + txHash + " '*", Response: response}
+	}
+	committed := lookup(fakechain.Response{Stdout: indexed(txHash, 0, itemUUID.String(), "123umfx")})
+
+	tt := []struct {
+		name     string
+		commands []fakechain.Command
+		class    errclass.Class
+		err      string
+	}{
+		{name: "confirmed", commands: []fakechain.Command{status(43), committed}},
+		{name: "status failure", commands: []fakechain.Command{{Pattern: "'status '*", Response: fakechain.Response{Stderr: "connection refused", Exit: 1}}},
+			class: errclass.Transient, err: "failed to query node status"},
+		{name: "timeout", commands: []fakechain.Command{status(42)},
+			class: errclass.Transient, err: "timed out waiting for block 43, latest block is 42"},
+		{name: "lookup failure", commands: []fakechain.Command{status(43), lookup(fakechain.Response{Stderr: "connection refused", Exit: 1})},
+			class: errclass.Transient, err: "failed to query transaction"},
+		{name: "moved", commands: []fakechain.Command{status(43), lookup(fakechain.Response{Stdout: `{"txhash":"` + txHash + `","height":"43","code":0}`})},
+			class: errclass.Intervention, err: "transaction " + txHash + " moved from block 42 to block 43"},
+		{name: "failed", commands: []fakechain.Command{status(43), lookup(fakechain.Response{Stdout: indexed(txHash, 5, itemUUID.String(), "123umfx")})},
+			class: errclass.Intervention, err: "transaction " + txHash + " failed"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			binary := fakechain.New(t, tc.commands...)
+			cfg := migrateConfig(binary.Path)
+			cfg.ConfirmationDepth = 1
+
+			confirmation, err := manifest.Confirm(context.Background(), cfg, &manifest.CosmosTx{TxHash: txHash, Height: "42"})
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				require.Equal(t, tc.class, errclass.Of(err))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, &store.Confirmation{Depth: 1, Height: 42, ConfirmedHeight: 43}, confirmation)
+		})
+	}
+}
+
+func TestIsSendEnabled(t *testing.T) {
+	sendEnabled := func(stdout string) fakechain.Command {
+		return fakechain.Command{Pattern: "'q bank send-enabled umfx '*", Response: fakechain.Response{Stdout: stdout}}
+	}
+	params := func(stdout string) fakechain.Command {
+		return fakechain.Command{Pattern: "'q bank params '*", Response: fakechain.Response{Stdout: stdout}}
+	}
+
+	tt := []struct {
+		name     string
+		commands []fakechain.Command
+		enabled  bool
+		err      string
+	}{
+		{name: "enabled", commands: []fakechain.Command{sendEnabled(`{"send_enabled":[{"denom":"umfx","enabled":true}]}`)}, enabled: true},
+		{name: "disabled", commands: []fakechain.Command{sendEnabled(`{"send_enabled":[{"denom":"umfx","enabled":false}]}`)}},
+		{name: "default enabled", commands: []fakechain.Command{
+			sendEnabled(`{"send_enabled":[{"denom":"uother","enabled":false}]}`),
+			params(`{"params":{"default_send_enabled":true}}`),
+		}, enabled: true},
+		{name: "default disabled", commands: []fakechain.Command{
+			sendEnabled(`{"send_enabled":[]}`),
+			params(`{"params":{"default_send_enabled":false}}`),
+		}},
+		{name: "query failure", commands: []fakechain.Command{
+			{Pattern: "'q bank send-enabled '*", Response: fakechain.Response{Stderr: "connection refused", Exit: 1}},
+		}, err: "connection refused"},
+		{name: "malformed output", commands: []fakechain.Command{sendEnabled("not json")}, err: "failed to unmarshal output"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			binary := fakechain.New(t, tc.commands...)
+			enabled, err := manifest.IsSendEnabled(context.Background(), migrateConfig(binary.Path), "umfx")
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.enabled, enabled)
+		})
+	}
+}
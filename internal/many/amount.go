@@ -0,0 +1,34 @@
+package many
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ConversionFactor is the number of MANY token units converted to one Manifest token unit.
+// The MANY chain supports 9 decimal places, the Manifest chain supports 6 decimal places and 1 MFX on the MANY chain
+// is 10 MFX on the Manifest chain.
+const ConversionFactor = 100
+
+// ParseAmount parses a MANY amount, a decimal integer without sign nor leading zeros.
+func ParseAmount(s string) (*big.Int, error) {
+	if s == "" || (s[0] == '0' && len(s) > 1) {
+		return nil, fmt.Errorf("invalid amount: %q", s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid amount: %q", s)
+		}
+	}
+
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %q", s)
+	}
+	return amount, nil
+}
+
+// ConvertAmount converts a MANY amount to a Manifest amount. The remainder is the dust lost in the conversion.
+func ConvertAmount(amount *big.Int) (converted *big.Int, remainder *big.Int) {
+	return new(big.Int).QuoRem(amount, big.NewInt(ConversionFactor), new(big.Int))
+}
@@ -0,0 +1,104 @@
+package many_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/many"
+)
+
+func TestParseAmount(t *testing.T) {
+	tt := []struct {
+		amount string
+		valid  bool
+	}{
+		{amount: "0", valid: true},
+		{amount: "100", valid: true},
+		{amount: "123456789012345678901234567890", valid: true},
+		{amount: ""},
+		{amount: "00"},
+		{amount: "0100"},
+		{amount: "+100"},
+		{amount: "-100"},
+		{amount: " 100"},
+		{amount: "100 "},
+		{amount: "1_000"},
+		{amount: "0x64"},
+		{amount: "1e3"},
+		{amount: "10.0"},
+		{amount: "١٠٠"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.amount, func(t *testing.T) {
+			amount, err := many.ParseAmount(tc.amount)
+			if !tc.valid {
+				require.ErrorContains(t, err, "invalid amount")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.amount, amount.String())
+		})
+	}
+}
+
+func TestConvertAmount(t *testing.T) {
+	tt := []struct {
+		amount    int64
+		converted int64
+		remainder int64
+	}{
+		{amount: 0, converted: 0, remainder: 0},
+		{amount: 99, converted: 0, remainder: 99},
+		{amount: 100, converted: 1, remainder: 0},
+		{amount: 12345, converted: 123, remainder: 45},
+	}
+
+	for _, tc := range tt {
+		converted, remainder := many.ConvertAmount(big.NewInt(tc.amount))
+		require.Equal(t, big.NewInt(tc.converted).String(), converted.String())
+		require.Equal(t, big.NewInt(tc.remainder).String(), remainder.String())
+	}
+}
+
+func FuzzParseAmount(f *testing.F) {
+	for _, seed := range []string{"0", "99", "100", "12345", "00", "0100", "+100", "-100", "1_000", "0x64", " 1"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		amount, err := many.ParseAmount(s)
+		if err != nil {
+			return
+		}
+
+		// Only canonical decimal amounts are accepted: no sign, no leading zero, no separator
+		require.Equal(t, s, amount.String())
+		require.GreaterOrEqual(t, amount.Sign(), 0)
+	})
+}
+
+func FuzzConvertAmount(f *testing.F) {
+	for _, seed := range []string{"0", "1", "99", "100", "101", "12345", "123456789012345678901234567890"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		amount, err := many.ParseAmount(s)
+		if err != nil {
+			t.Skip()
+		}
+
+		converted, remainder := many.ConvertAmount(amount)
+		require.GreaterOrEqual(t, converted.Sign(), 0, "negative output for %s", s)
+		require.GreaterOrEqual(t, remainder.Sign(), 0, "negative remainder for %s", s)
+		require.Negative(t, remainder.Cmp(big.NewInt(many.ConversionFactor)), "remainder %s too large for %s", remainder, s)
+
+		// converted * factor + remainder == amount
+		total := new(big.Int).Mul(converted, big.NewInt(many.ConversionFactor))
+		total.Add(total, remainder)
+		require.Zero(t, total.Cmp(amount), "%s * %d + %s != %s", converted, many.ConversionFactor, remainder, amount)
+	})
+}
@@ -1,13 +1,20 @@
 package many
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"net/http"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 )
 
 type Arguments struct {
@@ -31,11 +38,18 @@ type TxInfo struct {
 	Arguments json.RawMessage `json:"argument"`
 }
 
-func GetTxInfo(r *resty.Client, hash string) (*Arguments, error) {
-	req := r.R().SetPathParam("thash", hash).SetResult(&TxInfo{})
+func GetTxInfo(ctx context.Context, r *resty.Client, hash string) (_ *Arguments, err error) {
+	ctx, span := tracing.Start(ctx, "GetTxInfo", trace.WithAttributes(attribute.String("hash", hash)))
+	defer func() { tracing.End(span, err) }()
+
+	req := r.R().SetContext(ctx).SetPathParam("thash", hash).SetResult(&TxInfo{})
 	resp, err := req.Get("neighborhoods/{neighborhood}/transactions/{thash}")
 	if err != nil {
-		return nil, errors.WithMessage(err, "error unmarshalling MANY tx info")
+		return nil, errors.WithMessage(errclass.Request(err), "error unmarshalling MANY tx info")
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errclass.StatusCode(resp.StatusCode())
 	}
 
 	txInfo := resp.Result().(*TxInfo)
@@ -88,17 +102,13 @@ func CheckTxInfo(txArgs *Arguments, itemUUID uuid.UUID, manifestAddr string) err
 		return fmt.Errorf("MANY tx UUID does not match work item UUID: %s, %s", txUUID, itemUUID)
 	}
 
-	amount := new(big.Int)
-	bigAmount, ok := amount.SetString(txArgs.Amount, 10)
-	if !ok {
-		return fmt.Errorf("invalid MANY tx amount: %s", txArgs.Amount)
+	amount, err := ParseAmount(txArgs.Amount)
+	if err != nil {
+		return errors.WithMessage(err, "invalid MANY tx amount")
 	}
 
-	// The MANY chain supports 9 decimal places
-	// The MANIFEST chain support 6 decimal places
-	// We're doing a 1:10 conversion
 	// Check the amount is not dust that would be lost in the conversion
-	if bigAmount.Cmp(big.NewInt(100)) < 0 {
+	if amount.Cmp(big.NewInt(ConversionFactor)) < 0 {
 		return fmt.Errorf("amount must be greater than 0.000000099: %s", txArgs.Amount)
 	}
 
@@ -0,0 +1,112 @@
+package many_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/many"
+)
+
+const (
+	from         = "maffbahksdwaqeenayy2gxke32hgb7aq4ao4wt745lsfs6wijp"
+	manifestAddr = "manifest1jjzy5en2000728mzs3wn86a6u6jpygzajj2fg2"
+)
+
+var itemUUID = uuid.MustParse("5aa19d2a-4bdf-4687-a850-1804756b3f1f")
+
+func FuzzGetTxInfo(f *testing.F) {
+	ledgerSend := fmt.Sprintf(`{"from":"%s","to":"%s","amount":"100","symbol":"dummy","memo":["%s","%s"]}`,
+		from, many.IllegalAddr, itemUUID, manifestAddr)
+	f.Add("ledger.send", ledgerSend)
+	f.Add("account.multisigSubmitTransaction", `{"transaction":{"argument":`+ledgerSend+`}}`)
+	f.Add("ledger.send", `{"amount":100,"memo":"not a list"}`)
+	f.Add("ledger.send", `null`)
+	f.Add("account.multisigSubmitTransaction", `{"transaction":null}`)
+	f.Add("ledger.burn", `{}`)
+	f.Add("ledger.send", `{"memo":[`)
+
+	f.Fuzz(func(t *testing.T, method string, arguments string) {
+		body, err := json.Marshal(map[string]any{"method": method, "argument": json.RawMessage(arguments)})
+		if err != nil {
+			// Not a valid JSON document, the server cannot send it
+			t.Skip()
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}))
+		defer server.Close()
+
+		r := resty.New().SetBaseURL(server.URL).SetPathParam("neighborhood", "0")
+		args, err := many.GetTxInfo(context.Background(), r, "d1e60bf3")
+		if err != nil {
+			require.Nil(t, args)
+			return
+		}
+		require.NotNil(t, args)
+
+		// The arguments are those of the transaction, whatever the method
+		var expected many.Arguments
+		switch method {
+		case "ledger.send":
+			require.NoError(t, json.Unmarshal([]byte(arguments), &expected))
+		case "account.multisigSubmitTransaction":
+			var multisig many.MultisigSubmitTransactionArguments
+			require.NoError(t, json.Unmarshal([]byte(arguments), &multisig))
+			expected = multisig.Transaction.Arguments
+		default:
+			t.Fatalf("unsupported method %q accepted", method)
+		}
+		require.Equal(t, expected, *args)
+	})
+}
+
+func FuzzCheckTxInfo(f *testing.F) {
+	f.Add(many.IllegalAddr, "100", itemUUID.String(), manifestAddr, 2)
+	f.Add(many.IllegalAddr, "99", itemUUID.String(), manifestAddr, 2)
+	f.Add(many.IllegalAddr, "0100", itemUUID.String(), manifestAddr, 2)
+	f.Add(many.IllegalAddr, "+100", itemUUID.String(), manifestAddr, 2)
+	f.Add(many.IllegalAddr, "100", strings.ToUpper(itemUUID.String()), manifestAddr, 2)
+	f.Add(many.IllegalAddr, "100", "urn:uuid:"+itemUUID.String(), manifestAddr, 2)
+	f.Add(many.IllegalAddr, "100", itemUUID.String(), manifestAddr, 3)
+	f.Add(from, "100", itemUUID.String(), manifestAddr, 2)
+
+	f.Fuzz(func(t *testing.T, to string, amount string, memoUUID string, memoAddr string, memoLen int) {
+		memo := []string{memoUUID, memoAddr}
+		switch {
+		case memoLen <= 0:
+			memo = nil
+		case memoLen == 1:
+			memo = memo[:1]
+		case memoLen > 2:
+			memo = append(memo, "extra")
+		}
+
+		err := many.CheckTxInfo(&many.Arguments{From: from, To: to, Amount: amount, Symbol: "dummy", Memo: memo}, itemUUID, manifestAddr)
+		if err != nil {
+			return
+		}
+
+		// Only a transaction to the illegal address, for the work item, of a canonical amount which is not dust, is valid
+		require.Equal(t, many.IllegalAddr, to)
+		require.Len(t, memo, 2)
+		require.Equal(t, itemUUID, uuid.MustParse(memoUUID))
+		require.Equal(t, manifestAddr, memoAddr)
+
+		parsed, ok := new(big.Int).SetString(amount, 10)
+		require.True(t, ok)
+		require.Equal(t, amount, parsed.String())
+		require.GreaterOrEqual(t, parsed.Cmp(big.NewInt(many.ConversionFactor)), 0)
+	})
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "mfx_migrator"
+
+	// Path is the path of the metrics endpoint.
+	Path = "/metrics"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// Registry holds the migrator metrics, along with the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	Claims = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "claims_total",
+		Help:      "Number of work items claimed.",
+	})
+
+	Completions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "completions_total",
+		Help:      "Number of work items migrated successfully.",
+	})
+
+	Failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failures_total",
+		Help:      "Number of work items marked as failed, by error class.",
+	}, []string{"class"})
+
+	MigratedAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "migrated_amount_total",
+		Help:      "Amount of tokens sent on the Manifest Ledger, in base units, by denom.",
+	}, []string{"denom"})
+
+	TalibRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "talib_request_duration_seconds",
+		Help:      "Duration of the remote database requests, by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	CommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "command_duration_seconds",
+		Help:      "Duration of the chain binary commands, by subcommand and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10), // 0.1s to 51.2s
+	}, []string{"subcommand", "outcome"})
+
+	StatusDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "status_duration_seconds",
+		Help:      "Time spent by the work items in a status before moving to the next one, by status.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10), // 1s to ~3 days
+	}, []string{"status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Claims,
+		Completions,
+		Failures,
+		MigratedAmount,
+		TalibRequestDuration,
+		CommandDuration,
+		StatusDuration,
+	)
+}
+
+// Handler returns the HTTP handler exposing the metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes the metrics on the given address until the context is done.
+// It returns once the address is bound, serving the metrics in the background.
+func Serve(ctx context.Context, addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not start metrics listener")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		slog.Info("Serving metrics", "address", listener.Addr().String(), "path", Path)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics listener stopped", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Could not stop metrics listener", "error", err)
+		}
+	}()
+
+	return listener.Addr(), nil
+}
@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+)
+
+func TestInstrumentClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := metrics.InstrumentClient(resty.New().SetBaseURL(server.URL))
+
+	_, err := client.R().SetPathParam("uuid", "5aa19d2a-4bdf-4687-a850-1804756b3f1f").Get("migrations/{uuid}")
+	require.NoError(t, err)
+	_, err = client.R().SetPathParam("uuid", "missing").Get("migrations/{uuid}")
+	require.NoError(t, err)
+	_, err = client.R().Get("http://127.0.0.1:0/unreachable")
+	require.Error(t, err)
+
+	require.Equal(t, uint64(1), requestCount(t, "GET", "migrations/{uuid}", "200"))
+	require.Equal(t, uint64(1), requestCount(t, "GET", "migrations/{uuid}", "404"))
+	require.Equal(t, uint64(1), requestCount(t, "GET", "http://127.0.0.1:0/unreachable", "error"))
+}
+
+// requestCount returns the number of talib requests observed with the given labels.
+func requestCount(t *testing.T, method, route, code string) uint64 {
+	t.Helper()
+
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+
+	labels := map[string]string{"method": method, "route": route, "code": code}
+	for _, family := range families {
+		if family.GetName() != "mfx_migrator_talib_request_duration_seconds" {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr, err := metrics.Serve(ctx, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	metrics.Claims.Inc()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s%s", addr, metrics.Path))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "mfx_migrator_claims_total")
+	require.Contains(t, string(body), "go_goroutines")
+
+	_, err = metrics.Serve(ctx, addr.String())
+	require.ErrorContains(t, err, "could not start metrics listener")
+}
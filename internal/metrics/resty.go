@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+type contextKey string
+
+// routeKey holds the URL template of a request, e.g. `neighborhoods/{neighborhood}/migrations/{uuid}`.
+const routeKey contextKey = "route"
+
+// InstrumentClient records the duration and status code of every response received by the client, and the duration
+// of the requests failing without a response.
+func InstrumentClient(client *resty.Client) *resty.Client {
+	return client.
+		OnBeforeRequest(captureRoute).
+		OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+			observeRequest(resp.Request, strconv.Itoa(resp.StatusCode()), resp.Time())
+			return nil
+		}).
+		OnError(func(req *resty.Request, err error) {
+			// Responses are already observed, only observe requests that failed without one
+			var respErr *resty.ResponseError
+			if !errors.As(err, &respErr) || respErr.Response.RawResponse == nil {
+				observeRequest(req, "error", time.Since(req.Time))
+			}
+		})
+}
+
+// captureRoute keeps the URL template of the request in its context.
+// It must run before resty rewrites the URL with the path parameters, which happens on the first attempt.
+func captureRoute(_ *resty.Client, req *resty.Request) error {
+	if req.Context().Value(routeKey) == nil {
+		req.SetContext(context.WithValue(req.Context(), routeKey, req.URL))
+	}
+	return nil
+}
+
+func observeRequest(req *resty.Request, code string, duration time.Duration) {
+	route, _ := req.Context().Value(routeKey).(string)
+	TalibRequestDuration.WithLabelValues(req.Method, route, code).Observe(duration.Seconds())
+}
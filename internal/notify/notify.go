@@ -0,0 +1,316 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/secrets"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+// Event is the type of a lifecycle event.
+type Event string
+
+const (
+	ItemCompleted        Event = "item.completed"             // The tokens were sent and the work item completed
+	ItemFailed           Event = "item.failed"                // The work item was marked as failed
+	InterventionRequired Event = "item.intervention_required" // The work item failed and the tokens may have moved
+	PolicyHold           Event = "item.policy_hold"           // The eligibility policy put the work item on hold
+	LowBalance           Event = "bank.low_balance"           // The bank balance dropped below its threshold
+	KillSwitchEngaged    Event = "kill_switch.engaged"        // The kill switch stopped the send of the work item
+)
+
+var events = []Event{ItemCompleted, ItemFailed, InterventionRequired, PolicyHold, LowBalance, KillSwitchEngaged}
+
+// Headers sent with every event.
+const (
+	EventHeader     = "X-Migrator-Event"
+	DeliveryHeader  = "X-Migrator-Delivery"
+	TimestampHeader = "X-Migrator-Timestamp"
+	// SignatureHeader holds `sha256=<hex>`, the HMAC-SHA256 of `<timestamp>.<body>` keyed with the webhook secret.
+	SignatureHeader = "X-Migrator-Signature"
+)
+
+// Webhook is a webhook receiving the lifecycle events.
+type Webhook struct {
+	URL        string  `mapstructure:"url"`
+	Secret     string  `mapstructure:"secret"`      // Key of the event signature
+	SecretFile string  `mapstructure:"secret-file"` // File holding the key of the event signature
+	Events     []Event `mapstructure:"events"`      // Events sent to the webhook, all events if empty
+}
+
+// Config is the notification configuration.
+type Config struct {
+	Webhooks         []Webhook         `mapstructure:"webhooks"`
+	LowBalance       map[string]string `mapstructure:"low-balance"` // Bank balance threshold, in base units, by denom
+	RetryCount       int               `mapstructure:"retry-count"`
+	RetryWaitTime    time.Duration     `mapstructure:"retry-wait"`
+	RetryMaxWaitTime time.Duration     `mapstructure:"retry-max-wait"`
+	Timeout          time.Duration     `mapstructure:"timeout"`
+	QueueSize        int               `mapstructure:"queue-size"`    // Maximum number of deliveries waiting to be sent
+	DrainTimeout     time.Duration     `mapstructure:"drain-timeout"` // Maximum wait time for the queued deliveries on Close
+}
+
+// Validate the Config making sure all webhooks and thresholds are valid
+func (c Config) Validate() error {
+	_, err := New(c)
+	return err
+}
+
+// Balance is the bank balance of a denom.
+type Balance struct {
+	Denom     string `json:"denom"`
+	Amount    string `json:"amount"`
+	Threshold string `json:"threshold"`
+}
+
+// Payload is the JSON body of an event.
+type Payload struct {
+	ID         string          `json:"id"` // Unique ID of the event, the same for every delivery attempt
+	Event      Event           `json:"event"`
+	Time       time.Time       `json:"time"`
+	Item       *store.WorkItem `json:"item,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	ErrorClass string          `json:"errorClass,omitempty"`
+	Balance    *Balance        `json:"balance,omitempty"`
+}
+
+type webhook struct {
+	url    string
+	secret []byte
+	events []Event
+}
+
+// Notifier sends the lifecycle events to the webhooks.
+// The events are queued and delivered one at a time by a worker with its own context, so that a slow or unreachable
+// webhook never delays the migrations. A nil Notifier sends nothing.
+type Notifier struct {
+	client     *resty.Client
+	webhooks   []webhook
+	lowBalance map[string]*big.Int
+
+	mu           sync.Mutex
+	closed       bool
+	queue        chan func(ctx context.Context)
+	done         chan struct{} // Closed once the worker stopped
+	ctx          context.Context
+	cancel       context.CancelFunc
+	drainTimeout time.Duration
+}
+
+// New creates a Notifier. It returns nil if no webhook is configured.
+func New(c Config) (*Notifier, error) {
+	lowBalance := make(map[string]*big.Int, len(c.LowBalance))
+	for denom, value := range c.LowBalance {
+		threshold, ok := new(big.Int).SetString(value, 10)
+		if !ok || threshold.Sign() < 0 {
+			return nil, fmt.Errorf("invalid low balance threshold for %s: %s", denom, value)
+		}
+		lowBalance[denom] = threshold
+	}
+
+	if c.RetryCount < 0 {
+		return nil, fmt.Errorf("webhook retry count must be >= 0")
+	}
+
+	if c.QueueSize <= 0 {
+		return nil, fmt.Errorf("webhook queue size must be > 0")
+	}
+
+	var webhooks []webhook
+	for _, w := range c.Webhooks {
+		if w.URL == "" {
+			return nil, fmt.Errorf("webhook URL is required")
+		}
+
+		for _, event := range w.Events {
+			if !slices.Contains(events, event) {
+				return nil, fmt.Errorf("webhook %s: unknown event %s", w.URL, event)
+			}
+		}
+
+		secret := w.Secret
+		if w.SecretFile != "" {
+			var err error
+			if secret, err = secrets.FromFile(w.SecretFile); err != nil {
+				return nil, errors.WithMessagef(err, "webhook %s", w.URL)
+			}
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("webhook %s: secret is required", w.URL)
+		}
+		secrets.Register(secret)
+
+		webhooks = append(webhooks, webhook{url: w.URL, secret: []byte(secret), events: w.Events})
+	}
+
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+
+	// Events have a unique ID, receivers can deduplicate the deliveries, so all failed deliveries are retried
+	client := resty.New().
+		SetRetryCount(c.RetryCount).
+		SetRetryWaitTime(c.RetryWaitTime).
+		SetRetryMaxWaitTime(c.RetryMaxWaitTime).
+		SetTimeout(c.Timeout).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			return err != nil || resp.StatusCode() == http.StatusTooManyRequests || resp.StatusCode() >= http.StatusInternalServerError
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		client:       client,
+		webhooks:     webhooks,
+		lowBalance:   lowBalance,
+		queue:        make(chan func(ctx context.Context), c.QueueSize),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		drainTimeout: c.DrainTimeout,
+	}
+	go n.work()
+	return n, nil
+}
+
+func (n *Notifier) work() {
+	defer close(n.done)
+	for job := range n.queue {
+		job(n.ctx)
+	}
+}
+
+// Go runs the job on the worker of the notifier, after the jobs queued before it, e.g. a check notifying its outcome.
+// The job is dropped if the queue is full or the notifier is closed.
+func (n *Notifier) Go(name string, job func(ctx context.Context)) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		slog.Error("Notifier closed, dropping job", "job", name)
+		return
+	}
+	select {
+	case n.queue <- job:
+	default:
+		slog.Error("Notification queue full, dropping job", "job", name)
+	}
+}
+
+// Close stops accepting jobs and waits for the queued ones, at most the drain timeout of the configuration. The jobs
+// still running past the timeout are canceled.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	timer := time.NewTimer(n.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-n.done:
+	case <-timer.C:
+		slog.Warn("Notification queue not drained in time, canceling the remaining deliveries", "timeout", n.drainTimeout)
+		n.cancel()
+		<-n.done
+	}
+	n.cancel()
+}
+
+// Notify queues the event for the webhooks subscribed to it.
+// Failing to deliver an event is logged, it never fails the migration.
+func (n *Notifier) Notify(payload Payload) {
+	if n == nil {
+		return
+	}
+
+	payload.ID = uuid.NewString()
+	if payload.Time.IsZero() {
+		payload.Time = time.Now().UTC()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Could not marshal event", "event", payload.Event, "error", err)
+		return
+	}
+
+	for _, w := range n.webhooks {
+		if len(w.events) > 0 && !slices.Contains(w.events, payload.Event) {
+			continue
+		}
+
+		n.Go(string(payload.Event), func(ctx context.Context) {
+			if err := n.send(ctx, w, payload, body); err != nil {
+				slog.Error("Could not deliver event", "event", payload.Event, "id", payload.ID, "url", w.url, "error", err)
+				return
+			}
+			slog.Debug("Event delivered", "event", payload.Event, "id", payload.ID, "url", w.url)
+		})
+	}
+}
+
+// LowBalanceThreshold returns the bank balance threshold of the denom, if any.
+func (n *Notifier) LowBalanceThreshold(denom string) (*big.Int, bool) {
+	if n == nil {
+		return nil, false
+	}
+	threshold, ok := n.lowBalance[denom]
+	return threshold, ok
+}
+
+func (n *Notifier) send(ctx context.Context, w webhook, payload Payload, body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader(EventHeader, string(payload.Event)).
+		SetHeader(DeliveryHeader, payload.ID).
+		SetHeader(TimestampHeader, timestamp).
+		SetHeader(SignatureHeader, Sign(w.secret, timestamp, body)).
+		SetBody(body).
+		Post(w.url)
+	if err != nil {
+		return errors.WithMessage(err, "error posting event")
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("response status code: %d", resp.StatusCode())
+	}
+
+	return nil
+}
+
+// Sign returns the signature of an event body sent at the given timestamp.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,192 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/notify"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/testutils"
+)
+
+const secret = "s3cr3t"
+
+type delivery struct {
+	payload   notify.Payload
+	signature string
+}
+
+// webhookServer returns a webhook failing the first `failures` deliveries, and recording the valid ones.
+func webhookServer(t *testing.T, failures int) (*httptest.Server, func() []delivery) {
+	var mu sync.Mutex
+	var deliveries []delivery
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		if calls <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		signature := r.Header.Get(notify.SignatureHeader)
+		require.Equal(t, notify.Sign([]byte(secret), r.Header.Get(notify.TimestampHeader), body), signature)
+
+		var payload notify.Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		require.Equal(t, string(payload.Event), r.Header.Get(notify.EventHeader))
+		require.Equal(t, payload.ID, r.Header.Get(notify.DeliveryHeader))
+
+		deliveries = append(deliveries, delivery{payload: payload, signature: signature})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []delivery {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]delivery(nil), deliveries...)
+	}
+}
+
+func testConfig(webhooks ...notify.Webhook) notify.Config {
+	return notify.Config{
+		Webhooks:         webhooks,
+		RetryCount:       2,
+		RetryWaitTime:    time.Millisecond,
+		RetryMaxWaitTime: time.Millisecond,
+		Timeout:          time.Second,
+		QueueSize:        10,
+		DrainTimeout:     5 * time.Second,
+	}
+}
+
+func TestNotify(t *testing.T) {
+	all, allDeliveries := webhookServer(t, 0)
+	failuresOnly, failuresOnlyDeliveries := webhookServer(t, 0)
+	flaky, flakyDeliveries := webhookServer(t, 2)
+	down, downDeliveries := webhookServer(t, 100)
+
+	notifier, err := notify.New(testConfig(
+		notify.Webhook{URL: all.URL, Secret: secret},
+		notify.Webhook{URL: failuresOnly.URL, Secret: secret, Events: []notify.Event{notify.ItemFailed, notify.InterventionRequired}},
+		notify.Webhook{URL: flaky.URL, Secret: secret},
+		notify.Webhook{URL: down.URL, Secret: secret},
+	))
+	require.NoError(t, err)
+
+	item := &store.WorkItem{Status: store.COMPLETED, UUID: uuid.MustParse(testutils.DummyUUIDStr), ManifestAddress: testutils.DummyManifestAddr}
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted, Item: item})
+	notifier.Notify(notify.Payload{Event: notify.InterventionRequired, Item: item, Error: "boom", ErrorClass: "intervention"})
+	notifier.Close()
+
+	require.Len(t, allDeliveries(), 2)
+	require.Equal(t, notify.ItemCompleted, allDeliveries()[0].payload.Event)
+	require.Equal(t, item.UUID, allDeliveries()[0].payload.Item.UUID)
+	require.Equal(t, store.COMPLETED, allDeliveries()[0].payload.Item.Status)
+	require.NotEmpty(t, allDeliveries()[0].payload.ID)
+
+	require.Len(t, failuresOnlyDeliveries(), 1)
+	require.Equal(t, notify.InterventionRequired, failuresOnlyDeliveries()[0].payload.Event)
+	require.Equal(t, "intervention", failuresOnlyDeliveries()[0].payload.ErrorClass)
+	require.Equal(t, allDeliveries()[1].payload.ID, failuresOnlyDeliveries()[0].payload.ID)
+
+	// The first event is delivered on the third attempt
+	require.Len(t, flakyDeliveries(), 2)
+	require.Empty(t, downDeliveries())
+}
+
+// TestNotify_Queue never waits for an unresponsive webhook, but on Close, for at most the drain timeout.
+func TestNotify_Queue(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	config := testConfig(notify.Webhook{URL: server.URL, Secret: secret})
+	config.RetryCount = 0
+	config.Timeout = time.Minute
+	config.QueueSize = 1
+	config.DrainTimeout = 100 * time.Millisecond
+	notifier, err := notify.New(config)
+	require.NoError(t, err)
+
+	// The first event is being delivered, the second is queued and the third is dropped
+	start := time.Now()
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted})
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 10*time.Millisecond)
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted})
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted})
+	checked := false
+	notifier.Go("check", func(ctx context.Context) { checked = true })
+	require.Less(t, time.Since(start), time.Second)
+
+	// The delivery in progress is canceled, then the queued one fails at once
+	start = time.Now()
+	notifier.Close()
+	require.Less(t, time.Since(start), time.Second)
+	require.LessOrEqual(t, calls.Load(), int32(2))
+	require.False(t, checked)
+
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted}) // Dropped, the notifier is closed
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte(secret+"\n"), 0o600))
+
+	notifier, err := notify.New(testConfig())
+	require.NoError(t, err)
+	require.Nil(t, notifier)
+	notifier.Notify(notify.Payload{Event: notify.ItemCompleted}) // No-op
+	notifier.Close()
+
+	notifier, err = notify.New(testConfig(notify.Webhook{URL: "http://localhost", SecretFile: secretFile}))
+	require.NoError(t, err)
+	require.NotNil(t, notifier)
+
+	tt := []struct {
+		name   string
+		config notify.Config
+		err    string
+	}{
+		{name: "missing URL", config: testConfig(notify.Webhook{Secret: secret}), err: "webhook URL is required"},
+		{name: "missing secret", config: testConfig(notify.Webhook{URL: "http://localhost"}), err: "secret is required"},
+		{name: "missing secret file", config: testConfig(notify.Webhook{URL: "http://localhost", SecretFile: filepath.Join(dir, "missing")}), err: "could not stat secret file"},
+		{name: "unknown event", config: testConfig(notify.Webhook{URL: "http://localhost", Secret: secret, Events: []notify.Event{"item.unknown"}}), err: "unknown event item.unknown"},
+		{name: "invalid queue size", config: notify.Config{Webhooks: []notify.Webhook{{URL: "http://localhost", Secret: secret}}}, err: "webhook queue size must be > 0"},
+		{name: "invalid threshold", config: notify.Config{LowBalance: map[string]string{"umfx": "-1"}}, err: "invalid low balance threshold for umfx"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorContains(t, tc.config.Validate(), tc.err)
+		})
+	}
+}
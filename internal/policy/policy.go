@@ -0,0 +1,265 @@
+package policy
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Action is the outcome of a policy decision.
+type Action string
+
+const (
+	Allow  Action = "allow"  // The migration can proceed
+	Hold   Action = "hold"   // The migration is left untouched until the policy changes
+	Reject Action = "reject" // The migration is marked as failed
+)
+
+// DefaultRuleName is the rule name recorded when no rule matched.
+const DefaultRuleName = "default"
+
+// Built-in rules, checked by the migration before the configured rules. A migration failing one of them is rejected,
+// and the rule name is recorded with the decision.
+const (
+	WhitelistRuleName   = "whitelist"   // The MANY sender is allowed to migrate by the remote database
+	TransactionRuleName = "transaction" // The MANY transaction burns a valid amount for the work item and its destination
+	TokenMapRuleName    = "token-map"   // The MANY token is mapped to a MANIFEST denom
+)
+
+// reservedRuleNames are the rule names which cannot be configured.
+var reservedRuleNames = []string{DefaultRuleName, WhitelistRuleName, TransactionRuleName, TokenMapRuleName}
+
+// Rule is a single eligibility rule.
+// Every criterion set on a rule must match for the rule to apply. Unset criteria match everything.
+type Rule struct {
+	Name        string   `mapstructure:"name"`        // Name of the rule, recorded with every decision
+	Action      Action   `mapstructure:"action"`      // Action to take when the rule matches
+	Senders     []string `mapstructure:"senders"`     // MANY sender addresses
+	Symbols     []string `mapstructure:"symbols"`     // MANY token symbols
+	MinAmount   string   `mapstructure:"min-amount"`  // Minimum MANY amount (inclusive), in the smallest MANY unit
+	MaxAmount   string   `mapstructure:"max-amount"`  // Maximum MANY amount (inclusive), in the smallest MANY unit
+	Destination string   `mapstructure:"destination"` // Regular expression the Manifest destination address must match
+	NotBefore   string   `mapstructure:"not-before"`  // RFC3339 time before which the rule does not apply
+	NotAfter    string   `mapstructure:"not-after"`   // RFC3339 time after which the rule does not apply
+}
+
+// Config is the policy configuration.
+// Rules are evaluated in order and the first matching rule wins.
+type Config struct {
+	DefaultAction Action `mapstructure:"default-action"` // Action to take when no rule matches
+	Rules         []Rule `mapstructure:"rules"`
+}
+
+// Validate the Config making sure all rules can be compiled
+func (c Config) Validate() error {
+	_, err := New(c)
+	return err
+}
+
+// Input is the migration information a policy is evaluated against.
+type Input struct {
+	Sender      string    // MANY sender address
+	Symbol      string    // MANY token symbol
+	Amount      *big.Int  // MANY amount, in the smallest MANY unit
+	Destination string    // Manifest destination address
+	Time        time.Time // Time of the evaluation
+}
+
+// Decision is the result of a policy evaluation.
+type Decision struct {
+	Rule   string // Name of the matching rule, or DefaultRuleName
+	Action Action
+	Reason string
+}
+
+type compiledRule struct {
+	name        string
+	action      Action
+	senders     []string
+	symbols     []string
+	minAmount   *big.Int
+	maxAmount   *big.Int
+	destination *regexp.Regexp
+	notBefore   *time.Time
+	notAfter    *time.Time
+}
+
+// Engine evaluates migrations against a compiled policy.
+type Engine struct {
+	defaultAction Action
+	rules         []compiledRule
+}
+
+// New compiles the policy configuration into an Engine.
+func New(c Config) (*Engine, error) {
+	defaultAction := c.DefaultAction
+	if defaultAction == "" {
+		defaultAction = Allow
+	}
+	if !defaultAction.valid() {
+		return nil, fmt.Errorf("invalid policy default action: %s", defaultAction)
+	}
+
+	names := make(map[string]bool, len(c.Rules))
+	rules := make([]compiledRule, 0, len(c.Rules))
+	for i, rule := range c.Rules {
+		cr, err := compileRule(rule)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid policy rule #%d", i)
+		}
+		if names[cr.name] {
+			return nil, fmt.Errorf("duplicate policy rule name: %s", cr.name)
+		}
+		names[cr.name] = true
+		rules = append(rules, *cr)
+	}
+
+	return &Engine{defaultAction: defaultAction, rules: rules}, nil
+}
+
+func compileRule(rule Rule) (*compiledRule, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("rule name is required")
+	}
+
+	if slices.Contains(reservedRuleNames, rule.Name) {
+		return nil, fmt.Errorf("rule name %s is reserved", rule.Name)
+	}
+
+	if !rule.Action.valid() {
+		return nil, fmt.Errorf("invalid action for rule %s: %s", rule.Name, rule.Action)
+	}
+
+	cr := &compiledRule{
+		name:    rule.Name,
+		action:  rule.Action,
+		senders: rule.Senders,
+		symbols: rule.Symbols,
+	}
+
+	var err error
+	if cr.minAmount, err = parseAmount(rule.MinAmount); err != nil {
+		return nil, errors.WithMessagef(err, "invalid min amount for rule %s", rule.Name)
+	}
+
+	if cr.maxAmount, err = parseAmount(rule.MaxAmount); err != nil {
+		return nil, errors.WithMessagef(err, "invalid max amount for rule %s", rule.Name)
+	}
+
+	if cr.minAmount != nil && cr.maxAmount != nil && cr.minAmount.Cmp(cr.maxAmount) > 0 {
+		return nil, fmt.Errorf("min amount greater than max amount for rule %s", rule.Name)
+	}
+
+	if rule.Destination != "" {
+		if cr.destination, err = regexp.Compile(rule.Destination); err != nil {
+			return nil, errors.WithMessagef(err, "invalid destination pattern for rule %s", rule.Name)
+		}
+	}
+
+	if cr.notBefore, err = parseTime(rule.NotBefore); err != nil {
+		return nil, errors.WithMessagef(err, "invalid not-before time for rule %s", rule.Name)
+	}
+
+	if cr.notAfter, err = parseTime(rule.NotAfter); err != nil {
+		return nil, errors.WithMessagef(err, "invalid not-after time for rule %s", rule.Name)
+	}
+
+	if cr.notBefore != nil && cr.notAfter != nil && cr.notBefore.After(*cr.notAfter) {
+		return nil, fmt.Errorf("not-before after not-after for rule %s", rule.Name)
+	}
+
+	return cr, nil
+}
+
+func parseAmount(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("not a positive integer: %s", s)
+	}
+	return amount, nil
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (a Action) valid() bool {
+	return a == Allow || a == Hold || a == Reject
+}
+
+// Evaluate returns the decision of the first rule matching the input, or the default action if no rule matches.
+func (e *Engine) Evaluate(in Input) Decision {
+	for _, rule := range e.rules {
+		if reason, ok := rule.match(in); ok {
+			return Decision{Rule: rule.name, Action: rule.action, Reason: reason}
+		}
+	}
+	return Decision{Rule: DefaultRuleName, Action: e.defaultAction, Reason: "no rule matched"}
+}
+
+// match returns true and a human-readable reason if all the rule criteria match the input.
+func (r compiledRule) match(in Input) (string, bool) {
+	var matched []string
+
+	if len(r.senders) > 0 {
+		if !slices.Contains(r.senders, in.Sender) {
+			return "", false
+		}
+		matched = append(matched, "sender")
+	}
+
+	if len(r.symbols) > 0 {
+		if !slices.Contains(r.symbols, in.Symbol) {
+			return "", false
+		}
+		matched = append(matched, "symbol")
+	}
+
+	if r.minAmount != nil {
+		if in.Amount == nil || in.Amount.Cmp(r.minAmount) < 0 {
+			return "", false
+		}
+		matched = append(matched, "min-amount")
+	}
+
+	if r.maxAmount != nil {
+		if in.Amount == nil || in.Amount.Cmp(r.maxAmount) > 0 {
+			return "", false
+		}
+		matched = append(matched, "max-amount")
+	}
+
+	if r.destination != nil {
+		if !r.destination.MatchString(in.Destination) {
+			return "", false
+		}
+		matched = append(matched, "destination")
+	}
+
+	if r.notBefore != nil || r.notAfter != nil {
+		if (r.notBefore != nil && in.Time.Before(*r.notBefore)) || (r.notAfter != nil && in.Time.After(*r.notAfter)) {
+			return "", false
+		}
+		matched = append(matched, "time window")
+	}
+
+	if len(matched) == 0 {
+		return "rule has no criteria", true
+	}
+	return "matched " + strings.Join(matched, ", "), true
+}
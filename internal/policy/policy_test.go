@@ -0,0 +1,92 @@
+package policy_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/policy"
+)
+
+func TestPolicy_New(t *testing.T) {
+	tt := []struct {
+		name   string
+		config policy.Config
+		err    string
+	}{
+		{name: "empty", config: policy.Config{}},
+		{name: "invalid default action", config: policy.Config{DefaultAction: "maybe"}, err: "invalid policy default action"},
+		{name: "missing name", config: policy.Config{Rules: []policy.Rule{{Action: policy.Allow}}}, err: "rule name is required"},
+		{name: "reserved name", config: policy.Config{Rules: []policy.Rule{{Name: "default", Action: policy.Allow}}}, err: "reserved"},
+		{name: "built-in rule name", config: policy.Config{Rules: []policy.Rule{{Name: "whitelist", Action: policy.Allow}}}, err: "rule name whitelist is reserved"},
+		{name: "invalid action", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: "maybe"}}}, err: "invalid action"},
+		{name: "duplicate name", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow}, {Name: "r", Action: policy.Hold}}}, err: "duplicate policy rule name"},
+		{name: "invalid min amount", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow, MinAmount: "-1"}}}, err: "invalid min amount"},
+		{name: "invalid max amount", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow, MaxAmount: "abc"}}}, err: "invalid max amount"},
+		{name: "min greater than max", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow, MinAmount: "10", MaxAmount: "1"}}}, err: "min amount greater than max amount"},
+		{name: "invalid destination", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow, Destination: "("}}}, err: "invalid destination pattern"},
+		{name: "invalid not-before", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow, NotBefore: "yesterday"}}}, err: "invalid not-before"},
+		{name: "invalid window", config: policy.Config{Rules: []policy.Rule{{Name: "r", Action: policy.Allow, NotBefore: "2024-02-01T00:00:00Z", NotAfter: "2024-01-01T00:00:00Z"}}}, err: "not-before after not-after"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	engine, err := policy.New(policy.Config{
+		DefaultAction: policy.Reject,
+		Rules: []policy.Rule{
+			{Name: "blocked-sender", Action: policy.Reject, Senders: []string{"mblocked"}},
+			{Name: "large-amount", Action: policy.Hold, MinAmount: "1000000"},
+			{Name: "freeze", Action: policy.Hold, NotBefore: "2024-06-01T00:00:00Z", NotAfter: "2024-06-02T00:00:00Z"},
+			{Name: "mfx", Action: policy.Allow, Symbols: []string{"MFX"}, Destination: "^manifest1[a-z0-9]+$"},
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	freeze := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name   string
+		input  policy.Input
+		rule   string
+		action policy.Action
+	}{
+		{name: "allowed", input: policy.Input{Sender: "mfoo", Symbol: "MFX", Amount: big.NewInt(100), Destination: "manifest1abc", Time: now}, rule: "mfx", action: policy.Allow},
+		{name: "blocked sender", input: policy.Input{Sender: "mblocked", Symbol: "MFX", Amount: big.NewInt(100), Destination: "manifest1abc", Time: now}, rule: "blocked-sender", action: policy.Reject},
+		{name: "large amount", input: policy.Input{Sender: "mfoo", Symbol: "MFX", Amount: big.NewInt(1000000), Destination: "manifest1abc", Time: now}, rule: "large-amount", action: policy.Hold},
+		{name: "time window", input: policy.Input{Sender: "mfoo", Symbol: "MFX", Amount: big.NewInt(100), Destination: "manifest1abc", Time: freeze}, rule: "freeze", action: policy.Hold},
+		{name: "unknown symbol", input: policy.Input{Sender: "mfoo", Symbol: "FOO", Amount: big.NewInt(100), Destination: "manifest1abc", Time: now}, rule: policy.DefaultRuleName, action: policy.Reject},
+		{name: "invalid destination", input: policy.Input{Sender: "mfoo", Symbol: "MFX", Amount: big.NewInt(100), Destination: "cosmos1abc", Time: now}, rule: policy.DefaultRuleName, action: policy.Reject},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := engine.Evaluate(tc.input)
+			require.Equal(t, tc.rule, decision.Rule)
+			require.Equal(t, tc.action, decision.Action)
+			require.NotEmpty(t, decision.Reason)
+		})
+	}
+}
+
+func TestPolicy_DefaultAllow(t *testing.T) {
+	engine, err := policy.New(policy.Config{})
+	require.NoError(t, err)
+
+	decision := engine.Evaluate(policy.Input{Sender: "mfoo", Symbol: "MFX", Amount: big.NewInt(100)})
+	require.Equal(t, policy.DefaultRuleName, decision.Rule)
+	require.Equal(t, policy.Allow, decision.Action)
+}
@@ -0,0 +1,146 @@
+package quarantine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+const (
+	// ArchiveDir is the directory, relative to the quarantine directory, holding the archived work items.
+	ArchiveDir = "archive"
+	// AuditLog is the file, relative to the quarantine directory, recording the operator actions, one JSON entry per line.
+	AuditLog = "audit.jsonl"
+)
+
+// Action is an operator action on a quarantined work item.
+type Action string
+
+const (
+	Requeue Action = "requeue" // The work item was claimed again to be migrated
+	Purge   Action = "purge"   // The work item was resolved
+)
+
+// Entry is an entry of the audit log.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Action  Action    `json:"action"`
+	UUID    uuid.UUID `json:"uuid"`
+	Reason  string    `json:"reason,omitempty"`
+	User    string    `json:"user,omitempty"`    // Operator who ran the action
+	Archive string    `json:"archive,omitempty"` // Path of the archived state file, relative to the quarantine directory
+}
+
+// Quarantine is a directory holding the state files of the work items that failed and need an operator.
+// The state files are moved there by `scripts/claim_and_migrate.sh`.
+type Quarantine struct {
+	Dir string
+}
+
+// New returns the quarantine in the given directory.
+func New(dir string) *Quarantine {
+	return &Quarantine{Dir: dir}
+}
+
+// List returns the quarantined work items, sorted by UUID.
+func (q *Quarantine) List() ([]*store.WorkItem, error) {
+	items, err := store.ReadStates(q.Dir)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not list quarantined work items")
+	}
+	return items, nil
+}
+
+// Get returns the quarantined work item.
+func (q *Quarantine) Get(id uuid.UUID) (*store.WorkItem, error) {
+	item, err := store.ReadState(q.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("work item %s not found in quarantine", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Archive moves the quarantined work item to the archive and records the action in the audit log.
+func (q *Quarantine) Archive(id uuid.UUID, action Action, reason string, now time.Time) error {
+	if err := os.MkdirAll(filepath.Join(q.Dir, ArchiveDir), 0o755); err != nil {
+		return errors.WithMessage(err, "could not create archive directory")
+	}
+
+	archive := filepath.Join(ArchiveDir, fmt.Sprintf("%s-%s.json", id, now.UTC().Format("20060102T150405Z")))
+	if err := os.Rename(q.path(id), filepath.Join(q.Dir, archive)); err != nil {
+		return errors.WithMessagef(err, "could not archive work item %s", id)
+	}
+
+	entry := Entry{Time: now.UTC(), Action: action, UUID: id, Reason: reason, User: operator(), Archive: archive}
+	if err := q.record(entry); err != nil {
+		return errors.WithMessagef(err, "work item %s archived to %s", id, archive)
+	}
+	return nil
+}
+
+// History returns the entries of the audit log, oldest first.
+func (q *Quarantine) History() ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(q.Dir, AuditLog))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not read audit log")
+	}
+
+	var entries []Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, errors.WithMessage(err, "could not parse audit log")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (q *Quarantine) record(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithMessage(err, "could not marshal audit log entry")
+	}
+
+	file, err := os.OpenFile(filepath.Join(q.Dir, AuditLog), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.WithMessage(err, "could not open audit log")
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return errors.WithMessage(err, "could not write audit log")
+	}
+	return nil
+}
+
+func (q *Quarantine) path(id uuid.UUID) string {
+	return filepath.Join(q.Dir, fmt.Sprintf("%s.json", id))
+}
+
+// operator returns the name of the user running the command, empty if unknown.
+func operator() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
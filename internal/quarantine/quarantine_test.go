@@ -0,0 +1,79 @@
+package quarantine_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/quarantine"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+func quarantineItem(t *testing.T, dir string, class errclass.Class) *store.WorkItem {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { require.NoError(t, os.Chdir(wd)) }()
+
+	errStr := "some error"
+	item := &store.WorkItem{Status: store.FAILED, UUID: uuid.New(), Error: &errStr, ErrorClass: class}
+	require.NoError(t, store.SaveState(context.Background(), item))
+	return item
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	q := quarantine.New(dir)
+
+	items, err := q.List()
+	require.NoError(t, err)
+	require.Empty(t, items)
+
+	terminal := quarantineItem(t, dir, errclass.Terminal)
+	intervention := quarantineItem(t, dir, errclass.Intervention)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte("{}"), 0o644))
+
+	items, err = q.List()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.ElementsMatch(t, []uuid.UUID{terminal.UUID, intervention.UUID}, []uuid.UUID{items[0].UUID, items[1].UUID})
+
+	item, err := q.Get(intervention.UUID)
+	require.NoError(t, err)
+	require.Equal(t, errclass.Intervention, item.ErrorClass)
+
+	_, err = q.Get(uuid.New())
+	require.ErrorContains(t, err, "not found in quarantine")
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, q.Archive(terminal.UUID, quarantine.Purge, "refunded", now))
+	require.NoError(t, q.Archive(intervention.UUID, quarantine.Requeue, "", now))
+	require.Error(t, q.Archive(terminal.UUID, quarantine.Purge, "twice", now))
+
+	items, err = q.List()
+	require.NoError(t, err)
+	require.Empty(t, items)
+
+	entries, err := q.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, quarantine.Purge, entries[0].Action)
+	require.Equal(t, terminal.UUID, entries[0].UUID)
+	require.Equal(t, "refunded", entries[0].Reason)
+	require.Equal(t, now, entries[0].Time)
+	require.Equal(t, quarantine.Requeue, entries[1].Action)
+
+	// The archived work item is kept, with its error
+	archived, err := store.ReadState(filepath.Join(dir, entries[0].Archive))
+	require.NoError(t, err)
+	require.Equal(t, terminal.UUID, archived.UUID)
+	require.Equal(t, errclass.Terminal, archived.ErrorClass)
+}
@@ -0,0 +1,201 @@
+package secrets
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Mask replaces secrets in the log output.
+const Mask = "***"
+
+const (
+	// CredentialsDirectoryEnv is the environment variable set by systemd when credentials are passed to a service
+	CredentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+	// DockerSecretsDirectory is the directory where Docker mounts secrets
+	DockerSecretsDirectory = "/run/secrets"
+)
+
+// Sources lists where a secret can be loaded from.
+// Sources are tried in the following order: Value, File, Helper, then the credentials directories.
+type Sources struct {
+	Name   string // Name of the secret, used as the file name in the credentials directories and as the helper argument
+	Value  string // Plaintext value, from a flag, the environment or the config file
+	File   string // Path of a file holding the secret
+	Helper string // Credential helper command, invoked as `<helper> get <name>`
+}
+
+// Resolve loads the secret from the first available source.
+// An empty string is returned if no source holds the secret.
+func Resolve(s Sources) (string, error) {
+	value, err := resolve(s)
+	if err != nil {
+		return "", errors.WithMessagef(err, "could not load %s", s.Name)
+	}
+	return value, nil
+}
+
+func resolve(s Sources) (string, error) {
+	if s.Value != "" {
+		return s.Value, nil
+	}
+
+	if s.File != "" {
+		return FromFile(s.File)
+	}
+
+	if s.Helper != "" {
+		return FromHelper(s.Helper, s.Name)
+	}
+
+	return FromCredentialsDirectories(s.Name)
+}
+
+// FromFile reads a secret from a file.
+// The file must be a regular file not accessible by the group or other users.
+func FromFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", errors.WithMessage(err, "could not stat secret file")
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("secret file %s is not a regular file", path)
+	}
+
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return "", fmt.Errorf("secret file %s permissions %#o are too open, expected 0600 or stricter", path, perm)
+	}
+
+	return readSecret(path)
+}
+
+// FromHelper runs the credential helper command and returns its standard output.
+func FromHelper(helper, name string) (string, error) {
+	args := strings.Fields(helper)
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty credential helper")
+	}
+	args = append(args, "get", name)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.WithMessagef(err, "credential helper %s failed", args[0])
+	}
+
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// FromCredentialsDirectories reads a secret from the systemd credentials directory or the Docker secrets directory.
+// An empty string is returned if the secret is found in neither.
+func FromCredentialsDirectories(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	var dirs []string
+	if dir := os.Getenv(CredentialsDirectoryEnv); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, DockerSecretsDirectory)
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		slog.Debug("loading secret from credentials directory", "name", name, "dir", dir)
+		return readSecret(path)
+	}
+
+	return "", nil
+}
+
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.WithMessage(err, "could not read secret file")
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]struct{}{}
+)
+
+// Register adds a secret to the values redacted from the log output.
+func Register(secret string) {
+	if secret == "" {
+		return
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[secret] = struct{}{}
+}
+
+// empty returns true if no secret is registered.
+func empty() bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return len(registry) == 0
+}
+
+// Redact replaces every registered secret in s.
+// It returns the redacted string and true if s contained a secret.
+func Redact(s string) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	found := false
+	for secret := range registry {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Mask)
+			found = true
+		}
+	}
+	return s, found
+}
+
+// sensitiveKeys are log attribute keys whose values are always masked.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"accesstoken":   true,
+	"refresh_token": true,
+	"refreshtoken":  true,
+	"secret":        true,
+	"authorization": true,
+}
+
+// ReplaceAttr is a slog.HandlerOptions.ReplaceAttr function masking sensitive attributes and registered secrets.
+func ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Mask)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if redacted, ok := Redact(a.Value.String()); ok {
+			return slog.String(a.Key, redacted)
+		}
+	case slog.KindAny:
+		if empty() {
+			break
+		}
+		if redacted, ok := Redact(fmt.Sprintf("%+v", a.Value.Any())); ok {
+			return slog.String(a.Key, redacted)
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"time"
+)
+
+// RecordAttempt records a failed migration attempt of the work item and returns the number of failed attempts.
+func RecordAttempt(item *WorkItem, now time.Time) int {
+	UpdateAudit(item, func(audit *Audit) {
+		audit.Attempts++
+		audit.LastAttempt = &now
+	})
+	return item.Audit.Attempts
+}
+
+// NextAttempt returns the earliest time of the next migration attempt of the work item, zero if no attempt failed.
+// The wait time starts with backoff and doubles with every failed attempt, up to maxBackoff.
+func NextAttempt(item *WorkItem, backoff time.Duration, maxBackoff time.Duration) time.Time {
+	if item.Audit == nil || item.Audit.Attempts == 0 || item.Audit.LastAttempt == nil {
+		return time.Time{}
+	}
+
+	wait := backoff
+	for i := 1; i < item.Audit.Attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return item.Audit.LastAttempt.Add(min(wait, maxBackoff))
+}
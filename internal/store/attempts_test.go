@@ -0,0 +1,39 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+func TestAttempts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	item := &store.WorkItem{Status: store.MIGRATING, Audit: &store.Audit{}}
+	require.True(t, store.NextAttempt(item, time.Minute, time.Hour).IsZero())
+
+	tests := []struct {
+		attempts int
+		wait     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, tt := range tests {
+		for item.Audit.Attempts < tt.attempts {
+			shared := item.Audit
+			attempts := shared.Attempts
+			require.Equal(t, attempts+1, store.RecordAttempt(item, now))
+			require.NotSame(t, shared, item.Audit)
+			require.Equal(t, attempts, shared.Attempts)
+		}
+		require.Equal(t, now.Add(tt.wait), store.NextAttempt(item, time.Minute, time.Hour), "attempts: %d", tt.attempts)
+	}
+}
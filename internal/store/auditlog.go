@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditLog is the file, relative to the state directory, recording the decisions made while migrating the work items,
+// one JSON entry per line. Unlike the state files, it is never deleted.
+const AuditLog = "audit.jsonl"
+
+// AuditEvent is a decision recorded in the audit log.
+type AuditEvent string
+
+const (
+	PolicyEvaluated   AuditEvent = "policy"       // The eligibility policy was evaluated
+	TransferConfirmed AuditEvent = "confirmation" // The migration transaction was confirmed
+)
+
+// AuditEntry is an entry of the audit log.
+type AuditEntry struct {
+	Time         time.Time       `json:"time"`
+	Event        AuditEvent      `json:"event"`
+	UUID         uuid.UUID       `json:"uuid"`
+	Policy       *PolicyDecision `json:"policy,omitempty"`
+	TxHash       string          `json:"txHash,omitempty"` // Hash of the confirmed migration transaction
+	Confirmation *Confirmation   `json:"confirmation,omitempty"`
+}
+
+// AppendAuditLog appends the entry to the audit log of the state directory in the context.
+func AppendAuditLog(ctx context.Context, entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit log entry: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(StateDir(ctx), AuditLog), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// ReadAuditLog returns the entries of the audit log of the state directory in the context, oldest first.
+func ReadAuditLog(ctx context.Context) ([]AuditEntry, error) {
+	data, err := os.ReadFile(filepath.Join(StateDir(ctx), AuditLog))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var entries []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry AuditEntry
+		if err = json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse audit log: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
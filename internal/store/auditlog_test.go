@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := store.WithStateDir(context.Background(), t.TempDir())
+
+	entries, err := store.ReadAuditLog(ctx)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := store.AuditEntry{Time: now, Event: store.PolicyEvaluated, UUID: uuid.New(),
+		Policy: &store.PolicyDecision{Rule: "default", Action: "allow", Time: now}}
+	second := store.AuditEntry{Time: now.Add(time.Minute), Event: store.PolicyEvaluated, UUID: uuid.New(),
+		Policy: &store.PolicyDecision{Rule: "large", Action: "hold", Reason: "too large", Time: now.Add(time.Minute)}}
+	third := store.AuditEntry{Time: now.Add(2 * time.Minute), Event: store.TransferConfirmed, UUID: first.UUID, TxHash: "8F5E3B9A0C1D2E3F",
+		Confirmation: &store.Confirmation{Depth: 2, Height: 10, ConfirmedHeight: 12}}
+	require.NoError(t, store.AppendAuditLog(ctx, first))
+	require.NoError(t, store.AppendAuditLog(ctx, second))
+	require.NoError(t, store.AppendAuditLog(ctx, third))
+
+	// The state files are the only JSON files of the state directory
+	items, err := store.ReadStates(store.StateDir(ctx))
+	require.NoError(t, err)
+	require.Empty(t, items)
+
+	entries, err = store.ReadAuditLog(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []store.AuditEntry{first, second, third}, entries)
+}
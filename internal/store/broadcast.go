@@ -0,0 +1,23 @@
+package store
+
+import (
+	"slices"
+)
+
+// Pending returns true if the migration stopped before the chain accepted or rejected the transaction.
+func (b Broadcast) Pending() bool {
+	return b.TxHash == "" && !b.Rejected
+}
+
+// RecordBroadcast records the broadcast of a migration transaction in the work item audit.
+// A broadcast accepted or rejected by the chain completes the pending broadcast recorded right before the transaction
+// was sent, if any.
+func RecordBroadcast(item *WorkItem, broadcast Broadcast) {
+	UpdateAudit(item, func(audit *Audit) {
+		broadcasts := slices.Clip(audit.Broadcasts)
+		if n := len(broadcasts); n > 0 && broadcasts[n-1].Pending() && !broadcast.Pending() {
+			broadcasts = broadcasts[: n-1 : n-1]
+		}
+		audit.Broadcasts = append(broadcasts, broadcast)
+	})
+}
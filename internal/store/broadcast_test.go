@@ -0,0 +1,37 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+)
+
+func TestRecordBroadcast(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	item := &store.WorkItem{Status: store.MIGRATING}
+
+	record := func(broadcast store.Broadcast) {
+		shared := item.Audit
+		var before []store.Broadcast
+		if shared != nil {
+			before = append(before, shared.Broadcasts...)
+			defer func() { require.Equal(t, before, shared.Broadcasts) }()
+		}
+		store.RecordBroadcast(item, broadcast)
+		require.NotSame(t, shared, item.Audit)
+	}
+
+	// A rejected transaction, then a pending one completed with its hash
+	record(store.Broadcast{Coin: "123umfx", Time: now})
+	record(store.Broadcast{TxHash: "0A", Coin: "123umfx", Rejected: true, Time: now})
+	record(store.Broadcast{Coin: "123umfx", Time: now.Add(time.Minute)})
+	record(store.Broadcast{TxHash: "0B", Coin: "123umfx", Time: now.Add(time.Minute)})
+
+	require.Equal(t, []store.Broadcast{
+		{TxHash: "0A", Coin: "123umfx", Rejected: true, Time: now},
+		{TxHash: "0B", Coin: "123umfx", Time: now.Add(time.Minute)},
+	}, item.Audit.Broadcasts)
+}
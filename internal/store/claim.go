@@ -1,25 +1,39 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/httpclient"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 )
 
 // ClaimWorkItemFromQueue retrieves a work item from the remote database work queue.
-func ClaimWorkItemFromQueue(r *resty.Client) ([]*WorkItem, error) {
+func ClaimWorkItemFromQueue(ctx context.Context, r *resty.Client) (_ []*WorkItem, err error) {
+	ctx, span := tracing.Start(ctx, "ClaimWorkItemFromQueue")
+	defer func() { tracing.End(span, err) }()
+
 	// 1. Claim work items
-	items, err := claimWorkItems(r)
+	items, err := claimWorkItems(ctx, r)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error claiming work items")
 	}
 
 	// 2. Save the work item states
+	now := time.Now().UTC()
 	for _, item := range items {
-		if err := SaveState(item); err != nil {
+		recordStatus(item, now)
+		startTrace(ctx, item)
+		if err := SaveState(ctx, item); err != nil {
 			return nil, err
 		}
 	}
@@ -27,24 +41,47 @@ func ClaimWorkItemFromQueue(r *resty.Client) ([]*WorkItem, error) {
 	return items, nil
 }
 
-func ClaimWorkItemFromUUID(r *resty.Client, uuid uuid.UUID, force bool) (*WorkItem, error) {
-	item, err := claimWorkItem(r, uuid, force)
+func ClaimWorkItemFromUUID(ctx context.Context, r *resty.Client, uuid uuid.UUID, force bool) (_ *WorkItem, err error) {
+	ctx, span := tracing.Start(ctx, "ClaimWorkItemFromUUID", trace.WithAttributes(attribute.String("uuid", uuid.String())))
+	defer func() { tracing.End(span, err) }()
+
+	item, err := claimWorkItem(ctx, r, uuid, force)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error claiming work item")
 	}
 
-	if err := SaveState(item); err != nil {
+	recordStatus(item, time.Now().UTC())
+	startTrace(ctx, item)
+	if err := SaveState(ctx, item); err != nil {
 		return nil, err
 	}
 
 	return item, nil
 }
 
-func claimWorkItems(r *resty.Client) ([]*WorkItem, error) {
-	req := r.R().SetResult(&[]*WorkItem{})
+// startTrace starts the trace of a claimed work item, linked to the claim span. The trace context is kept in the work
+// item audit, so that the following commands continue the trace.
+func startTrace(ctx context.Context, item *WorkItem) {
+	_, span := tracing.Start(ctx, "WorkItem",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(attribute.String("uuid", item.UUID.String())),
+	)
+	defer span.End()
+
+	traceContext := tracing.Inject(trace.ContextWithSpan(ctx, span))
+	if traceContext == nil {
+		return
+	}
+	UpdateAudit(item, func(audit *Audit) { audit.TraceContext = traceContext })
+}
+
+func claimWorkItems(ctx context.Context, r *resty.Client) ([]*WorkItem, error) {
+	// Claiming is not idempotent, a replayed claim could claim another batch of work items
+	req := httpclient.NonIdempotent(r.R().SetContext(ctx)).SetResult(&[]*WorkItem{})
 	response, err := req.Put("neighborhoods/{neighborhood}/migrations/claim/")
 	if err != nil {
-		return nil, errors.WithMessage(err, "error claiming work items")
+		return nil, errors.WithMessage(errclass.Request(err), "error claiming work items")
 	}
 
 	if response == nil {
@@ -53,7 +90,7 @@ func claimWorkItems(r *resty.Client) ([]*WorkItem, error) {
 
 	statusCode := response.StatusCode()
 	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("response status code: %d", statusCode)
+		return nil, errclass.StatusCode(statusCode)
 	}
 
 	claimResponse := response.Result().(*[]*WorkItem)
@@ -64,13 +101,13 @@ func claimWorkItems(r *resty.Client) ([]*WorkItem, error) {
 	return *claimResponse, nil
 }
 
-func claimWorkItem(r *resty.Client, itemUUID uuid.UUID, force bool) (*WorkItem, error) {
-	req := r.R().SetResult(&WorkItem{}).
+func claimWorkItem(ctx context.Context, r *resty.Client, itemUUID uuid.UUID, force bool) (*WorkItem, error) {
+	req := httpclient.NonIdempotent(r.R().SetContext(ctx)).SetResult(&WorkItem{}).
 		SetPathParam("uuid", itemUUID.String()).
 		SetQueryParam("force", fmt.Sprintf("%t", force))
 	response, err := req.Put("neighborhoods/{neighborhood}/migrations/claim/{uuid}")
 	if err != nil {
-		return nil, errors.WithMessage(err, "error claiming work item")
+		return nil, errors.WithMessage(errclass.Request(err), "error claiming work item")
 	}
 
 	if response == nil {
@@ -79,7 +116,7 @@ func claimWorkItem(r *resty.Client, itemUUID uuid.UUID, force bool) (*WorkItem,
 
 	statusCode := response.StatusCode()
 	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("response status code: %d", statusCode)
+		return nil, errclass.StatusCode(statusCode)
 	}
 
 	item := response.Result().(*WorkItem)
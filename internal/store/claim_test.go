@@ -1,6 +1,7 @@
 package store_test
 
 import (
+	"context"
 	"net/url"
 	"os"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
 	"github.com/manifest-network/mfx-migrator/testutils"
 
 	"github.com/manifest-network/mfx-migrator/internal/store"
@@ -35,7 +37,7 @@ func TestStore_Claim(t *testing.T) {
 		{"success_queue", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.MigrationClaimResponder(1, store.CLAIMED)},
 		}, func() {
-			items, err := store.ClaimWorkItemFromQueue(rClient)
+			items, err := store.ClaimWorkItemFromQueue(context.Background(), rClient)
 			require.NotEmpty(t, items)
 			require.NotEqual(t, uuid.Nil, items[0].UUID)
 			require.NoError(t, err)
@@ -45,7 +47,7 @@ func TestStore_Claim(t *testing.T) {
 		{"no_item_queue", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.MigrationClaimResponder(0, store.CLAIMED)},
 		}, func() {
-			item, err := store.ClaimWorkItemFromQueue(rClient)
+			item, err := store.ClaimWorkItemFromQueue(context.Background(), rClient)
 			require.NoError(t, err) // no work items available
 			require.Empty(t, item)
 		}},
@@ -54,44 +56,56 @@ func TestStore_Claim(t *testing.T) {
 			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.MigrationClaimOneResponder(store.CLAIMED)},
 		}, func() {
 			myUUID := uuid.MustParse("5aa19d2a-4bdf-4687-a850-1804756b3f1f")
-			item, err := store.ClaimWorkItemFromUUID(rClient, myUUID, false)
+			item, err := store.ClaimWorkItemFromUUID(context.Background(), rClient, myUUID, false)
 			require.NoError(t, err)
 			require.NotNil(t, item)
 			require.Equal(t, myUUID, item.UUID)
+			require.Len(t, item.Audit.History, 1)
+			require.Equal(t, store.CLAIMED, item.Audit.History[0].Status)
 		}},
 		// Fail to claim a work item by UUID (work item UUID not found)
 		{"failure_uuid_not_found", []testutils.HttpResponder{
 			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.NotFoundResponder},
 		}, func() {
-			item, err := store.ClaimWorkItemFromUUID(rClient, uuid.New(), false)
+			item, err := store.ClaimWorkItemFromUUID(context.Background(), rClient, uuid.New(), false)
 			require.Error(t, err) // work item not found
 			require.ErrorContains(t, err, "error claiming work item")
 			require.ErrorContains(t, err, "status code: 404")
 			require.Nil(t, item)
+			require.Equal(t, errclass.Terminal, errclass.Of(err))
 		}},
 		// Fail to claim a work item by UUID (response is not a work item)
 		{"invalid_work_item", []testutils.HttpResponder{
 			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.GarbageResponder},
 		}, func() {
-			item, err := store.ClaimWorkItemFromUUID(rClient, uuid.New(), false)
+			item, err := store.ClaimWorkItemFromUUID(context.Background(), rClient, uuid.New(), false)
 			require.Error(t, err)
 			require.ErrorContains(t, err, "cannot unmarshal")
 			require.Nil(t, item)
+			require.Equal(t, errclass.Terminal, errclass.Of(err))
 		}},
 		// Fail to claim a work item from the queue (work item list is invalid)
 		{"invalid_work_items", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.GarbageResponder},
 		}, func() {
-			item, err := store.ClaimWorkItemFromQueue(rClient)
+			item, err := store.ClaimWorkItemFromQueue(context.Background(), rClient)
 			require.Error(t, err)
 			require.ErrorContains(t, err, "cannot unmarshal")
 			require.Nil(t, item)
 		}},
+		// Fail to claim a work item from the queue (server error)
+		{"server_error_queue", []testutils.HttpResponder{
+			{Method: "PUT", Url: testutils.ClaimUrl, Responder: httpmock.NewStringResponder(503, "")},
+		}, func() {
+			_, err := store.ClaimWorkItemFromQueue(context.Background(), rClient)
+			require.ErrorContains(t, err, "status code: 503")
+			require.Equal(t, errclass.Transient, errclass.Of(err))
+		}},
 		// Fail to claim a work item from the queue (invalid work item list URL)
 		{"invalid_all_work_items_url", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.NotFoundResponder},
 		}, func() {
-			_, err := store.ClaimWorkItemFromQueue(rClient)
+			_, err := store.ClaimWorkItemFromQueue(context.Background(), rClient)
 			require.Error(t, err) // unable to list work items
 			require.ErrorContains(t, err, "error claiming work items")
 			require.ErrorContains(t, err, "status code: 404")
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -8,16 +9,25 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 )
 
 // GetWorkItem retrieves a work item from the remote database by UUID.
-func GetWorkItem(r *resty.Client, itemUUID uuid.UUID) (*WorkItem, error) {
+func GetWorkItem(ctx context.Context, r *resty.Client, itemUUID uuid.UUID) (_ *WorkItem, err error) {
+	ctx, span := tracing.Start(ctx, "GetWorkItem", trace.WithAttributes(attribute.String("uuid", itemUUID.String())))
+	defer func() { tracing.End(span, err) }()
+
 	req := r.R().
+		SetContext(ctx).
 		SetPathParam("uuid", itemUUID.String()).
 		SetResult(&WorkItem{})
 	response, err := req.Get("neighborhoods/{neighborhood}/migrations/{uuid}")
 	if err != nil {
-		return nil, errors.WithMessage(err, ErrorGettingWorkItem)
+		return nil, errors.WithMessage(errclass.Request(err), ErrorGettingWorkItem)
 	}
 
 	if response == nil {
@@ -26,7 +36,7 @@ func GetWorkItem(r *resty.Client, itemUUID uuid.UUID) (*WorkItem, error) {
 
 	statusCode := response.StatusCode()
 	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("response status code: %d", statusCode)
+		return nil, errclass.StatusCode(statusCode)
 	}
 
 	item := response.Result().(*WorkItem)
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"github.com/manifest-network/mfx-migrator/internal/metrics"
+)
+
+// recordStatus appends the current status of the work item to its history, if it changed.
+// The time spent in the previous status is recorded in the metrics.
+func recordStatus(item *WorkItem, now time.Time) {
+	if item.Audit != nil && len(item.Audit.History) > 0 {
+		previous := item.Audit.History[len(item.Audit.History)-1]
+		if previous.Status == item.Status {
+			return
+		}
+		metrics.StatusDuration.WithLabelValues(previous.Status.String()).Observe(now.Sub(previous.Time).Seconds())
+	}
+
+	UpdateAudit(item, func(audit *Audit) {
+		audit.History = append(slices.Clip(audit.History), StatusChange{Status: item.Status, Time: now})
+	})
+}
@@ -1,14 +1,50 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
-func SaveState(item *WorkItem) error {
+// stateDirKey is the context key of the directory holding the state files, see WithStateDir.
+const stateDirKey ContextKey = "stateDir"
+
+// stateDir is the default directory holding the state files.
+var stateDir = "."
+
+// SetStateDir sets the default directory holding the state files, the current directory by default.
+func SetStateDir(dir string) {
+	stateDir = dir
+}
+
+// WithStateDir returns a context in which the state files are held in the given directory instead of the default one.
+// This allows the work items of several neighborhoods to be handled at the same time.
+func WithStateDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, stateDirKey, dir)
+}
+
+// StateDir returns the directory holding the state files in the context.
+func StateDir(ctx context.Context) string {
+	if dir, ok := ctx.Value(stateDirKey).(string); ok {
+		return dir
+	}
+	return stateDir
+}
+
+// StatePath returns the path of the state file of the work item in the context.
+func StatePath(ctx context.Context, uuid string) string {
+	return filepath.Join(StateDir(ctx), fmt.Sprintf("%s.json", uuid))
+}
+
+func SaveState(ctx context.Context, item *WorkItem) error {
 	slog.Debug("saving state", "item", item)
 
 	// Convert the WorkItem to JSON
@@ -18,7 +54,7 @@ func SaveState(item *WorkItem) error {
 	}
 
 	// Create a new file with the UUID of the WorkItem as the filename
-	file, err := os.Create(fmt.Sprintf("%s.json", item.UUID))
+	file, err := os.Create(StatePath(ctx, item.UUID.String()))
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
@@ -33,11 +69,16 @@ func SaveState(item *WorkItem) error {
 	return nil
 }
 
-func LoadState(uuid string) (*WorkItem, error) {
+func LoadState(ctx context.Context, uuid string) (*WorkItem, error) {
 	slog.Debug("loading state", "uuid", uuid)
 
-	// Open the file with the UUID as the filename
-	file, err := os.Open(fmt.Sprintf("%s.json", uuid))
+	// The file is named after the UUID
+	return ReadState(StatePath(ctx, uuid))
+}
+
+// ReadState reads the work item from the state file at the given path.
+func ReadState(path string) (*WorkItem, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -58,3 +99,30 @@ func LoadState(uuid string) (*WorkItem, error) {
 
 	return &item, nil
 }
+
+// ReadStates reads the work items from the state files in the given directory, sorted by UUID.
+// Files not named after a UUID, e.g. the configuration file, are skipped.
+func ReadStates(dir string) ([]*WorkItem, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state files: %w", err)
+	}
+
+	var items []*WorkItem
+	for _, path := range paths {
+		if _, err := uuid.Parse(strings.TrimSuffix(filepath.Base(path), ".json")); err != nil {
+			continue
+		}
+
+		item, err := ReadState(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		items = append(items, item)
+	}
+
+	slices.SortFunc(items, func(a, b *WorkItem) int {
+		return strings.Compare(a.UUID.String(), b.UUID.String())
+	})
+	return items, nil
+}
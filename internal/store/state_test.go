@@ -1,7 +1,9 @@
 package store_test
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/uuid"
@@ -23,10 +25,31 @@ func TestSaveLoadState(t *testing.T) {
 		ManifestHash:     nil,
 		ManifestDatetime: nil,
 	}
-	err := store.SaveState(item)
+	err := store.SaveState(context.Background(), item)
 	require.NoError(t, err)
 
-	otherItem, err := store.LoadState(someUUID.String())
+	otherItem, err := store.LoadState(context.Background(), someUUID.String())
 	require.NoError(t, err)
 	require.Equal(t, item, otherItem)
 }
+
+func TestWithStateDir(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	require.NoError(t, os.Mkdir("neighborhood-1", 0o700))
+
+	item := &store.WorkItem{Status: store.CLAIMED, UUID: uuid.New()}
+	ctx := store.WithStateDir(context.Background(), "neighborhood-1")
+	require.Equal(t, "neighborhood-1", store.StateDir(ctx))
+	require.Equal(t, ".", store.StateDir(context.Background()))
+	require.NoError(t, store.SaveState(ctx, item))
+
+	require.FileExists(t, filepath.Join("neighborhood-1", item.UUID.String()+".json"))
+	_, err := store.LoadState(context.Background(), item.UUID.String())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	loaded, err := store.LoadState(ctx, item.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, item, loaded)
+}
@@ -5,11 +5,14 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
 	"github.com/manifest-network/mfx-migrator/internal/utils"
 )
 
 type Token struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"` // Optional
+	ExpiresIn    int64  `json:"expires_in"`    // Optional, in seconds
 }
 
 type WorkItemStatus int
@@ -40,6 +43,61 @@ type WorkItem struct {
 	ManifestHash     *string        `json:"manifestHash"`
 	ManifestDatetime *time.Time     `json:"manifestDatetime"`
 	Error            *string        `json:"error"`
+	ErrorClass       errclass.Class `json:"errorClass,omitempty"` // Local-only, class of the error
+	Audit            *Audit         `json:"audit,omitempty"`      // Local-only, never sent to the remote database
+}
+
+// Audit holds local metadata about how a work item was processed.
+type Audit struct {
+	Policy       *PolicyDecision   `json:"policy,omitempty"`
+	History      []StatusChange    `json:"history,omitempty"`
+	TraceContext map[string]string `json:"traceContext,omitempty"` // Trace of the work item, continued by each command
+	Attempts     int               `json:"attempts,omitempty"`     // Number of failed migration attempts
+	LastAttempt  *time.Time        `json:"lastAttempt,omitempty"`  // Time of the last failed migration attempt
+	Confirmation *Confirmation     `json:"confirmation,omitempty"` // Confirmation of the migration transaction
+	Broadcasts   []Broadcast       `json:"broadcasts,omitempty"`   // Migration transactions sent to the MANIFEST chain
+}
+
+// UpdateAudit updates a copy of the audit of the work item, then replaces the audit with the copy. The audit may be
+// shared with copies of the work item, it is never modified in place; the slices of the copy must be clipped before
+// appending to them.
+func UpdateAudit(item *WorkItem, update func(audit *Audit)) {
+	var audit Audit
+	if item.Audit != nil {
+		audit = *item.Audit
+	}
+	update(&audit)
+	item.Audit = &audit
+}
+
+// Broadcast records a migration transaction sent to the MANIFEST chain. It is saved right before the transaction is
+// broadcast, then again with the hash returned by the chain, before waiting for the transaction to be included.
+type Broadcast struct {
+	TxHash   string    `json:"txHash,omitempty"`   // Empty if the migration stopped before the chain returned the hash
+	Coin     string    `json:"coin"`               // Coin sent, e.g. `123umfx`
+	Rejected bool      `json:"rejected,omitempty"` // True if the chain rejected the transaction, no token was sent
+	Time     time.Time `json:"time"`
+}
+
+// Confirmation is the confirmation of the migration transaction on the MANIFEST chain.
+type Confirmation struct {
+	Depth           uint  `json:"depth"`           // Number of blocks required past the inclusion height
+	Height          int64 `json:"height"`          // Inclusion height of the transaction
+	ConfirmedHeight int64 `json:"confirmedHeight"` // Latest block height when the transaction was confirmed
+}
+
+// StatusChange records the time a work item entered a status.
+type StatusChange struct {
+	Status WorkItemStatus `json:"status"`
+	Time   time.Time      `json:"time"`
+}
+
+// PolicyDecision records the outcome of the eligibility policy evaluation.
+type PolicyDecision struct {
+	Rule   string    `json:"rule"`
+	Action string    `json:"action"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
 }
 
 // Equal returns true if the WorkItem is equal to the other WorkItem
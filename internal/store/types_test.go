@@ -43,3 +43,15 @@ func TestTypes_WorkItem(t *testing.T) {
 	require.True(t, wi.Equal(wi))
 	require.False(t, wi.Equal(store.WorkItem{}))
 }
+
+func TestUpdateAudit(t *testing.T) {
+	item := &store.WorkItem{}
+	store.UpdateAudit(item, func(audit *store.Audit) { audit.Attempts = 1 })
+	require.Equal(t, 1, item.Audit.Attempts)
+
+	// A copy of the work item keeps its audit
+	shared := *item
+	store.UpdateAudit(item, func(audit *store.Audit) { audit.Attempts = 2 })
+	require.Equal(t, 2, item.Audit.Attempts)
+	require.Equal(t, 1, shared.Audit.Attempts)
+}
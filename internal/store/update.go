@@ -1,24 +1,38 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/manifest-network/mfx-migrator/internal/errclass"
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
 	"github.com/manifest-network/mfx-migrator/internal/utils"
 )
 
 // UpdateWorkItemAndSaveState updates a work item in the remote database and saves the state locally.
-func UpdateWorkItemAndSaveState(r *resty.Client, item WorkItem) error {
+// The status change is recorded in the work item history.
+func UpdateWorkItemAndSaveState(ctx context.Context, r *resty.Client, item *WorkItem) (err error) {
+	ctx, span := tracing.Start(ctx, "UpdateWorkItemAndSaveState", trace.WithAttributes(
+		attribute.String("uuid", item.UUID.String()),
+		attribute.String("status", item.Status.String()),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	// 1. Update the work item
-	if err := updateWorkItem(r, item); err != nil {
+	if err := updateWorkItem(ctx, r, *item); err != nil {
 		return errors.WithMessage(err, "error updating remote work item")
 	}
 
 	// 2. Save the work item state
-	if err := SaveState(&item); err != nil {
+	recordStatus(item, time.Now().UTC())
+	if err := SaveState(ctx, item); err != nil {
 		return err
 	}
 
@@ -26,7 +40,7 @@ func UpdateWorkItemAndSaveState(r *resty.Client, item WorkItem) error {
 }
 
 // updateWorkItem updates a work item in the remote database.
-func updateWorkItem(r *resty.Client, item WorkItem) error {
+func updateWorkItem(ctx context.Context, r *resty.Client, item WorkItem) error {
 	// 1. Create an update request
 	updateRequest := WorkItemUpdateRequest{
 		Status:           item.Status,
@@ -37,12 +51,13 @@ func updateWorkItem(r *resty.Client, item WorkItem) error {
 
 	// 2. Send the update request
 	req := r.R().
+		SetContext(ctx).
 		SetPathParam("uuid", item.UUID.String()).
 		SetBody(&updateRequest).
 		SetResult(&WorkItemUpdateResponse{})
 	response, err := req.Put("neighborhoods/{neighborhood}/migrations/{uuid}")
 	if err != nil {
-		return errors.WithMessage(err, "error updating work item")
+		return errors.WithMessage(errclass.Request(err), "error updating work item")
 	}
 
 	if response == nil {
@@ -51,7 +66,7 @@ func updateWorkItem(r *resty.Client, item WorkItem) error {
 
 	statusCode := response.StatusCode()
 	if statusCode != http.StatusOK {
-		return fmt.Errorf("response status code: %d", statusCode)
+		return errclass.StatusCode(statusCode)
 	}
 
 	// 3. Unmarshal the update response
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/manifest-network/mfx-migrator"
+	serviceName         = "mfx-migrator"
+)
+
+// propagator carries the trace context in the W3C `traceparent` and `tracestate` headers.
+var propagator = propagation.TraceContext{}
+
+// provider is the tracer provider configured by Setup, nil if tracing is disabled.
+var provider *sdktrace.TracerProvider
+
+// Setup exports the spans over OTLP/HTTP to the endpoint, the full URL of the traces endpoint of the collector,
+// e.g. `http://localhost:4318/v1/traces`. Tracing is disabled if the endpoint is empty.
+// The standard `OTEL_EXPORTER_OTLP_*` environment variables, e.g. the headers, are honored.
+func Setup(ctx context.Context, endpoint string, version string) error {
+	if endpoint == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return errors.WithMessage(err, "could not create trace exporter")
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return errors.WithMessage(err, "could not create trace resource")
+	}
+
+	provider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	slog.Debug("Tracing enabled", "endpoint", endpoint)
+	return nil
+}
+
+// Shutdown exports the remaining spans and stops the exporter, if tracing is enabled.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+
+	if err := provider.Shutdown(ctx); err != nil {
+		return errors.WithMessage(err, "could not export remaining spans")
+	}
+	return nil
+}
+
+// Start starts a span, child of the span in the context if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End ends the span, recording the error if any.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject returns the trace context of the span in the context, to be persisted. It is empty if tracing is disabled.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns the context continuing the persisted trace context.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// InstrumentClient sends the trace context of the requests in their headers.
+func InstrumentClient(client *resty.Client) *resty.Client {
+	return client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+		return nil
+	})
+}
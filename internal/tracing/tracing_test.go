@@ -0,0 +1,60 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/manifest-network/mfx-migrator/internal/tracing"
+	"github.com/manifest-network/mfx-migrator/testutils"
+)
+
+func TestTracing(t *testing.T) {
+	collector := testutils.NewTraceCollector(t)
+	require.NoError(t, tracing.Setup(context.Background(), collector.URL, "test"))
+
+	var traceparent string
+	talib := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer talib.Close()
+	client := tracing.InstrumentClient(resty.New().SetBaseURL(talib.URL))
+
+	// Claim, then persist the trace context of the work item
+	ctx, claim := tracing.Start(context.Background(), "claim")
+	traceContext := tracing.Inject(ctx)
+	require.NotEmpty(t, traceContext)
+	tracing.End(claim, nil)
+
+	// Continue the trace in another command
+	ctx, migrate := tracing.Start(tracing.Extract(context.Background(), traceContext), "migrate")
+	_, err := client.R().SetContext(ctx).Get("/")
+	require.NoError(t, err)
+	tracing.End(migrate, context.Canceled)
+
+	traceID := trace.SpanContextFromContext(ctx).TraceID().String()
+	require.Contains(t, traceparent, traceID)
+	require.Contains(t, traceparent, trace.SpanContextFromContext(ctx).SpanID().String())
+
+	require.NoError(t, tracing.Shutdown(context.Background()))
+	require.ElementsMatch(t, []testutils.CollectedSpan{
+		{Name: "claim", TraceID: traceID},
+		{Name: "migrate", TraceID: traceID},
+	}, collector.Spans())
+}
+
+func TestTracing_Disabled(t *testing.T) {
+	require.NoError(t, tracing.Setup(context.Background(), "", "test"))
+
+	ctx, span := tracing.Start(context.Background(), "claim")
+	defer span.End()
+
+	require.Nil(t, tracing.Inject(ctx))
+	require.Equal(t, ctx, tracing.Extract(ctx, nil))
+}
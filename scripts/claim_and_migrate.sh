@@ -1,32 +1,54 @@
 #!/usr/bin/env bash
 
 WORKDIR=/jobs
-QUARANTINE_DIR=/quarantine
+
+# Exit codes of a failed migration, by error class
+EXIT_TRANSIENT=3
+EXIT_TERMINAL=4
+EXIT_INTERVENTION=5
 
 cd "$WORKDIR" || exit 1
 
+# With a profile or neighborhoods, the state files are in a subdirectory of the workdir
+STATE_DIR=$(mfx-migrator state-dir) || exit 1
+# Quarantined work items are managed with `mfx-migrator quarantine`
+QUARANTINE_DIR=$(mfx-migrator quarantine dir) || exit 1
+
 # Claim some work from queue
 mfx-migrator claim
 
-# Run the migration for each JSON file in the workdir
-find . -name '*.json' ! -name "config.json" -print0 | xargs -0 -I{} -P 1 bash -c 'mfx-migrator migrate --uuid $(basename "{}" .json)'
-
-# Check each JSON file for a failed status and a non-empty error
-for file in *.json; do
-    [[ -e "$f" ]] || break # Exit if no files found
+# Run the migration for each JSON file in the state directory
+for file in "$STATE_DIR"/*.json; do
+    [[ -e "$file" ]] || break # Exit if no files found
 
-    if [ "$file" == "config.json" ]; then
+    if [ "$(basename "$file")" == "config.json" ]; then
         continue
     fi
 
-    status=$(jq -r '.status' "$file")
-    error=$(jq -r '.error' "$file")
-
-    if [[ "$status" -eq 5 ]] && [[ -n "$error" ]]; then
-        # If the quarantine directory doesn't exist, create it
-        mkdir -p "$QUARANTINE_DIR"
-
-        # Move the file to the quarantine directory
-        mv "$file" "$QUARANTINE_DIR/"
-    fi
+    uuid=$(basename "$file" .json)
+    mfx-migrator migrate --uuid "$uuid"
+    code=$?
+
+    case $code in
+        0)
+            ;;
+        "$EXIT_TRANSIENT")
+            # Nothing happened, keep the state file so the migration is retried on the next run
+            echo "Migration of $uuid failed temporarily, it will be retried" >&2
+            ;;
+        "$EXIT_TERMINAL")
+            # The work item was rejected
+            mkdir -p "$QUARANTINE_DIR"
+            mv "$file" "$QUARANTINE_DIR/"
+            ;;
+        "$EXIT_INTERVENTION")
+            # The tokens may have been sent, an operator must reconcile the work item
+            echo "Migration of $uuid requires an operator intervention" >&2
+            mkdir -p "$QUARANTINE_DIR"
+            mv "$file" "$QUARANTINE_DIR/"
+            ;;
+        *)
+            echo "Migration of $uuid failed with exit code $code" >&2
+            ;;
+    esac
 done
@@ -0,0 +1,80 @@
+// Package fakechain builds a stand-in chain binary replying to the commands with scripted responses, to test the
+// chain commands without a chain.
+package fakechain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Response is the scripted response of a command.
+type Response struct {
+	Stdout string
+	Stderr string
+	Exit   int // Exit code
+	Sleep  int // Number of seconds spent before responding
+}
+
+// Command is a command of the binary and its response.
+type Command struct {
+	Pattern string // Shell pattern matched against the arguments, e.g. `q block *`
+	Response
+}
+
+// Binary is a stand-in chain binary.
+type Binary struct {
+	Path string
+	log  string
+}
+
+// New writes a binary replying to the commands with the response of the first matching command.
+// Unexpected commands exit with code 1.
+func New(t *testing.T, commands ...Command) *Binary {
+	t.Helper()
+
+	dir := t.TempDir()
+	b := &Binary{Path: filepath.Join(dir, "manifestd"), log: filepath.Join(dir, "calls.log")}
+
+	var script strings.Builder
+	fmt.Fprintf(&script, "#!/bin/sh\necho \"$*\" >> %q\ncase \"$*\" in\n", b.log)
+	for i, c := range commands {
+		stdout := filepath.Join(dir, fmt.Sprintf("%d.out", i))
+		stderr := filepath.Join(dir, fmt.Sprintf("%d.err", i))
+		for path, content := range map[string]string{stdout: c.Stdout, stderr: c.Stderr} {
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		fmt.Fprintf(&script, "  %s)\n", c.Pattern)
+		if c.Sleep > 0 {
+			// Replace the shell, so that killing the command stops the sleep
+			fmt.Fprintf(&script, "    exec sleep %d ;;\n", c.Sleep)
+			continue
+		}
+		fmt.Fprintf(&script, "    cat %q; cat %q >&2; exit %d ;;\n", stdout, stderr, c.Exit)
+	}
+	script.WriteString("esac\necho \"unexpected command: $*\" >&2\nexit 1\n")
+
+	if err := os.WriteFile(b.Path, []byte(script.String()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Calls returns the arguments of the commands executed so far, one string per command.
+func (b *Binary) Calls(t *testing.T) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(b.log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
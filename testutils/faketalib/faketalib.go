@@ -0,0 +1,527 @@
+// Package faketalib is an in-memory talib, the remote database of the migrations, for end-to-end tests and local
+// development.
+//
+// It implements the endpoints used by the migrator with real state transitions: work items are claimed from the
+// queue or by UUID, moved to MIGRATING, then COMPLETED or FAILED, and invalid transitions are rejected.
+// Faults can be injected to fail chosen requests.
+package faketalib
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/manifest-network/mfx-migrator/internal/many"
+	"github.com/manifest-network/mfx-migrator/internal/store"
+	"github.com/manifest-network/mfx-migrator/internal/utils"
+)
+
+// Routes of the fake talib, also used to target the faults.
+const (
+	RouteLogin       = "POST /auth/login"
+	RouteRefresh     = "POST /auth/refresh"
+	RouteClaimQueue  = "PUT /neighborhoods/{neighborhood}/migrations/claim/"
+	RouteClaimUUID   = "PUT /neighborhoods/{neighborhood}/migrations/claim/{uuid}"
+	RouteGet         = "GET /neighborhoods/{neighborhood}/migrations/{uuid}"
+	RouteUpdate      = "PUT /neighborhoods/{neighborhood}/migrations/{uuid}"
+	RouteTransaction = "GET /neighborhoods/{neighborhood}/transactions/{thash}"
+	RouteWhitelist   = "GET /migrations-whitelist/{address}"
+	RouteKillSwitch  = "GET /kill-switch"
+)
+
+// tokenLifetime is the lifetime of the access tokens, in seconds.
+const tokenLifetime = 3600
+
+// Migration is a migration of MANY tokens, the MANY transaction burning the tokens and the resulting work item.
+type Migration struct {
+	UUID            uuid.UUID `json:"uuid"`            // Random if empty
+	From            string    `json:"from"`            // MANY address burning the tokens
+	ManifestAddress string    `json:"manifestAddress"` // Destination of the tokens on the MANIFEST chain
+	Amount          string    `json:"amount"`          // Amount of tokens, in MANY base units
+	Symbol          string    `json:"symbol"`          // MANY token symbol
+	Allowed         bool      `json:"allowed"`         // Whether the MANY address is in the whitelist
+	Neighborhood    string    `json:"neighborhood"`    // ID of the neighborhood of the work item, Server.Neighborhood if empty
+}
+
+// Fault makes the matching requests fail.
+type Fault struct {
+	Route  string // Route of the failing requests, one of the Route constants, any route if empty
+	UUID   string // UUID of the work item of the failing requests, any work item if empty
+	Status int    // Status code of the failed response
+	After  int    // Number of matching requests served before failing
+	Times  int    // Number of requests failing, every request if 0
+	// Processed processes the request before failing, as if the response was lost.
+	Processed bool
+}
+
+type fault struct {
+	Fault
+	served    int
+	remaining int
+}
+
+// Server is an in-memory talib. It is an http.Handler, e.g. to be served by httptest.NewServer.
+type Server struct {
+	Username     string
+	Password     string
+	Neighborhood string // ID of the default neighborhood of the work items
+	ClaimBatch   int    // Maximum number of work items claimed from the queue at once
+
+	mu            sync.Mutex
+	mux           *http.ServeMux
+	items         map[uuid.UUID]*store.WorkItem
+	neighborhoods map[uuid.UUID]string // Neighborhood of the work items, by UUID
+	queue         []uuid.UUID          // UUIDs of the work items, in creation order
+	txs           map[string]many.TxInfo
+	whitelist     map[string]bool
+	tokens        map[string]bool
+	refresh       map[string]bool
+	faults        []*fault
+	killSwitch    *string // Reason the kill switch is engaged, nil if released
+}
+
+// New creates a fake talib accepting the given credentials, serving neighborhood 0, and the neighborhoods of the added
+// work items, and claiming one work item at a time from the queue.
+func New(username, password string) *Server {
+	s := &Server{
+		Username:      username,
+		Password:      password,
+		Neighborhood:  "0",
+		ClaimBatch:    1,
+		mux:           http.NewServeMux(),
+		items:         make(map[uuid.UUID]*store.WorkItem),
+		neighborhoods: make(map[uuid.UUID]string),
+		txs:           make(map[string]many.TxInfo),
+		whitelist:     make(map[string]bool),
+		tokens:        make(map[string]bool),
+		refresh:       make(map[string]bool),
+	}
+
+	s.mux.HandleFunc(RouteLogin, s.login)
+	s.mux.HandleFunc(RouteRefresh, s.refreshToken)
+	s.mux.HandleFunc(RouteClaimQueue, s.authorized(s.claimQueue))
+	s.mux.HandleFunc(RouteClaimUUID, s.authorized(s.claimUUID))
+	s.mux.HandleFunc(RouteGet, s.authorized(s.get))
+	s.mux.HandleFunc(RouteUpdate, s.authorized(s.update))
+	s.mux.HandleFunc(RouteTransaction, s.authorized(s.transaction))
+	s.mux.HandleFunc(RouteWhitelist, s.authorized(s.allowed))
+	s.mux.HandleFunc(RouteKillSwitch, s.authorized(s.getKillSwitch))
+
+	return s
+}
+
+// Add adds a CREATED work item, with its ledger.send MANY transaction, and returns the work item.
+func (s *Server) Add(m Migration) store.WorkItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.UUID == uuid.Nil {
+		m.UUID = uuid.New()
+	}
+
+	arguments, err := json.Marshal(many.Arguments{
+		From:   m.From,
+		To:     many.IllegalAddr,
+		Amount: m.Amount,
+		Symbol: m.Symbol,
+		Memo:   []string{m.UUID.String(), m.ManifestAddress},
+	})
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(arguments)
+	manyHash := hex.EncodeToString(hash[:])
+
+	createdDate := time.Now().UTC().Truncate(time.Millisecond)
+	item := &store.WorkItem{
+		Status:          store.CREATED,
+		CreatedDate:     &createdDate,
+		UUID:            m.UUID,
+		ManyHash:        manyHash,
+		ManifestAddress: m.ManifestAddress,
+	}
+
+	if _, ok := s.items[m.UUID]; !ok {
+		s.queue = append(s.queue, m.UUID)
+	}
+	s.items[m.UUID] = item
+	s.neighborhoods[m.UUID] = m.Neighborhood
+	s.txs[manyHash] = many.TxInfo{Method: "ledger.send", Arguments: arguments}
+	s.whitelist[m.From] = m.Allowed
+
+	return *item
+}
+
+// Item returns a copy of the work item with the given UUID.
+func (s *Server) Item(itemUUID uuid.UUID) (store.WorkItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[itemUUID]
+	if !ok {
+		return store.WorkItem{}, false
+	}
+	return *item, true
+}
+
+// Items returns a copy of the work items, in creation order.
+func (s *Server) Items() []store.WorkItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]store.WorkItem, 0, len(s.queue))
+	for _, id := range s.queue {
+		items = append(items, *s.items[id])
+	}
+	return items
+}
+
+// SetKillSwitch engages the kill switch with the given reason, or releases it.
+func (s *Server) SetKillSwitch(engaged bool, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.killSwitch = nil
+	if engaged {
+		s.killSwitch = &reason
+	}
+}
+
+// Inject adds a fault. Faults are matched in the order they were injected.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault{Fault: f, remaining: f.Times})
+}
+
+// ServeHTTP serves the talib endpoints, failing the requests matching a fault.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, route := s.mux.Handler(r)
+	f := s.fault(route, r)
+	if f == nil {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+
+	slog.Debug("Injecting fault", "route", route, "status", f.Status, "processed", f.Processed)
+	if f.Processed {
+		s.mux.ServeHTTP(discard{header: http.Header{}}, r)
+	}
+	http.Error(w, http.StatusText(f.Status), f.Status)
+}
+
+// fault returns the first fault matching the request, if any, and consumes it.
+func (s *Server) fault(route string, r *http.Request) *fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The path values are only set once the request is routed, match the UUID against the path instead
+	for _, f := range s.faults {
+		if f.Route != "" && f.Route != route {
+			continue
+		}
+		if f.UUID != "" && !strings.HasSuffix(r.URL.Path, "/"+f.UUID) {
+			continue
+		}
+		if f.served < f.After {
+			f.served++
+			continue
+		}
+		if f.Times > 0 {
+			if f.remaining == 0 {
+				continue
+			}
+			f.remaining--
+		}
+		return f
+	}
+	return nil
+}
+
+// discard is a response writer dropping the response of a processed request failed by a fault.
+type discard struct {
+	header http.Header
+}
+
+func (d discard) Header() http.Header         { return d.header }
+func (d discard) Write(b []byte) (int, error) { return len(b), nil }
+func (d discard) WriteHeader(int)             {}
+
+// authorized rejects the requests without a valid access token or for another neighborhood.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		ok := s.tokens[token]
+		s.mu.Unlock()
+
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if neighborhood := r.PathValue("neighborhood"); neighborhood != "" && !s.serves(neighborhood) {
+			http.Error(w, "unknown neighborhood", http.StatusNotFound)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// serves returns true if the neighborhood is the default neighborhood or the neighborhood of a work item.
+func (s *Server) serves(neighborhood string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if neighborhood == s.Neighborhood {
+		return true
+	}
+	for _, id := range s.queue {
+		if s.neighborhood(id) == neighborhood {
+			return true
+		}
+	}
+	return false
+}
+
+// neighborhood returns the neighborhood of the work item. The lock must be held.
+func (s *Server) neighborhood(itemUUID uuid.UUID) string {
+	if neighborhood := s.neighborhoods[itemUUID]; neighborhood != "" {
+		return neighborhood
+	}
+	return s.Neighborhood
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if credentials.Username != s.Username || credentials.Password != s.Password {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	s.issueToken(w)
+}
+
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	ok := s.refresh[body.RefreshToken]
+	delete(s.refresh, body.RefreshToken)
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	s.issueToken(w)
+}
+
+func (s *Server) issueToken(w http.ResponseWriter) {
+	token := store.Token{AccessToken: randomToken(), RefreshToken: randomToken(), ExpiresIn: tokenLifetime}
+
+	s.mu.Lock()
+	s.tokens[token.AccessToken] = true
+	s.refresh[token.RefreshToken] = true
+	s.mu.Unlock()
+
+	writeJSON(w, token)
+}
+
+func (s *Server) claimQueue(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := []*store.WorkItem{}
+	for _, id := range s.queue {
+		if len(claimed) >= s.ClaimBatch {
+			break
+		}
+		if item := s.items[id]; item.Status == store.CREATED && s.neighborhood(id) == r.PathValue("neighborhood") {
+			item.Status = store.CLAIMED
+			claimed = append(claimed, item)
+		}
+	}
+
+	writeJSON(w, claimed)
+}
+
+// claimUUID claims a CREATED work item, or a work item which is neither CLAIMED nor COMPLETED if forced.
+// Claiming a CLAIMED work item again returns it unchanged.
+func (s *Server) claimUUID(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.item(w, r)
+	if !ok {
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	switch {
+	case item.Status == store.CREATED, item.Status == store.CLAIMED:
+	case force && item.Status != store.COMPLETED:
+		item.Error = nil
+	default:
+		http.Error(w, fmt.Sprintf("work item is %s", item.Status), http.StatusConflict)
+		return
+	}
+
+	item.Status = store.CLAIMED
+	writeJSON(w, item)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, ok := s.item(w, r); ok {
+		writeJSON(w, item)
+	}
+}
+
+// transitions are the valid status changes of a work item.
+var transitions = map[store.WorkItemStatus][]store.WorkItemStatus{
+	store.CLAIMED:   {store.CLAIMED, store.MIGRATING, store.FAILED},
+	store.MIGRATING: {store.MIGRATING, store.COMPLETED, store.FAILED},
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	var req store.WorkItemUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.item(w, r)
+	if !ok {
+		return
+	}
+
+	// Replaying an update is a no-op
+	valid := req.Status == item.Status &&
+		utils.EqualStringPtr(req.ManifestHash, item.ManifestHash) &&
+		utils.EqualTimePtr(req.ManifestDatetime, item.ManifestDatetime) &&
+		utils.EqualStringPtr(req.Error, item.Error)
+	for _, status := range transitions[item.Status] {
+		valid = valid || status == req.Status
+	}
+	if !valid {
+		http.Error(w, fmt.Sprintf("invalid transition from %s to %d", item.Status, req.Status), http.StatusConflict)
+		return
+	}
+
+	switch {
+	case req.Status == store.COMPLETED && (req.ManifestHash == nil || req.ManifestDatetime == nil):
+		http.Error(w, "manifest hash and datetime are required", http.StatusBadRequest)
+		return
+	case req.Status == store.FAILED && req.Error == nil:
+		http.Error(w, "error is required", http.StatusBadRequest)
+		return
+	}
+
+	item.Status = req.Status
+	item.ManifestHash = req.ManifestHash
+	item.ManifestDatetime = req.ManifestDatetime
+	item.Error = req.Error
+
+	writeJSON(w, store.WorkItemUpdateResponse{
+		Status:           item.Status,
+		ManifestDatetime: item.ManifestDatetime,
+		ManifestHash:     item.ManifestHash,
+		Error:            item.Error,
+	})
+}
+
+func (s *Server) transaction(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.txs[r.PathValue("thash")]
+	if !ok {
+		http.Error(w, "transaction not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, tx)
+}
+
+func (s *Server) allowed(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, s.whitelist[r.PathValue("address")])
+}
+
+func (s *Server) getKillSwitch(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flag := map[string]any{"engaged": s.killSwitch != nil, "reason": ""}
+	if s.killSwitch != nil {
+		flag["reason"] = *s.killSwitch
+	}
+	writeJSON(w, flag)
+}
+
+// item returns the work item of the request, writing a not found response if there is none.
+// The lock must be held.
+func (s *Server) item(w http.ResponseWriter, r *http.Request) (*store.WorkItem, bool) {
+	itemUUID, err := uuid.Parse(r.PathValue("uuid"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	item, ok := s.items[itemUUID]
+	if !ok || s.neighborhood(itemUUID) != r.PathValue("neighborhood") {
+		http.Error(w, "work item not found", http.StatusNotFound)
+		return nil, false
+	}
+	return item, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Could not write response", "error", err)
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,130 @@
+package faketalib_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manifest-network/mfx-migrator/internal/store"
+
+	"github.com/manifest-network/mfx-migrator/testutils"
+	"github.com/manifest-network/mfx-migrator/testutils/faketalib"
+)
+
+func setup(t *testing.T) (*faketalib.Server, *resty.Client) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	talib := faketalib.New("user", "pass")
+	server := httptest.NewServer(talib)
+	t.Cleanup(server.Close)
+
+	client := resty.New().SetBaseURL(server.URL).SetPathParam("neighborhood", "0")
+	var token store.Token
+	resp, err := client.R().SetBody(map[string]string{"username": "user", "password": "pass"}).SetResult(&token).Post("/auth/login")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	client.SetAuthToken(token.AccessToken)
+
+	return talib, client
+}
+
+func migration(allowed bool) faketalib.Migration {
+	return faketalib.Migration{From: testutils.ManyFrom, ManifestAddress: testutils.ManifestAddress, Amount: "12345", Symbol: testutils.ManySymbol, Allowed: allowed}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	talib, client := setup(t)
+	ctx := context.Background()
+	first := talib.Add(migration(true))
+	second := talib.Add(migration(true))
+
+	// The queue is claimed in creation order, one work item at a time
+	items, err := store.ClaimWorkItemFromQueue(ctx, client)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, first.UUID, items[0].UUID)
+	require.Equal(t, store.CLAIMED, items[0].Status)
+
+	item, err := store.GetWorkItem(ctx, client, first.UUID)
+	require.NoError(t, err)
+	require.Equal(t, store.CLAIMED, item.Status)
+
+	// A work item cannot be completed before migrating
+	hash := "8F5E3B9A0C1D2E3F"
+	item.Status = store.COMPLETED
+	item.ManifestHash = &hash
+	item.ManifestDatetime = item.CreatedDate
+	require.ErrorContains(t, store.UpdateWorkItemAndSaveState(ctx, client, item), "response status code: 409")
+
+	item.Status = store.MIGRATING
+	require.NoError(t, store.UpdateWorkItemAndSaveState(ctx, client, item))
+	item.Status = store.COMPLETED
+	require.NoError(t, store.UpdateWorkItemAndSaveState(ctx, client, item))
+
+	remote, ok := talib.Item(first.UUID)
+	require.True(t, ok)
+	require.True(t, remote.Equal(*item))
+
+	// Completed work items cannot be claimed again, even if forced
+	_, err = store.ClaimWorkItemFromUUID(ctx, client, first.UUID, true)
+	require.ErrorContains(t, err, "response status code: 409")
+
+	// Failed work items can only be claimed again if forced
+	_, err = store.ClaimWorkItemFromUUID(ctx, client, second.UUID, false)
+	require.NoError(t, err)
+	msg := "some error"
+	failed := second
+	failed.Status = store.FAILED
+	failed.Error = &msg
+	require.NoError(t, store.UpdateWorkItemAndSaveState(ctx, client, &failed))
+	_, err = store.ClaimWorkItemFromUUID(ctx, client, second.UUID, false)
+	require.ErrorContains(t, err, "response status code: 409")
+	item, err = store.ClaimWorkItemFromUUID(ctx, client, second.UUID, true)
+	require.NoError(t, err)
+	require.Equal(t, store.CLAIMED, item.Status)
+	require.Nil(t, item.Error)
+
+	items, err = store.ClaimWorkItemFromQueue(ctx, client)
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	talib, client := setup(t)
+	item := talib.Add(migration(true))
+
+	_, err := store.GetWorkItem(context.Background(), client.SetAuthToken("invalid"), item.UUID)
+	require.ErrorContains(t, err, "response status code: 401")
+}
+
+func TestServer_Faults(t *testing.T) {
+	talib, client := setup(t)
+	ctx := context.Background()
+	item := talib.Add(migration(true))
+
+	// The claim is processed but its response is lost
+	talib.Inject(faketalib.Fault{Route: faketalib.RouteClaimQueue, Status: http.StatusBadGateway, Times: 1, Processed: true})
+	_, err := store.ClaimWorkItemFromQueue(ctx, client)
+	require.ErrorContains(t, err, "response status code: 502")
+	remote, _ := talib.Item(item.UUID)
+	require.Equal(t, store.CLAIMED, remote.Status)
+
+	// Only the requests of the work item fail, until the fault is exhausted
+	other := talib.Add(migration(true))
+	talib.Inject(faketalib.Fault{UUID: item.UUID.String(), Status: http.StatusInternalServerError, Times: 2})
+	for range 2 {
+		_, err = store.GetWorkItem(ctx, client, item.UUID)
+		require.ErrorContains(t, err, "response status code: 500")
+		_, err = store.GetWorkItem(ctx, client, other.UUID)
+		require.NoError(t, err)
+	}
+	_, err = store.GetWorkItem(ctx, client, item.UUID)
+	require.NoError(t, err)
+}
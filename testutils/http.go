@@ -20,5 +20,6 @@ var (
 	ClaimUrl     = RootUrl + fmt.Sprintf("neighborhoods/%s/migrations/claim/", Neighborhood)
 	ClaimUuidUrl = ClaimUrl + Uuidv4Regex
 	LoginUrl     = RootUrl + "auth/login"
+	RefreshUrl   = RootUrl + "auth/refresh"
 	WhiteListUrl = RootUrl + "migrations-whitelist/" + "[a-zA-Z0-9]{1,}"
 )
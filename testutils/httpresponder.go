@@ -13,8 +13,6 @@ import (
 	"github.com/manifest-network/mfx-migrator/internal/store"
 )
 
-// TODO: Randomize parameters. Fuzzy testing.
-
 const (
 	Uuid            = "5aa19d2a-4bdf-4687-a850-1804756b3f1f"
 	ManyFrom        = "maffbahksdwaqeenayy2gxke32hgb7aq4ao4wt745lsfs6wijp"
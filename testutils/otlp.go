@@ -0,0 +1,78 @@
+package testutils
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// CollectedSpan is a span received by the TraceCollector.
+type CollectedSpan struct {
+	Name    string
+	TraceID string
+}
+
+// TraceCollector is a stand-in for an OTLP/HTTP trace collector, keeping the received spans in memory.
+type TraceCollector struct {
+	URL string
+
+	mu    sync.Mutex
+	spans []CollectedSpan
+}
+
+// NewTraceCollector starts a TraceCollector, stopped when the test ends.
+func NewTraceCollector(t *testing.T) *TraceCollector {
+	t.Helper()
+
+	c := &TraceCollector{}
+	server := httptest.NewServer(http.HandlerFunc(c.export))
+	t.Cleanup(server.Close)
+	c.URL = server.URL + "/v1/traces"
+
+	return c
+}
+
+// Spans returns the spans received so far.
+func (c *TraceCollector) Spans() []CollectedSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CollectedSpan(nil), c.spans...)
+}
+
+func (c *TraceCollector) export(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	for _, resourceSpans := range req.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			for _, span := range scopeSpans.GetSpans() {
+				c.spans = append(c.spans, CollectedSpan{Name: span.GetName(), TraceID: hex.EncodeToString(span.GetTraceId())})
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	resp, err := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
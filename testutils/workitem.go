@@ -1,6 +1,7 @@
 package testutils
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -41,7 +42,7 @@ func SetupWorkItem(t *testing.T) {
 		Error:            nil,
 	}
 
-	if err := store.SaveState(&item); err != nil {
+	if err := store.SaveState(context.Background(), &item); err != nil {
 		t.Fatal(err)
 	}
 }